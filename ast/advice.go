@@ -2,11 +2,15 @@ package ast
 
 import (
 	"bytes"
+	"fmt"
+	"go/token"
 	"path"
+	"strconv"
 	"strings"
 	"text/template"
 
 	"github.com/dave/dst"
+	"github.com/dave/dst/dstutil"
 	"github.com/whatap/go-api-inst/ast/common"
 )
 
@@ -214,6 +218,58 @@ func isWrappedBy(expr dst.Expr, alias, funcName string) bool {
 	return ident.Name == alias && sel.Sel.Name == funcName
 }
 
+// ValueWrap wraps a function or method referenced as a value ("value:"
+// targets) in an adapter: whatapAlias.WhatapFunc(ref). The adapter takes the
+// function and returns one of the same type, so the expression still fits
+// wherever the reference did.
+//
+// Example (database/sql):
+//
+//	opener := sql.Open → opener := whatapsql.WrapOpen(sql.Open)
+//	http.HandleFunc("/", h.Get) → http.HandleFunc("/", whataphttp.WrapHandlerFunc(h.Get))
+type ValueWrap struct {
+	WhatapPkg   string // whatap import path
+	WhatapAlias string // whatap alias in code
+	WhatapFunc  string // adapter function name, e.g. "WrapOpen"
+}
+
+func (a *ValueWrap) Apply(ctx *MatchContext) {
+	if ctx.Ref == nil {
+		ctx.Applied = false
+		return
+	}
+	// The reference has no slot of its own to rewrite in place; find its
+	// parent through a cursor. Package-level references have no statement.
+	var root dst.Node = ctx.File
+	if ctx.EnclosingStmt != nil {
+		root = ctx.EnclosingStmt
+	}
+	wrapped := false
+	dstutil.Apply(root, func(c *dstutil.Cursor) bool {
+		if wrapped || c.Node() != ctx.Ref {
+			return !wrapped
+		}
+		// The nested-block pass revisits statements; leave a reference the
+		// first pass already wrapped alone.
+		if parent, ok := c.Parent().(*dst.CallExpr); ok && isWrappedBy(parent, a.WhatapAlias, a.WhatapFunc) {
+			return false
+		}
+		c.Replace(&dst.CallExpr{
+			Fun: &dst.SelectorExpr{
+				X:   dst.NewIdent(a.WhatapAlias),
+				Sel: dst.NewIdent(a.WhatapFunc),
+			},
+			Args: []dst.Expr{ctx.Ref},
+		})
+		wrapped = true
+		return false
+	}, nil)
+	ctx.Applied = wrapped
+}
+
+func (a *ValueWrap) WhatapImportPath() string  { return a.WhatapPkg }
+func (a *ValueWrap) WhatapImportAlias() string { return a.WhatapAlias }
+
 // CodeInsert inserts a statement before or after the matched call.
 // All fields are declarative settings — no callbacks.
 //
@@ -674,6 +730,49 @@ func (a *FieldWrapOrInsert) Apply(ctx *MatchContext) {
 func (a *FieldWrapOrInsert) WhatapImportPath() string  { return a.WhatapPkg }
 func (a *FieldWrapOrInsert) WhatapImportAlias() string { return a.WhatapAlias }
 
+// FieldAssignWrap wraps the value assigned to a typed struct field.
+// Complements FieldWrapOrInsert for code that sets the field after
+// construction instead of inside the composite literal. The target is
+// resolved via go/types, so only assignments whose receiver is the target
+// type match (see resolveAssignTarget).
+//
+// Example (net/http.Client.Transport=):
+//
+//	c := &http.Client{}
+//	c.Transport = myRT  →  c.Transport = whataphttp.NewRoundTrip(ctx, myRT)
+type FieldAssignWrap struct {
+	WhatapPkg   string // whatap import path
+	WhatapAlias string // alias in code
+	WhatapFunc  string // wrapper function, e.g. "NewRoundTrip"
+	CtxAware    bool   // true: wrapper takes ctx as first arg
+}
+
+func (a *FieldAssignWrap) Apply(ctx *MatchContext) {
+	if ctx.Assign == nil || len(ctx.Assign.Rhs) != 1 {
+		ctx.Applied = false
+		return
+	}
+	value := ctx.Assign.Rhs[0]
+	if isWrappedBy(value, a.WhatapAlias, a.WhatapFunc) {
+		ctx.Applied = false
+		return
+	}
+	args := []dst.Expr{value}
+	if a.CtxAware {
+		args = append([]dst.Expr{detectCtxExpr(ctx)}, args...)
+	}
+	ctx.Assign.Rhs[0] = &dst.CallExpr{
+		Fun: &dst.SelectorExpr{
+			X:   dst.NewIdent(a.WhatapAlias),
+			Sel: dst.NewIdent(a.WhatapFunc),
+		},
+		Args: args,
+	}
+}
+
+func (a *FieldAssignWrap) WhatapImportPath() string  { return a.WhatapPkg }
+func (a *FieldAssignWrap) WhatapImportAlias() string { return a.WhatapAlias }
+
 // ReplaceWithCtx replaces a function call and prepends a context argument.
 // Used for net/http client functions that need context injection.
 //
@@ -752,6 +851,12 @@ type TransformContext struct {
 	// and individual-arg (`f(h, a, b, c)`) call sites. Empty when ArgCount <= 1.
 	Args1Plus string // e.g. "hosts..." or "a, b, c"
 	IsSpread  bool   // true when the source call passes a slice with `...` (Call.Ellipsis)
+
+	// Around only — how the function finished (see Around).
+	Results     string   // result names, comma-joined: "whatapRet0, whatapErr"
+	ResultsList []string // result names as a slice
+	Err         string   // name of the trailing error result ("" if none)
+	Panic       string   // name of the recovered panic value ("" unless End uses it)
 }
 
 // Transform applies a Go text/template to transform a matched call.
@@ -780,15 +885,17 @@ func (a *Transform) applyInject(ctx *MatchContext) {
 	tc := buildCallTransformContext(ctx)
 
 	// Execute template
-	tmpl, err := template.New("transform").Parse(a.Template)
+	tmpl, err := template.New("transform").Funcs(templateFuncs(ctx)).Parse(a.Template)
 	if err != nil {
 		ctx.Applied = false
+		ctx.Err = err
 		return
 	}
 
 	var buf bytes.Buffer
 	if err := tmpl.Execute(&buf, tc); err != nil {
 		ctx.Applied = false
+		ctx.Err = err
 		return
 	}
 
@@ -799,7 +906,12 @@ func (a *Transform) applyInject(ctx *MatchContext) {
 	// wrap result as `x, err := <template result>`
 	if assign, ok := ctx.EnclosingStmt.(*dst.AssignStmt); ok {
 		stmts, err := parseCodeBlock(result)
-		if err != nil || len(stmts) == 0 {
+		if err != nil {
+			ctx.Applied = false
+			ctx.Err = fmt.Errorf("template: expanded code does not parse: %w", err)
+			return
+		}
+		if len(stmts) == 0 {
 			ctx.Applied = false
 			return
 		}
@@ -817,7 +929,12 @@ func (a *Transform) applyInject(ctx *MatchContext) {
 		}
 	} else if _, ok := ctx.EnclosingStmt.(*dst.ExprStmt); ok {
 		stmts, err := parseCodeBlock(result)
-		if err != nil || len(stmts) == 0 {
+		if err != nil {
+			ctx.Applied = false
+			ctx.Err = fmt.Errorf("template: expanded code does not parse: %w", err)
+			return
+		}
+		if len(stmts) == 0 {
 			ctx.Applied = false
 			return
 		}
@@ -884,6 +1001,19 @@ func resolveTargetPkgAlias(ctx *MatchContext) string {
 		}
 	}
 
+	// pkg.Func() call: the selector's ident already is the local package name
+	// (go/types confirms it refers to targetImport). Covers imports whose
+	// package name differs from the path, e.g. opensearch-go/v4 → "opensearch".
+	if ctx.Sel != nil {
+		if ident, ok := ctx.Sel.X.(*dst.Ident); ok && common.GetIdentPath(ident) == targetImport {
+			return ident.Name
+		}
+	}
+	// Otherwise any other reference to the package in the file (go/types).
+	if name := localPkgName(ctx.File, targetImport); name != "" {
+		return name
+	}
+
 	// Search file imports for this path
 	for _, imp := range ctx.File.Imports {
 		impPath := strings.Trim(imp.Path.Value, `"`)
@@ -904,6 +1034,25 @@ func resolveTargetPkgAlias(ctx *MatchContext) string {
 	return ctx.PkgName // fallback
 }
 
+// localPkgName returns the name the file uses for importPath, read from a
+// go/types-resolved package reference (`opensearch.Config`). "" without
+// type info or without a reference.
+func localPkgName(file *dst.File, importPath string) string {
+	name := ""
+	dst.Inspect(file, func(n dst.Node) bool {
+		if name != "" {
+			return false
+		}
+		if sel, ok := n.(*dst.SelectorExpr); ok {
+			if ident, ok := sel.X.(*dst.Ident); ok && common.GetIdentPath(ident) == importPath {
+				name = ident.Name
+			}
+		}
+		return name == ""
+	})
+	return name
+}
+
 // isVersionSuffix returns true if s looks like "v2", "v3", etc.
 func isVersionSuffix(s string) bool {
 	if len(s) < 2 || s[0] != 'v' {
@@ -962,14 +1111,17 @@ func (a *Hook) Apply(ctx *MatchContext) {
 	}
 
 	tc := buildCallTransformContext(ctx)
-	beforeStmts, err := evalTemplateStmts(tc, a.Before)
+	funcs := templateFuncs(ctx)
+	beforeStmts, err := evalTemplateStmts(funcs, tc, a.Before)
 	if err != nil {
 		ctx.Applied = false
+		ctx.Err = fmt.Errorf("before: %w", err)
 		return
 	}
-	afterStmts, err := evalTemplateStmts(tc, a.After)
+	afterStmts, err := evalTemplateStmts(funcs, tc, a.After)
 	if err != nil {
 		ctx.Applied = false
+		ctx.Err = fmt.Errorf("after: %w", err)
 		return
 	}
 	if len(beforeStmts) == 0 && len(afterStmts) == 0 {
@@ -1041,14 +1193,17 @@ func (a *Inject) Apply(ctx *MatchContext) {
 	}
 
 	tc := buildDeclTransformContext(ctx)
-	startStmts, err := evalTemplateStmts(tc, a.Start)
+	funcs := templateFuncs(ctx)
+	startStmts, err := evalTemplateStmts(funcs, tc, a.Start)
 	if err != nil {
 		ctx.Applied = false
+		ctx.Err = fmt.Errorf("start: %w", err)
 		return
 	}
-	endStmts, err := evalTemplateStmts(tc, a.End)
+	endStmts, err := evalTemplateStmts(funcs, tc, a.End)
 	if err != nil {
 		ctx.Applied = false
+		ctx.Err = fmt.Errorf("end: %w", err)
 		return
 	}
 	if len(startStmts) == 0 && len(endStmts) == 0 {
@@ -1099,3 +1254,561 @@ func (a *Inject) WhatapImportAlias() string {
 	}
 	return ""
 }
+
+// ── Around — start/end code that sees results, returned error and panic ──
+
+// Around is Inject for end code that needs to know how the function finished.
+// The FuncDecl's results are named (unnamed and `_` results get fresh names)
+// so the deferred end code runs after the return values have been set:
+//
+//	func (s *Store) Get(id string) (whatapRet0 *Item, whatapErr error) {
+//		<start>
+//		defer func() {
+//			whatapPanic := recover()
+//			<end>
+//			if whatapPanic != nil {
+//				panic(whatapPanic)
+//			}
+//		}()
+//		...original body
+//	}
+//
+// End templates additionally see {{.Results}} / {{.ResultsList}} (the result
+// names), {{.Err}} (the last result when its type is error, else "") and
+// {{.Panic}} (the recovered value, nil on a normal return). recover is only
+// emitted when the End template uses the .Panic field (not merely the word),
+// and the panic is re-raised after the end code so callers still observe it. End code may assign to the results.
+type Around struct {
+	Start         string            // code inserted at function start
+	End           string            // code run by a deferred closure at function exit
+	Imports       []string          // extra imports needed by the code
+	ImportAliases map[string]string // import path → alias
+}
+
+func (a *Around) Apply(ctx *MatchContext) {
+	fn := ctx.Decl
+	if fn == nil || fn.Body == nil {
+		ctx.Applied = false
+		return
+	}
+
+	// Pick names first; the FuncDecl is only modified once both templates
+	// have expanded.
+	taken := identNames(fn)
+	fresh := func(base string) string {
+		name := base
+		for i := 1; taken[name]; i++ {
+			name = base + strconv.Itoa(i)
+		}
+		taken[name] = true
+		return name
+	}
+	var fieldNames [][]string
+	var results []string
+	errName := ""
+	if fn.Type.Results != nil {
+		fields := fn.Type.Results.List
+		for i, f := range fields {
+			isErr := i == len(fields)-1 && isErrorTypeExpr(f.Type)
+			base := "whatapRet" + strconv.Itoa(len(results))
+			if isErr {
+				base = "whatapErr"
+			}
+			var names []string
+			if len(f.Names) == 0 {
+				names = append(names, fresh(base))
+			}
+			for _, id := range f.Names {
+				if id.Name == "_" {
+					names = append(names, fresh(base))
+				} else {
+					names = append(names, id.Name)
+				}
+			}
+			fieldNames = append(fieldNames, names)
+			results = append(results, names...)
+			if isErr {
+				errName = names[len(names)-1]
+			}
+		}
+	}
+	panicName := ""
+	if templateUsesField(a.End, "Panic") {
+		panicName = fresh("whatapPanic")
+	}
+
+	tc := buildDeclTransformContext(ctx)
+	tc.ResultsList = results
+	tc.Results = strings.Join(results, ", ")
+	tc.Err = errName
+	tc.Panic = panicName
+	funcs := templateFuncs(ctx)
+	startStmts, err := evalTemplateStmts(funcs, tc, a.Start)
+	if err != nil {
+		ctx.Applied = false
+		ctx.Err = fmt.Errorf("start: %w", err)
+		return
+	}
+	endStmts, err := evalTemplateStmts(funcs, tc, a.End)
+	if err != nil {
+		ctx.Applied = false
+		ctx.Err = fmt.Errorf("end: %w", err)
+		return
+	}
+	if len(endStmts) == 0 {
+		ctx.Applied = false
+		return
+	}
+
+	for i, f := range fieldNames {
+		field := fn.Type.Results.List[i]
+		if len(field.Names) == 0 {
+			field.Names = []*dst.Ident{dst.NewIdent(f[0])}
+			continue
+		}
+		for j, id := range field.Names {
+			id.Name = f[j]
+		}
+	}
+
+	deferBody := endStmts
+	if panicName != "" {
+		deferBody = make([]dst.Stmt, 0, len(endStmts)+2)
+		deferBody = append(deferBody, &dst.AssignStmt{
+			Lhs: []dst.Expr{dst.NewIdent(panicName)},
+			Tok: token.DEFINE,
+			Rhs: []dst.Expr{&dst.CallExpr{Fun: dst.NewIdent("recover")}},
+		})
+		deferBody = append(deferBody, endStmts...)
+		deferBody = append(deferBody, &dst.IfStmt{
+			Cond: &dst.BinaryExpr{X: dst.NewIdent(panicName), Op: token.NEQ, Y: dst.NewIdent("nil")},
+			Body: &dst.BlockStmt{List: []dst.Stmt{&dst.ExprStmt{X: &dst.CallExpr{
+				Fun:  dst.NewIdent("panic"),
+				Args: []dst.Expr{dst.NewIdent(panicName)},
+			}}}},
+		})
+	}
+	prefix := make([]dst.Stmt, 0, len(startStmts)+1)
+	prefix = append(prefix, startStmts...)
+	prefix = append(prefix, &dst.DeferStmt{
+		Call: &dst.CallExpr{
+			Fun: &dst.FuncLit{
+				Type: &dst.FuncType{Params: &dst.FieldList{}},
+				Body: &dst.BlockStmt{List: deferBody},
+			},
+		},
+	})
+	fn.Body.List = append(prefix, fn.Body.List...)
+
+	if ctx.ExtraImports == nil {
+		ctx.ExtraImports = make(map[string]string)
+	}
+	for _, imp := range a.Imports {
+		alias := ""
+		if a.ImportAliases != nil {
+			alias = a.ImportAliases[imp]
+		}
+		ctx.ExtraImports[imp] = alias
+	}
+	ctx.Applied = true
+}
+
+func (a *Around) WhatapImportPath() string {
+	if len(a.Imports) > 0 {
+		return a.Imports[0]
+	}
+	return ""
+}
+
+func (a *Around) WhatapImportAlias() string {
+	if a.ImportAliases != nil && len(a.Imports) > 0 {
+		return a.ImportAliases[a.Imports[0]]
+	}
+	return ""
+}
+
+// isErrorTypeExpr reports whether expr is the predeclared `error` type.
+func isErrorTypeExpr(expr dst.Expr) bool {
+	id, ok := expr.(*dst.Ident)
+	return ok && id.Name == "error" && id.Path == ""
+}
+
+// ── LoopBody — one transaction per consumer loop iteration ──
+
+// LoopBody starts a transaction for every iteration of the loop that receives
+// from the matched call and ends it when that iteration is over. Two loop
+// shapes are recognised, anywhere in the enclosing function (func literals
+// such as `go func() { ... }()` included):
+//
+//	for msg := range claim.Messages() { handle(msg) }   // call is the range expression
+//	for { m, err := r.ReadMessage(ctx); ... }           // call is a body statement's RHS
+//
+// A "range:" target matches the range loop itself (`for m := range ch` over a
+// channel of the target type), which is then the loop instrumented.
+//
+// The received values (range element / assignment LHS, in order) are passed
+// to StartFunc and its result to EndFunc. A blank `_` in a `:=` form is renamed
+// so it can be passed; a blank in a plain `=` form skips the loop.
+//
+// If the rest of the iteration cannot leave early (no return, no break /
+// continue / goto out of it, no defer or label), it is moved into a func
+// literal headed by `defer alias.EndFunc(alias.StartFunc(recv...))` so a panic
+// still ends the transaction. Otherwise the statements stay in place and
+// EndFunc is called explicitly before every exit and at the end of the body.
+type LoopBody struct {
+	WhatapPkg   string // whatap import path
+	WhatapAlias string // whatap alias in code
+	StartFunc   string // e.g. "StartConsume" — called with the received values
+	EndFunc     string // e.g. "EndConsume" — called with StartFunc's result
+}
+
+// loopBodyTxVar holds StartFunc's result in the explicit (non-closure) form.
+// A numeric suffix is added when the enclosing function already uses the
+// name — nested consumer loops would otherwise shadow the outer transaction
+// and end the inner one at the outer loop's exits.
+const loopBodyTxVar = "whatapLoopTx"
+
+func (a *LoopBody) Apply(ctx *MatchContext) {
+	site := findLoopSite(ctx)
+	if site == nil {
+		ctx.Applied = false
+		return
+	}
+	rest := (*site.body)[site.start:]
+	if len(rest) == 0 || stmtsCallWhatap(rest, a.WhatapAlias, a.StartFunc) {
+		ctx.Applied = false
+		return
+	}
+	recv, ok := site.receivedValues()
+	if !ok {
+		ctx.Applied = false
+		return
+	}
+
+	startCall := whatapCall(a.WhatapAlias, a.StartFunc, recv...)
+	head := (*site.body)[:site.start:site.start]
+
+	if !needsExplicitEnd(rest) {
+		// for ... { recv; func() { defer End(Start(recv)); rest... }() }
+		deferStmt := &dst.DeferStmt{Call: whatapCall(a.WhatapAlias, a.EndFunc, startCall)}
+		lit := &dst.FuncLit{
+			Type: &dst.FuncType{Params: &dst.FieldList{}},
+			Body: &dst.BlockStmt{List: append([]dst.Stmt{deferStmt}, rest...)},
+		}
+		*site.body = append(head, &dst.ExprStmt{X: &dst.CallExpr{Fun: lit}})
+		ctx.Blocks = append(ctx.Blocks, lit.Body)
+		return
+	}
+
+	// for ... { recv; tx := Start(recv); ...; End(tx); break; ...; End(tx) }
+	taken := identNames(ctx.EnclosingFunc)
+	txVar := loopBodyTxVar
+	for i := 1; taken[txVar]; i++ {
+		txVar = loopBodyTxVar + strconv.Itoa(i)
+	}
+	endStmt := func() dst.Stmt {
+		return &dst.ExprStmt{X: whatapCall(a.WhatapAlias, a.EndFunc, dst.NewIdent(txVar))}
+	}
+	tail := append([]dst.Stmt(nil), rest...)
+	sc := exitScope{labels: declaredLabels(tail)}
+	insertBeforeExits(&tail, sc, endStmt)
+	if last := tail[len(tail)-1]; !isIterationExit(last, sc) && !isPanicStmt(last) {
+		tail = append(tail, endStmt())
+	}
+	startStmt := &dst.AssignStmt{
+		Lhs: []dst.Expr{dst.NewIdent(txVar)},
+		Tok: token.DEFINE,
+		Rhs: []dst.Expr{startCall},
+	}
+	*site.body = append(append(head, startStmt), tail...)
+}
+
+func (a *LoopBody) WhatapImportPath() string  { return a.WhatapPkg }
+func (a *LoopBody) WhatapImportAlias() string { return a.WhatapAlias }
+
+// loopSite is the loop a LoopBody match instruments: its body, the index where
+// the per-iteration transaction begins, and where the received values live.
+type loopSite struct {
+	body   *[]dst.Stmt
+	start  int
+	rng    *dst.RangeStmt  // range form
+	assign *dst.AssignStmt // assignment form
+}
+
+// findLoopSite locates the loop fed by ctx.Call (a "range:" match names its
+// loop directly). The search walks the whole
+// enclosing function instead of trusting ctx.ParentBlock, because the engine's
+// first pass reports the outermost statement (and never enters func literals
+// as blocks), while consumer loops usually sit in a goroutine.
+func findLoopSite(ctx *MatchContext) *loopSite {
+	if ctx.Range != nil {
+		if ctx.Range.Body == nil {
+			return nil
+		}
+		return &loopSite{body: &ctx.Range.Body.List, rng: ctx.Range}
+	}
+	if ctx.Call == nil || ctx.EnclosingFunc == nil || ctx.EnclosingFunc.Body == nil {
+		return nil
+	}
+	var site *loopSite
+	dst.Inspect(ctx.EnclosingFunc.Body, func(n dst.Node) bool {
+		if site != nil {
+			return false
+		}
+		var body *dst.BlockStmt
+		switch s := n.(type) {
+		case *dst.RangeStmt:
+			if s.X == ctx.Call && s.Body != nil {
+				site = &loopSite{body: &s.Body.List, rng: s}
+				return false
+			}
+			body = s.Body
+		case *dst.ForStmt:
+			body = s.Body
+		}
+		if body == nil {
+			return true
+		}
+		for i, stmt := range body.List {
+			if as, ok := stmt.(*dst.AssignStmt); ok && len(as.Rhs) == 1 && as.Rhs[0] == ctx.Call {
+				site = &loopSite{body: &body.List, start: i + 1, assign: as}
+				return false
+			}
+		}
+		return true
+	})
+	return site
+}
+
+// receivedValues returns fresh expressions for the values received per
+// iteration, renaming blank identifiers in `:=` forms so they can be passed on.
+// The range form passes the element only (Value if present, else Key).
+func (s *loopSite) receivedValues() ([]dst.Expr, bool) {
+	if s.rng != nil {
+		slot := &s.rng.Key
+		if s.rng.Value != nil {
+			slot = &s.rng.Value
+		}
+		if *slot == nil {
+			// for range ch { ... } → for whatapRecv0 := range ch { ... }
+			s.rng.Key, s.rng.Tok = dst.NewIdent("whatapRecv0"), token.DEFINE
+			return []dst.Expr{dst.NewIdent("whatapRecv0")}, true
+		}
+		e, ok := nameBlank(slot, 0, s.rng.Tok)
+		if !ok {
+			return nil, false
+		}
+		return []dst.Expr{e}, true
+	}
+	out := make([]dst.Expr, 0, len(s.assign.Lhs))
+	for i := range s.assign.Lhs {
+		e, ok := nameBlank(&s.assign.Lhs[i], i, s.assign.Tok)
+		if !ok {
+			return nil, false
+		}
+		out = append(out, e)
+	}
+	return out, true
+}
+
+// nameBlank returns a clone of *slot, first replacing a blank `_` with
+// whatapRecv<i> when tok is `:=`. A blank under `=` cannot be renamed.
+func nameBlank(slot *dst.Expr, i int, tok token.Token) (dst.Expr, bool) {
+	if id, ok := (*slot).(*dst.Ident); ok && id.Name == "_" {
+		if tok != token.DEFINE {
+			return nil, false
+		}
+		id.Name = "whatapRecv" + strconv.Itoa(i)
+	}
+	return dst.Clone(*slot).(dst.Expr), true
+}
+
+// whatapCall builds alias.fn(args...).
+func whatapCall(alias, fn string, args ...dst.Expr) *dst.CallExpr {
+	return &dst.CallExpr{
+		Fun:  &dst.SelectorExpr{X: dst.NewIdent(alias), Sel: dst.NewIdent(fn)},
+		Args: args,
+	}
+}
+
+// stmtsCallWhatap reports whether alias.fn is called anywhere in stmts — the
+// already-instrumented check (the engine may visit the same loop twice).
+func stmtsCallWhatap(stmts []dst.Stmt, alias, fn string) bool {
+	found := false
+	for _, s := range stmts {
+		dst.Inspect(s, func(n dst.Node) bool {
+			if found {
+				return false
+			}
+			if call, ok := n.(*dst.CallExpr); ok && isWrappedBy(call, alias, fn) {
+				found = true
+			}
+			return !found
+		})
+	}
+	return found
+}
+
+// exitScope tracks what an unlabeled break/continue would leave at a given
+// depth inside the iteration, plus the labels declared within it.
+type exitScope struct {
+	inLoop      bool // inside a nested for/range — continue stays in the iteration
+	inBreakable bool // inside a nested for/range/switch/select — break stays too
+	labels      map[string]bool
+}
+
+func (sc exitScope) nested(loop bool) exitScope {
+	return exitScope{inLoop: sc.inLoop || loop, inBreakable: true, labels: sc.labels}
+}
+
+// isIterationExit reports whether s leaves the current iteration: a return,
+// or a break/continue/goto whose target lies outside it.
+func isIterationExit(s dst.Stmt, sc exitScope) bool {
+	switch s := s.(type) {
+	case *dst.ReturnStmt:
+		return true
+	case *dst.BranchStmt:
+		if s.Label != nil {
+			return !sc.labels[s.Label.Name]
+		}
+		switch s.Tok {
+		case token.BREAK:
+			return !sc.inBreakable
+		case token.CONTINUE:
+			return !sc.inLoop
+		}
+	}
+	return false
+}
+
+// isPanicStmt reports whether s is a bare panic(...) call statement, after
+// which an appended EndFunc call would be unreachable.
+func isPanicStmt(s dst.Stmt) bool {
+	es, ok := s.(*dst.ExprStmt)
+	if !ok {
+		return false
+	}
+	call, ok := es.X.(*dst.CallExpr)
+	if !ok {
+		return false
+	}
+	id, ok := call.Fun.(*dst.Ident)
+	return ok && id.Name == "panic"
+}
+
+// declaredLabels collects the labels declared in stmts (func literals excluded).
+func declaredLabels(stmts []dst.Stmt) map[string]bool {
+	labels := map[string]bool{}
+	for _, s := range stmts {
+		dst.Inspect(s, func(n dst.Node) bool {
+			switch n := n.(type) {
+			case *dst.FuncLit:
+				return false
+			case *dst.LabeledStmt:
+				labels[n.Label.Name] = true
+			}
+			return true
+		})
+	}
+	return labels
+}
+
+// identNames collects every identifier name under node.
+func identNames(node dst.Node) map[string]bool {
+	names := map[string]bool{}
+	dst.Inspect(node, func(n dst.Node) bool {
+		if id, ok := n.(*dst.Ident); ok {
+			names[id.Name] = true
+		}
+		return true
+	})
+	return names
+}
+
+// needsExplicitEnd reports whether stmts cannot be moved into a func literal
+// unchanged: they leave the iteration early, declare a label (a goto from
+// before the move would break) or defer (it would run per iteration).
+func needsExplicitEnd(stmts []dst.Stmt) bool {
+	if len(declaredLabels(stmts)) > 0 {
+		return true
+	}
+	blocked := false
+	for _, s := range stmts {
+		dst.Inspect(s, func(n dst.Node) bool {
+			switch n.(type) {
+			case *dst.FuncLit:
+				return false
+			case *dst.DeferStmt:
+				blocked = true
+			}
+			return !blocked
+		})
+	}
+	if blocked {
+		return true
+	}
+	return insertBeforeExits(&stmts, exitScope{labels: map[string]bool{}}, nil) > 0
+}
+
+// insertBeforeExits inserts mk() before every iteration exit in list and its
+// nested statement blocks (func literals excluded) and returns how many exits
+// were found. With mk == nil it only counts and leaves the tree untouched.
+func insertBeforeExits(list *[]dst.Stmt, sc exitScope, mk func() dst.Stmt) int {
+	n := 0
+	out := make([]dst.Stmt, 0, len(*list))
+	for _, s := range *list {
+		if isIterationExit(s, sc) {
+			n++
+			if mk != nil {
+				out = append(out, mk())
+			}
+		} else {
+			n += insertBeforeExitsIn(s, sc, mk)
+		}
+		out = append(out, s)
+	}
+	if mk != nil {
+		*list = out
+	}
+	return n
+}
+
+func insertBeforeExitsIn(s dst.Stmt, sc exitScope, mk func() dst.Stmt) int {
+	n := 0
+	switch s := s.(type) {
+	case *dst.BlockStmt:
+		n += insertBeforeExits(&s.List, sc, mk)
+	case *dst.IfStmt:
+		n += insertBeforeExits(&s.Body.List, sc, mk)
+		if s.Else != nil {
+			n += insertBeforeExitsIn(s.Else, sc, mk)
+		}
+	case *dst.ForStmt:
+		n += insertBeforeExits(&s.Body.List, sc.nested(true), mk)
+	case *dst.RangeStmt:
+		n += insertBeforeExits(&s.Body.List, sc.nested(true), mk)
+	case *dst.SwitchStmt:
+		for _, c := range s.Body.List {
+			n += insertBeforeExits(&c.(*dst.CaseClause).Body, sc.nested(false), mk)
+		}
+	case *dst.TypeSwitchStmt:
+		for _, c := range s.Body.List {
+			n += insertBeforeExits(&c.(*dst.CaseClause).Body, sc.nested(false), mk)
+		}
+	case *dst.SelectStmt:
+		for _, c := range s.Body.List {
+			n += insertBeforeExits(&c.(*dst.CommClause).Body, sc.nested(false), mk)
+		}
+	case *dst.LabeledStmt:
+		if isIterationExit(s.Stmt, sc) {
+			n++
+			if mk != nil {
+				// L: return → L: { End(tx); return }
+				s.Stmt = &dst.BlockStmt{List: []dst.Stmt{mk(), s.Stmt}}
+			}
+		} else {
+			n += insertBeforeExitsIn(s.Stmt, sc, mk)
+		}
+	}
+	return n
+}
@@ -0,0 +1,182 @@
+package ast
+
+import (
+	goast "go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+)
+
+// applyAround runs an Around advice on the named FuncDecl and returns the
+// rendered file.
+func applyAround(t *testing.T, src, funcName string, adv *Around) string {
+	t.Helper()
+	file := parseTestFile(t, src)
+	fn := findFuncDecl(file, funcName)
+	ctx := &MatchContext{
+		File: file, Mode: ModeInject, Decl: fn, FuncName: funcName, EnclosingFunc: fn,
+		ParentBlock: &fn.Body.List,
+	}
+	adv.Apply(ctx)
+	if ctx.Err != nil {
+		t.Fatalf("Apply: %v", ctx.Err)
+	}
+	if !ctx.Applied {
+		t.Fatal("Around should have applied")
+	}
+	return fileToString(t, file)
+}
+
+// typeCheck fails the test when src (a self-contained package) does not
+// type-check.
+func typeCheck(t *testing.T, src string) {
+	t.Helper()
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatalf("parse: %v\n%s", err, src)
+	}
+	if _, err := (&types.Config{}).Check("p", fset, []*goast.File{f}, nil); err != nil {
+		t.Fatalf("type-check: %v\n%s", err, src)
+	}
+}
+
+// TestAround_UnnamedResultsErrPanic names the results of a method returning
+// (T, error) and hands the error and recovered panic to the end code.
+func TestAround_UnnamedResultsErrPanic(t *testing.T) {
+	src := `package p
+
+type Item struct{}
+
+type Store struct{}
+
+func record(name string, n int, err error, p any) {}
+
+func (s *Store) Get(id string) (*Item, error) {
+	if id == "" {
+		panic("empty id")
+	}
+	return &Item{}, nil
+}
+`
+	got := applyAround(t, src, "Get", &Around{
+		Start: `_ = {{quote .FuncName}}`,
+		End:   `record({{quote .FuncName}}, {{len .ResultsList}}, {{.Err}}, {{.Panic}})`,
+	})
+	for _, want := range []string{
+		"func (s *Store) Get(id string) (whatapRet0 *Item, whatapErr error) {\n\t_ = \"Get\"\n\tdefer func() {",
+		"whatapPanic := recover()\n\t\trecord(\"Get\", 2, whatapErr, whatapPanic)\n\t\tif whatapPanic != nil {\n\t\t\tpanic(whatapPanic)\n\t\t}\n\t}()",
+		"return &Item{}, nil",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("want %q in:\n%s", want, got)
+		}
+	}
+	typeCheck(t, got)
+}
+
+// TestAround_NamedAndBlankResults keeps existing result names, renames `_`,
+// avoids identifiers already used in the function, and omits recover when
+// the end code does not use .Panic.
+func TestAround_NamedAndBlankResults(t *testing.T) {
+	src := `package p
+
+func record(args ...any) {}
+
+func Split(s string) (head string, _ int, err error) {
+	whatapRet1 := len(s)
+	return s, whatapRet1, nil
+}
+`
+	got := applyAround(t, src, "Split", &Around{
+		End: `{{if .Err}}if {{.Err}} != nil { record({{.Results}}) }{{end}}`,
+	})
+	for _, want := range []string{
+		"func Split(s string) (head string, whatapRet11 int, err error) {",
+		"if err != nil {\n\t\t\trecord(head, whatapRet11, err)\n\t\t}",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("want %q in:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "recover()") {
+		t.Errorf("recover emitted without .Panic:\n%s", got)
+	}
+	typeCheck(t, got)
+}
+
+// TestAround_PanicFieldNotText decides on recover from the template's fields:
+// the word Panic in a comment or a string does not count, $.Panic inside a
+// with body does.
+func TestAround_PanicFieldNotText(t *testing.T) {
+	src := `package p
+
+func record(args ...any) {}
+
+func Load(path string) error {
+	return nil
+}
+`
+	got := applyAround(t, src, "Load", &Around{
+		End: `{{/* .Panic is not needed */}}record("on .Panic", {{.Err}})`,
+	})
+	if strings.Contains(got, "recover()") {
+		t.Errorf("recover emitted for .Panic in text only:\n%s", got)
+	}
+	typeCheck(t, got)
+
+	got = applyAround(t, src, "Load", &Around{
+		End: `{{with .Err}}record({{.}}, {{$.Panic}}){{end}}`,
+	})
+	if !strings.Contains(got, "whatapPanic := recover()\n\t\trecord(whatapErr, whatapPanic)") {
+		t.Errorf("recover missing for $.Panic:\n%s", got)
+	}
+	typeCheck(t, got)
+}
+
+// TestAround_NoResults leaves .Err and .Results empty.
+func TestAround_NoResults(t *testing.T) {
+	src := `package p
+
+func record(args ...any) {}
+
+func Run() {}
+`
+	got := applyAround(t, src, "Run", &Around{
+		End: `record({{quote .Results}}{{if .Err}}, {{.Err}}{{end}})`,
+	})
+	if !strings.Contains(got, "func Run() {\n\tdefer func() {\n\t\trecord(\"\")\n\t}()\n}") {
+		t.Errorf("unexpected output:\n%s", got)
+	}
+	typeCheck(t, got)
+}
+
+func TestBuildRules_Around(t *testing.T) {
+	cfg := &RulesConfig{Rules: []RuleSpec{{
+		Type: "around", Target: "decl:myapp/store.Store.Get",
+		End: `if {{.Err}} != nil { trace.Fail({{.Err}}) }`, Imports: []string{"myapp/trace"},
+	}}}
+	rules, err := BuildRules(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a, ok := rules[0].Advice.(*Around); !ok || a.WhatapImportPath() != "myapp/trace" {
+		t.Fatalf("advice = %#v", rules[0].Advice)
+	}
+
+	for _, tc := range []struct {
+		spec    RuleSpec
+		wantErr string
+	}{
+		{RuleSpec{Type: "around", Target: "myapp/store.Get", End: `x()`}, `must start with "decl:"`},
+		{RuleSpec{Type: "around", Target: "decl:myapp/store.Get", Start: `x()`}, `requires "end"`},
+		{RuleSpec{Type: "around", Target: "decl:myapp/store.Get", End: `f({{.Error}})`}, "unknown field {{.Error}}"},
+	} {
+		_, err := BuildRules(&RulesConfig{Rules: []RuleSpec{tc.spec}})
+		if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("%+v: err = %v, want %q", tc.spec, err, tc.wantErr)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package ast
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dave/dst"
+)
+
+// fieldAssignContext builds a MatchContext for the first `x.F = v` statement
+// in fn, mirroring what Engine.buildContext produces for an AssignStmt match.
+func fieldAssignContext(t *testing.T, file *dst.File, fn *dst.FuncDecl) *MatchContext {
+	t.Helper()
+	for i, stmt := range fn.Body.List {
+		assign, ok := stmt.(*dst.AssignStmt)
+		if !ok {
+			continue
+		}
+		sel, ok := assign.Lhs[0].(*dst.SelectorExpr)
+		if !ok {
+			continue
+		}
+		return &MatchContext{
+			File: file, Mode: ModeInject, Assign: assign, Sel: sel,
+			FuncName: sel.Sel.Name, EnclosingFunc: fn, EnclosingStmt: stmt,
+			ParentBlock: &fn.Body.List, StmtIndex: i,
+		}
+	}
+	t.Fatal("no field assignment found")
+	return nil
+}
+
+// TestFieldAssignWrap_CtxAware verifies `c.Transport = rt` inside a handler is
+// rewritten to pass the handler's request context.
+func TestFieldAssignWrap_CtxAware(t *testing.T) {
+	src := `package p
+
+import "net/http"
+
+func handler(w http.ResponseWriter, r *http.Request) {
+	c := &http.Client{}
+	c.Transport = myRT
+	_ = c
+}
+`
+	file := parseTestFile(t, src)
+	fn := findFuncDecl(file, "handler")
+	ctx := fieldAssignContext(t, file, fn)
+
+	adv := &FieldAssignWrap{WhatapAlias: "whataphttp", WhatapFunc: "NewRoundTrip", CtxAware: true}
+	ctx.Applied = true
+	adv.Apply(ctx)
+	if !ctx.Applied {
+		t.Fatal("FieldAssignWrap should have applied")
+	}
+	got := fileToString(t, file)
+	if !strings.Contains(got, "c.Transport = whataphttp.NewRoundTrip(r.Context(), myRT)") {
+		t.Errorf("assignment not wrapped with ctx:\n%s", got)
+	}
+
+	// Second application is a no-op (idempotent).
+	ctx.Applied = true
+	adv.Apply(ctx)
+	if ctx.Applied {
+		t.Error("already-wrapped value should not be wrapped again")
+	}
+}
+
+// TestFieldAssignWrap_NoCtx verifies the plain form used by ReverseProxy.
+func TestFieldAssignWrap_NoCtx(t *testing.T) {
+	src := `package p
+
+func setup() {
+	proxy.Transport = rt
+}
+`
+	file := parseTestFile(t, src)
+	fn := findFuncDecl(file, "setup")
+	ctx := fieldAssignContext(t, file, fn)
+
+	adv := &FieldAssignWrap{WhatapAlias: "whataphttp", WhatapFunc: "WrapProxyTransport"}
+	ctx.Applied = true
+	adv.Apply(ctx)
+	if !ctx.Applied {
+		t.Fatal("FieldAssignWrap should have applied")
+	}
+	got := fileToString(t, file)
+	if !strings.Contains(got, "proxy.Transport = whataphttp.WrapProxyTransport(rt)") {
+		t.Errorf("assignment not wrapped:\n%s", got)
+	}
+}
+
+// TestResolveAssignTarget_Shapes verifies that only single `x.F = v` forms
+// are considered; without go/types nothing resolves (safe direction).
+func TestResolveAssignTarget_Shapes(t *testing.T) {
+	src := `package p
+
+func f() {
+	c.Transport = rt
+	a.T, b = x, y
+	n += 1
+}
+`
+	file := parseTestFile(t, src)
+	fn := findFuncDecl(file, "f")
+	for _, stmt := range fn.Body.List {
+		if got := resolveAssignTarget(stmt.(*dst.AssignStmt)); got != "" {
+			t.Errorf("resolveAssignTarget without type info = %q, want empty", got)
+		}
+	}
+}
+
+// TestBuildRule_FieldAssignWrap verifies the yaml type and its target check.
+func TestBuildRule_FieldAssignWrap(t *testing.T) {
+	cfg := &RulesConfig{ImportAliases: map[string]string{
+		"whataphttp": "github.com/whatap/go-api/instrumentation/net/http/whataphttp",
+	}}
+	r, err := buildRule(cfg, &RuleSpec{
+		Type: "field-assign-wrap", Target: "net/http.Client.Transport=",
+		With: "whataphttp.NewRoundTrip", CtxAware: true,
+	})
+	if err != nil {
+		t.Fatalf("buildRule: %v", err)
+	}
+	adv, ok := r.Advice.(*FieldAssignWrap)
+	if !ok || adv.WhatapFunc != "NewRoundTrip" || !adv.CtxAware {
+		t.Errorf("unexpected advice %+v", r.Advice)
+	}
+
+	if _, err := buildRule(cfg, &RuleSpec{
+		Type: "field-assign-wrap", Target: "net/http.Client.Transport",
+		With: "whataphttp.NewRoundTrip",
+	}); err == nil {
+		t.Error("target without trailing '=' should be rejected")
+	}
+}
@@ -0,0 +1,398 @@
+package ast
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dave/dst"
+)
+
+// applyLoopBody matches the first call to funcName inside fn and applies a
+// LoopBody advice to it, returning the rewritten file text.
+func applyLoopBody(t *testing.T, src, funcName string) (string, bool) {
+	t.Helper()
+	file := parseTestFile(t, src)
+	fn := findFuncDecl(file, "f")
+	var call *dst.CallExpr
+	dst.Inspect(fn, func(n dst.Node) bool {
+		if c, ok := n.(*dst.CallExpr); ok && call == nil {
+			if sel, ok := c.Fun.(*dst.SelectorExpr); ok && sel.Sel.Name == funcName {
+				call = c
+			}
+		}
+		return call == nil
+	})
+	if call == nil {
+		t.Fatalf("no call to %s", funcName)
+	}
+	ctx := &MatchContext{
+		File: file, Mode: ModeInject, Call: call, Sel: call.Fun.(*dst.SelectorExpr),
+		FuncName: funcName, EnclosingFunc: fn, EnclosingStmt: fn.Body.List[0],
+		ParentBlock: &fn.Body.List, StmtIndex: 0, Applied: true,
+	}
+	adv := &LoopBody{WhatapPkg: "example.com/whatapq", WhatapAlias: "whatapq", StartFunc: "StartConsume", EndFunc: "EndConsume"}
+	adv.Apply(ctx)
+	return fileToString(t, file), ctx.Applied
+}
+
+func TestLoopBody_RangeUsesDeferClosure(t *testing.T) {
+	src := `package p
+
+func f() {
+	go func() {
+		for msg := range claim.Messages() {
+			handle(msg)
+			sess.MarkMessage(msg, "")
+		}
+	}()
+}
+`
+	got, applied := applyLoopBody(t, src, "Messages")
+	if !applied {
+		t.Fatal("not applied")
+	}
+	want := `		for msg := range claim.Messages() {
+			func() {
+				defer whatapq.EndConsume(whatapq.StartConsume(msg))
+				handle(msg)
+				sess.MarkMessage(msg, "")
+			}()
+		}`
+	if !strings.Contains(got, want) {
+		t.Errorf("want:\n%s\nin:\n%s", want, got)
+	}
+}
+
+func TestLoopBody_ExplicitEndBeforeExits(t *testing.T) {
+	src := `package p
+
+func f() error {
+	for {
+		m, err := r.ReadMessage(ctx)
+		if err != nil {
+			break
+		}
+		for _, h := range m.Headers {
+			if h.Key == "skip" {
+				continue
+			}
+		}
+		if m.Key == nil {
+			continue
+		}
+		if bad(m) {
+			return errBad
+		}
+		handle(m)
+	}
+	return nil
+}
+`
+	got, applied := applyLoopBody(t, src, "ReadMessage")
+	if !applied {
+		t.Fatal("not applied")
+	}
+	for _, want := range []string{
+		"m, err := r.ReadMessage(ctx)\n\t\twhatapLoopTx := whatapq.StartConsume(m, err)\n",
+		"if err != nil {\n\t\t\twhatapq.EndConsume(whatapLoopTx)\n\t\t\tbreak\n",
+		// continue of the inner range stays in the iteration — no End there.
+		"if h.Key == \"skip\" {\n\t\t\t\tcontinue\n",
+		"if m.Key == nil {\n\t\t\twhatapq.EndConsume(whatapLoopTx)\n\t\t\tcontinue\n",
+		"whatapq.EndConsume(whatapLoopTx)\n\t\t\treturn errBad\n",
+		"handle(m)\n\t\twhatapq.EndConsume(whatapLoopTx)\n\t}\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("want %q in:\n%s", want, got)
+		}
+	}
+}
+
+func TestLoopBody_BlankAndIdempotent(t *testing.T) {
+	src := `package p
+
+func f() {
+	for {
+		msg, _ := sub.NextMsg(timeout)
+		handle(msg)
+	}
+}
+`
+	file := parseTestFile(t, src)
+	fn := findFuncDecl(file, "f")
+	call := findFirstCall(file)
+	adv := &LoopBody{WhatapPkg: "example.com/whatapq", WhatapAlias: "whatapq", StartFunc: "StartConsume", EndFunc: "EndConsume"}
+	for i := 0; i < 2; i++ {
+		ctx := &MatchContext{File: file, Mode: ModeInject, Call: call, EnclosingFunc: fn, Applied: true}
+		adv.Apply(ctx)
+		if ctx.Applied != (i == 0) {
+			t.Fatalf("pass %d: Applied=%v", i, ctx.Applied)
+		}
+	}
+	got := fileToString(t, file)
+	want := "msg, whatapRecv1 := sub.NextMsg(timeout)\n\t\tfunc() {\n\t\t\tdefer whatapq.EndConsume(whatapq.StartConsume(msg, whatapRecv1))"
+	if !strings.Contains(got, want) {
+		t.Errorf("want %q in:\n%s", want, got)
+	}
+}
+
+func TestLoopBody_BlankPlainAssignSkipped(t *testing.T) {
+	src := `package p
+
+func f() {
+	var msg *nats.Msg
+	for {
+		msg, _ = sub.NextMsg(timeout)
+		handle(msg)
+	}
+}
+`
+	if _, applied := applyLoopBody(t, src, "NextMsg"); applied {
+		t.Error("blank under = cannot be passed to StartFunc; loop must be skipped")
+	}
+}
+
+func TestBuildRules_LoopBody(t *testing.T) {
+	cfg := &RulesConfig{
+		ImportAliases: map[string]string{"whatapq": "example.com/whatapq", "other": "example.com/other"},
+		Rules: []RuleSpec{{
+			Type: "loop-body", Target: "example.com/q.Reader.Read",
+			Start: "whatapq.Begin", End: "whatapq.Finish",
+		}},
+	}
+	rules, err := BuildRules(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &LoopBody{WhatapPkg: "example.com/whatapq", WhatapAlias: "whatapq", StartFunc: "Begin", EndFunc: "Finish"}
+	if got, ok := rules[0].Advice.(*LoopBody); !ok || *got != *want {
+		t.Errorf("advice = %#v, want %#v", rules[0].Advice, want)
+	}
+
+	cfg.Rules[0].End = "other.Finish"
+	if _, err := BuildRules(cfg); err == nil || !strings.Contains(err.Error(), "same alias") {
+		t.Errorf("mismatched aliases: err = %v", err)
+	}
+}
+
+// TestLoopBody_EngineKeepsStatementAdvice runs LoopBody through the engine
+// together with a Hook on a call inside the loop body: the body moves into a
+// func literal, and the Hook must still insert exactly once, inside it.
+func TestLoopBody_EngineKeepsStatementAdvice(t *testing.T) {
+	reg := NewRegistry()
+	reg.Register(&Rule{Target: "q.Claim.Messages", Advice: &LoopBody{
+		WhatapPkg: "example.com/whatapq", WhatapAlias: "whatapq",
+		StartFunc: "StartConsume", EndFunc: "EndConsume",
+	}})
+	reg.Register(&Rule{Target: "audit.Log", Advice: &Hook{Before: `audit.Begin()`}})
+	resolve := func(n dst.Node) string {
+		call, ok := n.(*dst.CallExpr)
+		if !ok {
+			return ""
+		}
+		sel, ok := call.Fun.(*dst.SelectorExpr)
+		if !ok {
+			return ""
+		}
+		if x, ok := sel.X.(*dst.Ident); ok {
+			switch x.Name + "." + sel.Sel.Name {
+			case "claim.Messages":
+				return "q.Claim.Messages"
+			case "audit.Log":
+				return "audit.Log"
+			}
+		}
+		return ""
+	}
+	src := `package p
+
+func f() {
+	for msg := range claim.Messages() {
+		audit.Log(msg)
+	}
+}
+`
+	file := parseTestFile(t, src)
+	NewEngine(reg, ModeInject, resolve).Process(file)
+	got := fileToString(t, file)
+	want := "func() {\n\t\t\tdefer whatapq.EndConsume(whatapq.StartConsume(msg))\n\t\t\taudit.Begin()\n\t\t\taudit.Log(msg)\n\t\t}()"
+	if !strings.Contains(got, want) {
+		t.Errorf("want %q in:\n%s", want, got)
+	}
+	if n := strings.Count(got, "audit.Begin()"); n != 1 {
+		t.Errorf("Hook inserted %d times, want 1:\n%s", n, got)
+	}
+}
+
+// TestLoopBody_NestedLoopsUniqueTx runs the built-in rules over a sarama
+// claim loop that reads from a kafka-go reader per message. Both loops need
+// the explicit form; the inner transaction gets its own variable so the
+// outer loop's End calls still end the outer one.
+func TestLoopBody_NestedLoopsUniqueTx(t *testing.T) {
+	src := `package app
+
+import (
+	"context"
+
+	"github.com/IBM/sarama"
+	"github.com/segmentio/kafka-go"
+)
+
+func consume(ctx context.Context, claim sarama.ConsumerGroupClaim, r *kafka.Reader) error {
+	for msg := range claim.Messages() {
+		if msg == nil {
+			return nil
+		}
+		for {
+			m, err := r.ReadMessage(ctx)
+			if err != nil {
+				break
+			}
+			if len(m.Key) == 0 {
+				return nil
+			}
+		}
+	}
+	return nil
+}
+`
+	file := decorateWithStubs(t, src, map[string]string{
+		"github.com/IBM/sarama": `package sarama
+
+type ConsumerMessage struct{}
+
+type ConsumerGroupClaim interface {
+	Messages() <-chan *ConsumerMessage
+}
+`,
+		"github.com/segmentio/kafka-go": `package kafka
+
+import "context"
+
+type Message struct{ Key []byte }
+type Reader struct{}
+
+func (r *Reader) ReadMessage(ctx context.Context) (Message, error) { return Message{}, nil }
+`,
+	})
+	if !processBuiltin(file) {
+		t.Fatal("expected a transformation")
+	}
+	got := fileToString(t, file)
+	outer, inner := "whatapLoopTx", "whatapLoopTx1"
+	if strings.Contains(got, "whatapLoopTx1 := whatapsarama.") {
+		// Inner loop applied first — names swap, the pairing must still hold.
+		outer, inner = inner, outer
+	}
+	for _, want := range []string{
+		outer + " := whatapsarama.",
+		inner + " := whatapkafka.",
+		"if msg == nil {\n\t\t\twhatapsarama.EndConsume(" + outer + ")\n\t\t\treturn nil",
+		"if err != nil {\n\t\t\t\twhatapkafka.EndConsume(" + inner + ")\n\t\t\t\tbreak",
+		"if len(m.Key) == 0 {\n\t\t\t\twhatapsarama.EndConsume(" + outer + ")\n\t\t\t\twhatapkafka.EndConsume(" + inner + ")\n\t\t\t\treturn nil",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("want %q in:\n%s", want, got)
+		}
+	}
+}
+
+// TestEngine_UserFuncLitNotNested — only literals LoopBody created are
+// processed as nested blocks. A user's func() { ... }() is not a block of
+// the enclosing function, so statement-level Advice leaves it alone.
+func TestEngine_UserFuncLitNotNested(t *testing.T) {
+	reg := NewRegistry()
+	reg.Register(&Rule{Target: "audit.Log", Advice: &Hook{Before: `audit.Begin()`}})
+	resolve := func(n dst.Node) string {
+		if call, ok := n.(*dst.CallExpr); ok {
+			if sel, ok := call.Fun.(*dst.SelectorExpr); ok && sel.Sel.Name == "Log" {
+				return "audit.Log"
+			}
+		}
+		return ""
+	}
+	src := `package p
+
+func f() {
+	func() {
+		audit.Log("x")
+	}()
+}
+`
+	file := parseTestFile(t, src)
+	NewEngine(reg, ModeInject, resolve).Process(file)
+	if got := fileToString(t, file); strings.Contains(got, "audit.Begin()") {
+		t.Errorf("Hook applied inside a user func literal:\n%s", got)
+	}
+}
+
+// TestLoopBody_NATSChanRange runs the built-in rules over a type-checked
+// ChanSubscribe consumer: the range over `chan *nats.Msg` is the loop anchor,
+// while ranges over other channels are left alone.
+func TestLoopBody_NATSChanRange(t *testing.T) {
+	src := `package app
+
+import "github.com/nats-io/nats.go"
+
+func consume(nc *nats.Conn, names chan string) {
+	ch := make(chan *nats.Msg, 64)
+	nc.ChanSubscribe("orders", ch)
+	go func() {
+		for m := range ch {
+			handle(m.Data)
+		}
+	}()
+	for n := range names {
+		handle([]byte(n))
+	}
+}
+
+func handle(b []byte) {}
+`
+	file := decorateWithStubs(t, src, map[string]string{"github.com/nats-io/nats.go": `package nats
+
+type Conn struct{}
+type Subscription struct{}
+type Msg struct{ Data []byte }
+
+func (nc *Conn) ChanSubscribe(subj string, ch chan *Msg) (*Subscription, error) { return nil, nil }
+`})
+	if !processBuiltin(file) {
+		t.Fatal("expected a transformation")
+	}
+	got := fileToString(t, file)
+	for _, want := range []string{
+		"for m := range ch {\n\t\t\tfunc() {\n\t\t\t\tdefer whatapnats.EndConsume(whatapnats.StartChanConsume(m))\n\t\t\t\thandle(m.Data)\n",
+		"for n := range names {\n\t\thandle([]byte(n))\n\t}",
+		`"github.com/whatap/go-api/instrumentation/github.com/nats-io/nats.go/whatapnats"`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("want %q in:\n%s", want, got)
+		}
+	}
+	if n := strings.Count(got, "StartChanConsume"); n != 1 {
+		t.Errorf("StartChanConsume inserted %d times, want 1:\n%s", n, got)
+	}
+}
+
+func TestBuildRules_RangeTarget(t *testing.T) {
+	cfg := &RulesConfig{
+		ImportAliases: map[string]string{"whatapq": "example.com/whatapq"},
+		Rules: []RuleSpec{{
+			Type: "loop-body", Target: "range:example.com/q.Msg",
+			Start: "whatapq.Begin", End: "whatapq.Finish",
+		}},
+	}
+	if _, err := BuildRules(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg.Rules[0].Target = "range:example.com/q.*"
+	if _, err := BuildRules(cfg); err == nil || !strings.Contains(err.Error(), "do not take patterns") {
+		t.Errorf("pattern range target: err = %v", err)
+	}
+
+	cfg.Rules[0] = RuleSpec{Type: "wrap-call", Target: "range:example.com/q.Msg", With: "whatapq.Wrap"}
+	if _, err := BuildRules(cfg); err == nil || !strings.Contains(err.Error(), "use loop-body") {
+		t.Errorf("wrap-call on range target: err = %v", err)
+	}
+}
@@ -0,0 +1,120 @@
+package ast
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestEngine_ValueWrap wraps functions and methods referenced as values —
+// plain and qualified function values, method values and method
+// expressions, in function bodies and at package level — and leaves calls,
+// func-typed variables and generic references alone.
+func TestEngine_ValueWrap(t *testing.T) {
+	src := `package store
+
+import "strings"
+
+type Handler struct {
+	cb func() string
+}
+
+func (h *Handler) Get() string { return "" }
+
+func Open(name string) error { return nil }
+
+func Map[T any](v T) T { return v }
+
+var defaultOpener = Open
+
+func register(path string, f func() string) {}
+
+func apply(f func(string) string) {}
+
+func use(h *Handler, fnVar func(string) error) {
+	opener := Open
+	_ = Open("direct")
+	register("/", h.Get)
+	get := (*Handler).Get
+	h.Get()
+	if h != nil {
+		apply(strings.ToUpper)
+	}
+	f := fnVar
+	register("/cb", h.cb)
+	m := Map[int]
+	_, _, _, _ = opener, get, f, m
+}
+`
+	file := decorateTyped(t, src)
+	cfg := &RulesConfig{
+		ImportAliases: map[string]string{"wtrace": "example.com/trace"},
+		Rules: []RuleSpec{
+			{Type: "value-wrap", Target: "value:example.com/store.Open", With: "wtrace.WrapOpen"},
+			{Type: "value-wrap", Target: "value:example.com/store.Handler.*", With: "wtrace.Wrap"},
+			{Type: "value-wrap", Target: "value:strings.ToUpper", With: "wtrace.WrapString"},
+			// A call-site glob must not reach value references.
+			{Type: "hook", Target: "**.Get", Before: `println("call")`},
+		},
+	}
+	rules, err := BuildRules(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg := NewRegistry()
+	for _, r := range rules {
+		reg.RegisterUser(r)
+	}
+	NewEngine(reg, ModeInject, resolveTarget).Process(file)
+	got := fileToString(t, file)
+
+	for _, want := range []string{
+		"var defaultOpener = wtrace.WrapOpen(Open)",
+		"opener := wtrace.WrapOpen(Open)",
+		"_ = Open(\"direct\")",
+		"register(\"/\", wtrace.Wrap(h.Get))",
+		"get := wtrace.Wrap((*Handler).Get)",
+		"println(\"call\")\n\th.Get()",
+		"\t\tapply(wtrace.WrapString(strings.ToUpper))\n",
+		"f := fnVar",
+		"register(\"/cb\", h.cb)",
+		"m := Map[int]",
+		"\"example.com/trace\"",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("want %q in:\n%s", want, got)
+		}
+	}
+	if n := strings.Count(got, "wtrace."); n != 5 {
+		t.Errorf("got %d wrappers, want 5:\n%s", n, got)
+	}
+}
+
+func TestBuildRules_ValueTargets(t *testing.T) {
+	aliases := map[string]string{"wtrace": "example.com/trace"}
+	rules, err := BuildRules(&RulesConfig{ImportAliases: aliases, Rules: []RuleSpec{
+		{Type: "value-wrap", Target: "value:database/sql.Open", With: "wtrace.WrapOpen"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a, ok := rules[0].Advice.(*ValueWrap); !ok || a.WhatapPkg != "example.com/trace" || a.WhatapFunc != "WrapOpen" {
+		t.Fatalf("advice = %#v", rules[0].Advice)
+	}
+	if pkg := ExtractRulePackage(rules[0].Target); pkg != "database/sql" {
+		t.Errorf("ExtractRulePackage = %q", pkg)
+	}
+
+	for _, tc := range []struct {
+		spec    RuleSpec
+		wantErr string
+	}{
+		{RuleSpec{Type: "value-wrap", Target: "database/sql.Open", With: "wtrace.WrapOpen"}, `must start with "value:"`},
+		{RuleSpec{Type: "hook", Target: "value:database/sql.Open", Before: "x()"}, "does not accept a value: target"},
+		{RuleSpec{Type: "value-wrap", Target: "value:database/sql.Open", With: "nope.WrapOpen"}, "unknown importAlias"},
+	} {
+		_, err := BuildRules(&RulesConfig{ImportAliases: aliases, Rules: []RuleSpec{tc.spec}})
+		if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("%+v: err = %v, want %q", tc.spec, err, tc.wantErr)
+		}
+	}
+}
@@ -104,6 +104,7 @@ func TestDefaultPackageName(t *testing.T) {
 		{"github.com/gin-gonic/gin", "gin"},
 		{"github.com/redis/go-redis/v9", "redis"},
 		{"github.com/go-redis/redis/v8", "redis"},
+		{"github.com/elastic/go-elasticsearch/v8", "elasticsearch"},
 		{"gorm.io/gorm", "gorm"},
 		{"database/sql", "sql"},
 		{"fmt", "fmt"},
@@ -25,6 +25,11 @@ import (
 type typeContextData struct {
 	typesInfo *types.Info
 	nodeMap   map[dst.Node]ast.Node // dst → ast node mapping from decorator
+	fset      *token.FileSet        // positions for nodeMap entries (NodePosition)
+
+	// pkgs indexes the packages reachable from typesInfo by import path.
+	// Built on first LookupNamedType call, dropped with the type context.
+	pkgs map[string]*types.Package
 }
 
 var typeCtx typeContextData
@@ -40,15 +45,39 @@ var currentImportPath string
 func SetTypeContext(typesInfo *types.Info, nodeMap map[dst.Node]ast.Node) {
 	typeCtx.typesInfo = typesInfo
 	typeCtx.nodeMap = nodeMap
+	typeCtx.pkgs = nil
+}
+
+// SetTypeFileSet records the FileSet the current nodeMap was decorated from,
+// so NodePosition can report source positions. Set alongside SetTypeContext.
+func SetTypeFileSet(fset *token.FileSet) {
+	typeCtx.fset = fset
 }
 
 // ClearTypeContext clears the type context after processing a file.
 func ClearTypeContext() {
 	typeCtx.typesInfo = nil
 	typeCtx.nodeMap = nil
+	typeCtx.fset = nil
+	typeCtx.pkgs = nil
 	currentImportPath = ""
 }
 
+// NodePosition returns the original source position of a dst node.
+// ok is false when no FileSet/node mapping is available or the node was
+// created by a transformation (it has no ast counterpart).
+func NodePosition(n dst.Node) (pos token.Position, ok bool) {
+	if n == nil || typeCtx.fset == nil || typeCtx.nodeMap == nil {
+		return token.Position{}, false
+	}
+	astNode, found := typeCtx.nodeMap[n]
+	if !found || astNode == nil {
+		return token.Position{}, false
+	}
+	pos = typeCtx.fset.Position(astNode.Pos())
+	return pos, pos.IsValid()
+}
+
 // SetCurrentImportPath records the import path of the package currently being processed.
 func SetCurrentImportPath(p string) {
 	currentImportPath = p
@@ -129,6 +158,26 @@ func GetIdentFuncTarget(ident *dst.Ident) string {
 	return pkg.Path() + "." + fn.Name()
 }
 
+// IsFuncRef reports whether ident refers to a declared function or method
+// that can be used as a value as written — generic functions are excluded,
+// since a reference to one only compiles with its instantiation around it.
+// Returns false for variables of func type, builtins and without type info.
+func IsFuncRef(ident *dst.Ident) bool {
+	if ident == nil || typeCtx.typesInfo == nil || typeCtx.nodeMap == nil {
+		return false
+	}
+	astIdent, ok := typeCtx.nodeMap[ident].(*ast.Ident)
+	if !ok {
+		return false
+	}
+	fn, ok := typeCtx.typesInfo.Uses[astIdent].(*types.Func)
+	if !ok || fn.Pkg() == nil {
+		return false
+	}
+	sig, ok := fn.Type().(*types.Signature)
+	return ok && sig.TypeParams().Len() == 0
+}
+
 // ResolveType returns the actual Go type of a dst expression.
 // Uses dst→ast mapping and go/types to resolve.
 // Returns nil if type info is not available or resolution fails.
@@ -176,6 +225,45 @@ func ResolveType(expr dst.Expr) types.Type {
 	return nil
 }
 
+// LookupNamedType returns the type declared as pkgPath.name in the package
+// being processed or in any package it imports, directly or indirectly.
+// Returns nil when type info is unavailable or the package is not part of the
+// current package's import graph — go/types only knows the packages the
+// checker actually loaded.
+func LookupNamedType(pkgPath, name string) types.Type {
+	if typeCtx.typesInfo == nil {
+		return nil
+	}
+	if typeCtx.pkgs == nil {
+		typeCtx.pkgs = make(map[string]*types.Package)
+		var add func(p *types.Package)
+		add = func(p *types.Package) {
+			if p == nil || typeCtx.pkgs[p.Path()] != nil {
+				return
+			}
+			typeCtx.pkgs[p.Path()] = p
+			for _, imp := range p.Imports() {
+				add(imp)
+			}
+		}
+		// Info has no package pointer; every package the file touches shows
+		// up as the Pkg of some used object, and the current package's
+		// Imports() covers the rest of the graph.
+		for _, obj := range typeCtx.typesInfo.Uses {
+			add(obj.Pkg())
+		}
+	}
+	pkg := typeCtx.pkgs[pkgPath]
+	if pkg == nil {
+		return nil
+	}
+	tn, ok := pkg.Scope().Lookup(name).(*types.TypeName)
+	if !ok {
+		return nil
+	}
+	return tn.Type()
+}
+
 // IsReceiverOfType checks if the expression's resolved type matches pkgPath and typeName.
 // Automatically dereferences pointer types (e.g., *http.ServeMux → http.ServeMux).
 // Returns false if type info is not available or type doesn't match.
@@ -214,6 +302,32 @@ func NamedTypeOf(expr dst.Expr) (pkgPath, typeName string, ok bool) {
 	if t == nil {
 		return "", "", false
 	}
+	return namedTypeName(t)
+}
+
+// ChanElemTypeOf resolves expr's type as a channel that can be received from
+// (chan T or <-chan T, including named channel types) and returns its element
+// type's path and name the way NamedTypeOf does: `ch` of type
+// `chan *nats.Msg` → ("github.com/nats-io/nats.go", "Msg").
+// Returns ok=false for non-channels, send-only channels and unnamed elements.
+func ChanElemTypeOf(expr dst.Expr) (pkgPath, typeName string, ok bool) {
+	if !HasTypeInfo() {
+		return "", "", false
+	}
+	t := ResolveType(expr)
+	if t == nil {
+		return "", "", false
+	}
+	ch, isChan := t.Underlying().(*types.Chan)
+	if !isChan || ch.Dir() == types.SendOnly {
+		return "", "", false
+	}
+	return namedTypeName(ch.Elem())
+}
+
+// namedTypeName is the shared tail of NamedTypeOf and ChanElemTypeOf:
+// dereference one pointer and report the origin named type.
+func namedTypeName(t types.Type) (pkgPath, typeName string, ok bool) {
 	// Dereference pointer
 	if ptr, isPtr := t.(*types.Pointer); isPtr {
 		t = ptr.Elem()
@@ -229,6 +343,77 @@ func NamedTypeOf(expr dst.Expr) (pkgPath, typeName string, ok bool) {
 	return pkg.Path(), named.Obj().Name(), true
 }
 
+// InstanceTypeArgs returns the type arguments of a generic function or type
+// named by ident — the identifier itself or the Sel of pkg.Name — from the
+// go/types Instances map. Covers explicit (cache.Get[User](k)) and inferred
+// (cache.Get(k, u)) instantiation. nil when ident is not an instantiation or
+// type info is unavailable.
+func InstanceTypeArgs(ident *dst.Ident) []types.Type {
+	if ident == nil || typeCtx.typesInfo == nil || typeCtx.nodeMap == nil {
+		return nil
+	}
+	astIdent, ok := typeCtx.nodeMap[ident].(*ast.Ident)
+	if !ok {
+		return nil
+	}
+	return typeListSlice(typeCtx.typesInfo.Instances[astIdent].TypeArgs)
+}
+
+// NamedTypeArgs returns the type arguments of expr's named type, pointers
+// dereferenced — e.g. [User] for a receiver of type *repo.Repo[User]. This is
+// the information NamedTypeOf drops. nil for non-generic types.
+func NamedTypeArgs(expr dst.Expr) []types.Type {
+	if !HasTypeInfo() {
+		return nil
+	}
+	t := ResolveType(expr)
+	if ptr, isPtr := t.(*types.Pointer); isPtr {
+		t = ptr.Elem()
+	}
+	named, isNamed := t.(*types.Named)
+	if !isNamed {
+		return nil
+	}
+	return typeListSlice(named.TypeArgs())
+}
+
+func typeListSlice(list *types.TypeList) []types.Type {
+	if list.Len() == 0 {
+		return nil
+	}
+	out := make([]types.Type, list.Len())
+	for i := range out {
+		out[i] = list.At(i)
+	}
+	return out
+}
+
+// FieldOwnerOf resolves a field selector x.F and returns the package path and
+// type name of x's named type (pointers dereferenced). ok=false unless go/types
+// reports F as a struct field (*types.Var with IsField) — method values and
+// package-qualified identifiers never match.
+//
+// The owner is x's static type, not the struct that declares F: for
+// `type myClient struct{ *http.Client }`, `c.Transport` resolves to myClient.
+func FieldOwnerOf(sel *dst.SelectorExpr) (pkgPath, typeName string, ok bool) {
+	if sel == nil || !HasTypeInfo() {
+		return "", "", false
+	}
+	astNode, found := typeCtx.nodeMap[sel.Sel]
+	if !found {
+		return "", "", false
+	}
+	astIdent, isIdent := astNode.(*ast.Ident)
+	if !isIdent {
+		return "", "", false
+	}
+	v, isVar := typeCtx.typesInfo.Uses[astIdent].(*types.Var)
+	if !isVar || !v.IsField() {
+		return "", "", false
+	}
+	return NamedTypeOf(sel.X)
+}
+
 // TrySetupTypeContext tries to load type info and set up the type context.
 // Returns the decorated dst.File if successful, nil otherwise (caller should fallback).
 //
@@ -283,6 +468,7 @@ func TrySetupTypeContext(tc *TypeChecker, srcPath string) *dst.File {
 				return nil
 			}
 			SetTypeContext(pkg.TypesInfo, dec.Ast.Nodes)
+			SetTypeFileSet(pkg.Fset)
 			SetCurrentImportPath(pkg.PkgPath)
 			return dstFile
 		}
@@ -407,9 +593,10 @@ func SetupImportcfgTypeInfo(importcfgEntries map[string]string, goFiles []string
 
 	// Type-check with importer.ForCompiler (reads .a files, no go list, no nested build)
 	typesInfo := &types.Info{
-		Types:  make(map[ast.Expr]types.TypeAndValue),
-		Uses:   make(map[*ast.Ident]types.Object),
-		Scopes: make(map[ast.Node]*types.Scope),
+		Types:     make(map[ast.Expr]types.TypeAndValue),
+		Uses:      make(map[*ast.Ident]types.Object),
+		Scopes:    make(map[ast.Node]*types.Scope),
+		Instances: make(map[*ast.Ident]types.Instance),
 	}
 
 	cfg := &types.Config{
@@ -471,6 +658,7 @@ func TrySetupTypeContextFromImportcfg(srcPath string) *dst.File {
 	}
 
 	SetTypeContext(importcfgTypeCache.typesInfo, dec.Ast.Nodes)
+	SetTypeFileSet(importcfgTypeCache.fset)
 	SetCurrentImportPath(importcfgTypeCache.importPath)
 	return dstFile
 }
@@ -509,6 +697,7 @@ func TrySetupDecoratorContextFromImportcfg(srcPath string) *dst.File {
 
 	// Also set go/types context for method call resolution (ResolveType)
 	SetTypeContext(importcfgTypeCache.typesInfo, dec.Ast.Nodes)
+	SetTypeFileSet(importcfgTypeCache.fset)
 	SetCurrentImportPath(importcfgTypeCache.importPath)
 	return dstFile
 }
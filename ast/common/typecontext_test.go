@@ -683,3 +683,65 @@ func TestIsReceiverOfType_FallbackSafety(t *testing.T) {
 		t.Error("§164: IsReceiverOfType without type info should return false (safe fallback)")
 	}
 }
+
+func TestFieldOwnerOf_NoTypeInfo(t *testing.T) {
+	ClearTypeContext()
+	sel := &dst.SelectorExpr{X: dst.NewIdent("c"), Sel: dst.NewIdent("Transport")}
+	if _, _, ok := FieldOwnerOf(sel); ok {
+		t.Error("FieldOwnerOf should return ok=false without type info")
+	}
+	if _, _, ok := FieldOwnerOf(nil); ok {
+		t.Error("FieldOwnerOf(nil) should return ok=false")
+	}
+}
+
+// TestFieldOwnerOf_FieldVsMethod verifies that only struct-field selectors
+// resolve — a method value on the same receiver must not.
+func TestFieldOwnerOf_FieldVsMethod(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte("module fo\n\ngo 1.21\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	src := `package main
+
+type Client struct{ Transport any }
+
+func (c *Client) Close() {}
+
+func main() {
+	c := &Client{}
+	c.Transport = nil
+	f := c.Close
+	_ = f
+}
+`
+	if err := os.WriteFile(filepath.Join(tmpDir, "main.go"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tc := NewTypeChecker()
+	file := TrySetupTypeContext(tc, filepath.Join(tmpDir, "main.go"))
+	if file == nil {
+		t.Skip("TrySetupTypeContext returned nil (packages.Load may not work in test env)")
+	}
+	defer ClearTypeContext()
+
+	dst.Inspect(file, func(n dst.Node) bool {
+		sel, ok := n.(*dst.SelectorExpr)
+		if !ok {
+			return true
+		}
+		p, nm, ok := FieldOwnerOf(sel)
+		switch sel.Sel.Name {
+		case "Transport":
+			if !ok || p != "fo" || nm != "Client" {
+				t.Errorf("FieldOwnerOf(c.Transport) = (%q, %q, %v), want (fo, Client, true)", p, nm, ok)
+			}
+		case "Close":
+			if ok {
+				t.Error("FieldOwnerOf(c.Close) should be ok=false for a method value")
+			}
+		}
+		return true
+	})
+}
@@ -0,0 +1,172 @@
+package custom
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strings"
+
+	"github.com/whatap/go-api-inst/config"
+)
+
+// Library mode (config instrumentation.library_init). Plugins
+// (-buildmode=plugin / c-shared) and libraries loaded by a non-Go host have
+// no main(), so injectMainInit never starts the agent. LibraryInitRule turns
+// the config block into an add rule whose file starts the agent from init()
+// and shuts it down on SIGINT/SIGTERM and/or through an exported cleanup
+// function — the same file a user would otherwise write by hand in `add:`.
+
+// DefaultLibraryInitFile is the generated file name when library_init.file
+// is empty.
+const DefaultLibraryInitFile = "whatap_library_init.go"
+
+// DefaultLibraryCleanup is the exported cleanup function name when
+// library_init.cleanup is empty.
+const DefaultLibraryCleanup = "WhatapShutdown"
+
+// AddRules returns cfg.Add plus the library_init rule, if configured.
+// srcDir is the project root the rule's package is resolved against; tags
+// are the build's -tags, which decide the package's files.
+func AddRules(srcDir string, cfg *config.Config, tags []string) ([]config.AddRule, error) {
+	rules := cfg.Add
+	if li := cfg.Instrumentation.LibraryInit; li != nil {
+		rule, err := LibraryInitRule(srcDir, li, tags)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(append([]config.AddRule(nil), cfg.Add...), rule)
+	}
+	return rules, nil
+}
+
+// LibraryInitRule builds the add rule for a library_init block. The package
+// name is read from the target directory's Go files; a package with a
+// non-empty main() is rejected — main() is instrumented already, and a
+// second Init would start the agent twice.
+func LibraryInitRule(srcDir string, li *config.LibraryInitConfig, tags []string) (config.AddRule, error) {
+	shutdown := li.Shutdown
+	if shutdown == "" {
+		shutdown = "signal"
+	}
+	switch shutdown {
+	case "signal", "export", "both", "none":
+	default:
+		return config.AddRule{}, fmt.Errorf("library_init: shutdown %q: want signal, export, both or none", li.Shutdown)
+	}
+	cleanup := li.Cleanup
+	if cleanup == "" {
+		cleanup = DefaultLibraryCleanup
+	}
+	if !token.IsIdentifier(cleanup) || !token.IsExported(cleanup) {
+		return config.AddRule{}, fmt.Errorf("library_init: cleanup %q is not an exported Go identifier", cleanup)
+	}
+	if li.CgoExport && shutdown != "export" && shutdown != "both" {
+		return config.AddRule{}, fmt.Errorf("library_init: cgo_export needs shutdown: export or both")
+	}
+	file := li.File
+	if file == "" {
+		file = DefaultLibraryInitFile
+	}
+	if filepath.Base(file) != file || !strings.HasSuffix(file, ".go") || strings.HasSuffix(file, "_test.go") {
+		return config.AddRule{}, fmt.Errorf("library_init: file %q must be a .go file name (not _test.go) without directories", file)
+	}
+
+	pkg := strings.TrimPrefix(li.Package, "./")
+	rule := config.AddRule{Package: pkg, File: file}
+	dir := filepath.Dir(getAddFilePath(srcDir, rule))
+	name, err := libraryPackageName(dir, tags)
+	if err != nil {
+		return config.AddRule{}, err
+	}
+	rule.Content = libraryInitSource(name, shutdown, cleanup, li.CgoExport)
+	return rule, nil
+}
+
+// libraryPackageName returns the package name of the non-test Go files in
+// dir that the build selects (build constraints such as a `//go:build
+// ignore` generator are honoured, with tags as the build's -tags), failing
+// when dir has none or when it has a non-empty main().
+func libraryPackageName(dir string, tags []string) (string, error) {
+	ctxt := build.Default
+	ctxt.BuildTags = tags
+	bp, err := ctxt.ImportDir(dir, 0)
+	if err != nil {
+		var noGo *build.NoGoError
+		if errors.As(err, &noGo) {
+			return "", fmt.Errorf("library_init: no Go files in %s", dir)
+		}
+		return "", fmt.Errorf("library_init: %v", err)
+	}
+	if bp.Name != "main" {
+		return bp.Name, nil
+	}
+	fset := token.NewFileSet()
+	for _, name := range bp.GoFiles {
+		path := filepath.Join(dir, name)
+		f, err := parser.ParseFile(fset, path, nil, parser.SkipObjectResolution)
+		if err != nil {
+			return "", fmt.Errorf("library_init: %v", err)
+		}
+		for _, decl := range f.Decls {
+			if fn, ok := decl.(*ast.FuncDecl); ok && fn.Name.Name == "main" && fn.Recv == nil && fn.Body != nil && len(fn.Body.List) > 0 {
+				return "", fmt.Errorf("library_init: %s has a main() — it is instrumented already; drop library_init or point it at a package without main()", path)
+			}
+		}
+	}
+	return bp.Name, nil
+}
+
+// libraryInitSource renders the generated file.
+func libraryInitSource(pkg, shutdown, cleanup string, cgoExport bool) string {
+	onSignal := shutdown == "signal" || shutdown == "both"
+	export := shutdown == "export" || shutdown == "both"
+
+	var b strings.Builder
+	b.WriteString("// Code generated by whatap-go-inst (instrumentation.library_init). DO NOT EDIT.\n\n")
+	b.WriteString("package " + pkg + "\n\n")
+	if cgoExport {
+		b.WriteString("import \"C\"\n\n")
+	}
+	b.WriteString("import (\n")
+	if onSignal {
+		b.WriteString("\t\"os\"\n\t\"os/signal\"\n\t\"syscall\"\n\n")
+	}
+	b.WriteString("\twhataptrace \"github.com/whatap/go-api/trace\"\n)\n\n")
+
+	b.WriteString("func init() {\n\twhataptrace.Init(nil)\n")
+	if onSignal {
+		b.WriteString("\tgo whatapShutdownOnSignal()\n")
+	}
+	b.WriteString("}\n")
+
+	if onSignal {
+		b.WriteString(`
+// whatapShutdownOnSignal flushes the agent on SIGINT/SIGTERM, then stops
+// listening and re-delivers the signal so the host's own handling (or the
+// default termination) still applies.
+func whatapShutdownOnSignal() {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	sig := <-c
+	whataptrace.Shutdown()
+	signal.Stop(c)
+	if p, err := os.FindProcess(os.Getpid()); err == nil && p.Signal(sig) == nil {
+		return
+	}
+	os.Exit(1)
+}
+`)
+	}
+	if export {
+		b.WriteString("\n// " + cleanup + " flushes and stops the agent. The host calls it before\n// unloading the library or exiting.\n")
+		if cgoExport {
+			b.WriteString("//\n//export " + cleanup + "\n")
+		}
+		b.WriteString("func " + cleanup + "() {\n\twhataptrace.Shutdown()\n}\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,150 @@
+package custom
+
+import (
+	"go/format"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/whatap/go-api-inst/config"
+)
+
+func writeGo(t *testing.T, path, src string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLibraryInitRule(t *testing.T) {
+	dir := t.TempDir()
+	writeGo(t, filepath.Join(dir, "plugin", "plugin.go"), "package hooks\n\nfunc Handle() {}\n")
+	writeGo(t, filepath.Join(dir, "plugin", "plugin_test.go"), "package hooks_test\n")
+
+	cases := []struct {
+		li      config.LibraryInitConfig
+		want    []string
+		notWant []string
+	}{
+		{
+			config.LibraryInitConfig{Package: "./plugin"},
+			[]string{"package hooks\n", "whataptrace.Init(nil)", "go whatapShutdownOnSignal()", "signal.Stop(c)"},
+			[]string{"WhatapShutdown()", `import "C"`},
+		},
+		{
+			config.LibraryInitConfig{Package: "plugin", Shutdown: "export", Cleanup: "StopAgent", CgoExport: true},
+			[]string{`import "C"`, "//export StopAgent\nfunc StopAgent() {\n\twhataptrace.Shutdown()\n}"},
+			[]string{"os/signal"},
+		},
+		{
+			config.LibraryInitConfig{Package: "plugin", Shutdown: "both"},
+			[]string{"go whatapShutdownOnSignal()", "func WhatapShutdown() {"},
+			[]string{"//export"},
+		},
+		{
+			config.LibraryInitConfig{Package: "plugin", Shutdown: "none"},
+			[]string{"func init() {\n\twhataptrace.Init(nil)\n}"},
+			[]string{"Shutdown"},
+		},
+	}
+	for _, tc := range cases {
+		rule, err := LibraryInitRule(dir, &tc.li, nil)
+		if err != nil {
+			t.Fatalf("%+v: %v", tc.li, err)
+		}
+		if rule.Package != "plugin" || rule.File != DefaultLibraryInitFile {
+			t.Errorf("%+v: rule = %s/%s", tc.li, rule.Package, rule.File)
+		}
+		formatted, err := format.Source([]byte(rule.Content))
+		if err != nil {
+			t.Fatalf("%+v: generated source does not parse: %v\n%s", tc.li, err, rule.Content)
+		}
+		if string(formatted) != rule.Content {
+			t.Errorf("%+v: generated source is not gofmt'd:\n%s", tc.li, rule.Content)
+		}
+		for _, w := range tc.want {
+			if !strings.Contains(rule.Content, w) {
+				t.Errorf("%+v: missing %q:\n%s", tc.li, w, rule.Content)
+			}
+		}
+		for _, w := range tc.notWant {
+			if strings.Contains(rule.Content, w) {
+				t.Errorf("%+v: unexpected %q:\n%s", tc.li, w, rule.Content)
+			}
+		}
+	}
+}
+
+func TestLibraryInitRule_Errors(t *testing.T) {
+	dir := t.TempDir()
+	writeGo(t, filepath.Join(dir, "main.go"), "package main\n\nfunc main() {\n\trun()\n}\n")
+	writeGo(t, filepath.Join(dir, "plug", "main.go"), "package main\n\nfunc main() {}\n")
+	writeGo(t, filepath.Join(dir, "empty", "doc.txt"), "")
+
+	if _, err := LibraryInitRule(dir, &config.LibraryInitConfig{Package: "plug"}, nil); err != nil {
+		t.Errorf("plugin package main with an empty main() must be accepted: %v", err)
+	}
+	// A `//go:build ignore` generator is not part of the package.
+	writeGo(t, filepath.Join(dir, "lib", "lib.go"), "package lib\n\nfunc Do() {}\n")
+	writeGo(t, filepath.Join(dir, "lib", "gen.go"), "//go:build ignore\n\npackage main\n\nfunc main() {\n\tgenerate()\n}\n")
+	if rule, err := LibraryInitRule(dir, &config.LibraryInitConfig{Package: "lib"}, nil); err != nil || !strings.Contains(rule.Content, "\npackage lib\n") {
+		t.Errorf("library with an excluded generator: err = %v\n%s", err, rule.Content)
+	}
+	// Files behind a build tag count only when the build passes -tags.
+	writeGo(t, filepath.Join(dir, "pro", "pro.go"), "//go:build pro\n\npackage pro\n\nfunc Do() {}\n")
+	if _, err := LibraryInitRule(dir, &config.LibraryInitConfig{Package: "pro"}, nil); err == nil || !strings.Contains(err.Error(), "no Go files") {
+		t.Errorf("tagged package without -tags: err = %v, want no Go files", err)
+	}
+	if rule, err := LibraryInitRule(dir, &config.LibraryInitConfig{Package: "pro"}, []string{"pro"}); err != nil || !strings.Contains(rule.Content, "\npackage pro\n") {
+		t.Errorf("tagged package with -tags pro: err = %v\n%s", err, rule.Content)
+	}
+	for _, tc := range []struct {
+		li      config.LibraryInitConfig
+		wantErr string
+	}{
+		{config.LibraryInitConfig{Package: "."}, "has a main()"},
+		{config.LibraryInitConfig{Package: "empty"}, "no Go files"},
+		{config.LibraryInitConfig{Package: "plug", Shutdown: "exit"}, `shutdown "exit"`},
+		{config.LibraryInitConfig{Package: "plug", Shutdown: "export", Cleanup: "stop"}, "not an exported Go identifier"},
+		{config.LibraryInitConfig{Package: "plug", CgoExport: true}, "cgo_export needs"},
+		{config.LibraryInitConfig{Package: "plug", File: "sub/init.go"}, "without directories"},
+	} {
+		_, err := LibraryInitRule(dir, &tc.li, nil)
+		if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("%+v: err = %v, want %q", tc.li, err, tc.wantErr)
+		}
+	}
+}
+
+// TestAddRules appends the library_init rule after the user's add rules
+// and leaves cfg.Add untouched.
+func TestAddRules(t *testing.T) {
+	dir := t.TempDir()
+	writeGo(t, filepath.Join(dir, "lib.go"), "package lib\n")
+	cfg := &config.Config{Add: []config.AddRule{{Package: ".", File: "whatap_helper.go", Content: "package lib\n"}}}
+
+	rules, err := AddRules(dir, cfg, nil)
+	if err != nil || len(rules) != 1 {
+		t.Fatalf("without library_init: %d rules, %v", len(rules), err)
+	}
+	cfg.Instrumentation.LibraryInit = &config.LibraryInitConfig{}
+	rules, err = AddRules(dir, cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 2 || rules[1].File != DefaultLibraryInitFile || len(cfg.Add) != 1 {
+		t.Errorf("rules = %+v, cfg.Add = %+v", rules, cfg.Add)
+	}
+
+	out := t.TempDir()
+	if err := ApplyAddRules(out, dir, rules); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(out, DefaultLibraryInitFile)); err != nil {
+		t.Error(err)
+	}
+}
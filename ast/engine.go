@@ -35,6 +35,24 @@ type Engine struct {
 	// (b4131398 / 2026-03-24) lost during §227 Step 5 v1 retirement.
 	replacedModules     []string
 	skipReplacedModules bool
+
+	// scopeSite is the package/file being processed, checked against
+	// Rule.Scope after lookup. Zero value = unknown: package patterns
+	// never match it, so scoped rules with an include list stay off.
+	scopeSite ScopeSite
+
+	// ruleErrors collects MatchContext.Err values raised while processing
+	// the current file (see RuleErrors).
+	ruleErrors []RuleError
+
+	// adviceBlocks are the blocks Advice created in the current file
+	// (MatchContext.Blocks); processNestedBlocks descends into them.
+	adviceBlocks map[*dst.BlockStmt]bool
+
+	// callees holds the Ident/SelectorExpr nodes seen in callee position —
+	// a call's Fun, a selector's Sel — so only the remaining references are
+	// resolved as "value:" targets. Filled only when value rules exist.
+	callees map[dst.Node]bool
 }
 
 // NewEngine creates a new Engine.
@@ -60,6 +78,24 @@ func (e *Engine) SetSkipReplacedModules(skip bool) {
 	e.skipReplacedModules = skip
 }
 
+// SetScopeSite tells the engine which user package and file it is
+// processing, for rules restricted with `scope:` (see RuleScope).
+func (e *Engine) SetScopeSite(site ScopeSite) {
+	e.scopeSite = site
+}
+
+// inScope reports whether rule may apply to the current package/file.
+func (e *Engine) inScope(rule *Rule) bool {
+	if rule.Scope.Allows(e.scopeSite) {
+		return true
+	}
+	if engineDebug {
+		fmt.Fprintf(os.Stderr, "[v2-resolve] skip target=%q (out of scope: pkg=%q file=%q)\n",
+			rule.Target, e.scopeSite.ImportPath, e.scopeSite.File)
+	}
+	return false
+}
+
 // isReplacedTarget reports whether the Rule target's package path
 // matches any go.mod replace directive entry. §271 — mirrors v1
 // Injector.isReplacedModule logic. Returns false when the skip is
@@ -89,11 +125,41 @@ func (e *Engine) isReplacedTarget(target string) bool {
 	return false
 }
 
+// RuleErrors returns the rule errors raised by the last Process call —
+// templates that failed to execute or expanded to invalid Go. The matches
+// they belong to were left untouched.
+func (e *Engine) RuleErrors() []RuleError {
+	return e.ruleErrors
+}
+
+// recordRuleError appends ctx.Err as a RuleError. The nested-block pass can
+// revisit a call the outer pass already tried, so an identical error for the
+// same rule and node is recorded once.
+func (e *Engine) recordRuleError(ctx *MatchContext) {
+	re := RuleError{RuleID: ctx.Rule.ID, Target: ctx.Target, Err: ctx.Err, node: ctx.matchedNode()}
+	if re.RuleID == "" {
+		re.RuleID = ctx.Rule.Target
+	}
+	if pos, ok := common.NodePosition(re.node); ok {
+		re.File = pos.Filename
+		re.Line = pos.Line
+	}
+	for _, prev := range e.ruleErrors {
+		if prev.node == re.node && prev.RuleID == re.RuleID && prev.Err.Error() == re.Err.Error() {
+			return
+		}
+	}
+	e.ruleErrors = append(e.ruleErrors, re)
+}
+
 // Process runs the engine on a file. Returns true if any transformation occurred.
 func (e *Engine) Process(file *dst.File) bool {
 	e.transformed = false
 	e.whatapImports = make(map[string]string)
 	e.replacedPkgs = make(map[string]string)
+	e.ruleErrors = nil
+	e.adviceBlocks = nil
+	e.callees = nil
 
 	// Traverse AST — match rules, apply transformations
 	for _, decl := range file.Decls {
@@ -143,8 +209,24 @@ func (e *Engine) resolveImports(file *dst.File) {
 		}
 	}
 
-	// Step 3: Add whatap imports
+	// Step 3: Add whatap imports. Helper imports a Transform template only
+	// sometimes uses (e.g. "context" for a {{.Ctx}} that rendered as
+	// context.Background()) are added only when referenced — an unused
+	// import would fail the build.
+	var usedPkgs map[string]bool
 	for importPath, alias := range e.whatapImports {
+		if !strings.HasPrefix(importPath, "github.com/whatap/") && !originalImports[importPath] {
+			if usedPkgs == nil {
+				usedPkgs = collectUsedPackages(file)
+			}
+			name := alias
+			if name == "" {
+				name = common.DefaultPackageName(importPath)
+			}
+			if !usedPkgs[name] {
+				continue
+			}
+		}
 		if !originalImports[importPath] {
 			if alias != "" && !isRedundantAlias(importPath, alias) {
 				common.AddImportWithAlias(file, importPath, alias)
@@ -199,6 +281,17 @@ func (e *Engine) processStmt(file *dst.File, block *[]dst.Stmt, idx int, stmt ds
 		}
 		matched := e.matchAndApply(file, node, block, idx, stmt)
 		if matched {
+			// A field assignment only rewrites its RHS into whatap.F(v); the
+			// AssignStmt itself is never revisited, so keep descending — calls
+			// inside v may still match other rules.
+			if _, ok := node.(*dst.AssignStmt); ok {
+				return true
+			}
+			// Likewise a range loop only gains a per-iteration transaction;
+			// its body still holds the user's calls.
+			if _, ok := node.(*dst.RangeStmt); ok {
+				return true
+			}
 			// Don't descend into children of transformed nodes.
 			// WrapCall creates inner CallExpr that would re-match → infinite recursion.
 			return false
@@ -241,7 +334,8 @@ func (e *Engine) matchFuncDecl(file *dst.File, fn *dst.FuncDecl) {
 	}
 
 	// §272 Phase 3 Step 2 — ModeRemove 경로 미사용. forward map 만 조회.
-	rule := e.registry.Lookup(target)
+	// A rule out of scope here passes the match on to the next candidate.
+	rule := e.registry.lookupAccepted(target, e.inScope)
 	if rule == nil {
 		return
 	}
@@ -266,6 +360,9 @@ func (e *Engine) matchFuncDecl(file *dst.File, fn *dst.FuncDecl) {
 
 	ctx.Applied = true // default: assume applied (most Advice types always apply)
 	rule.Advice.Apply(ctx)
+	if ctx.Err != nil {
+		e.recordRuleError(ctx)
+	}
 
 	if !ctx.Applied {
 		return // Advice skipped — no import, no transform
@@ -299,6 +396,9 @@ func (e *Engine) matchFuncDecl(file *dst.File, fn *dst.FuncDecl) {
 // matchAndApply resolves a node's target and applies the matching rule.
 // Returns true if a rule was applied (caller should skip children to avoid re-matching).
 func (e *Engine) matchAndApply(file *dst.File, node dst.Node, block *[]dst.Stmt, idx int, stmt dst.Stmt) bool {
+	if !e.shouldResolve(node) {
+		return false
+	}
 	target := e.resolve(node)
 	if target == "" {
 		return false
@@ -321,7 +421,12 @@ func (e *Engine) matchAndApply(file *dst.File, node dst.Node, block *[]dst.Stmt,
 	}
 
 	// §272 Phase 3 Step 2 — ModeRemove 경로 미사용. forward map 만 조회.
-	rule := e.registry.Lookup(target)
+	// A rule out of scope here passes the match on to the next candidate:
+	// exact → patterns → interface targets.
+	rule := e.registry.lookupAccepted(target, e.inScope)
+	if rule == nil {
+		rule = e.registry.lookupInterface(node, e.inScope)
+	}
 	if rule == nil {
 		return false
 	}
@@ -338,11 +443,20 @@ func (e *Engine) matchAndApply(file *dst.File, node dst.Node, block *[]dst.Stmt,
 
 	ctx.Applied = true // default: assume applied (most Advice types always apply)
 	rule.Advice.Apply(ctx)
+	if ctx.Err != nil {
+		e.recordRuleError(ctx)
+	}
 
 	if !ctx.Applied {
 		return false // Advice skipped (e.g., MainInsert not in main()) — no import, no transform
 	}
 	e.transformed = true
+	for _, b := range ctx.Blocks {
+		if e.adviceBlocks == nil {
+			e.adviceBlocks = make(map[*dst.BlockStmt]bool)
+		}
+		e.adviceBlocks[b] = true
+	}
 
 	// Track whatap imports to add and original imports to potentially remove
 	if e.mode == ModeInject {
@@ -372,6 +486,42 @@ func (e *Engine) matchAndApply(file *dst.File, node dst.Node, block *[]dst.Stmt,
 	return true
 }
 
+// shouldResolve filters Ident and SelectorExpr nodes before resolution: they
+// are candidates only as function/method values, i.e. when value rules exist
+// and the node is not in callee position. dst.Inspect visits parents first,
+// so a CallExpr or SelectorExpr marks its callee child before it comes up.
+// Every other node kind passes.
+func (e *Engine) shouldResolve(node dst.Node) bool {
+	if !e.registry.hasValueRules() {
+		switch node.(type) {
+		case *dst.Ident, *dst.SelectorExpr:
+			return false
+		}
+		return true
+	}
+	if e.callees == nil {
+		e.callees = make(map[dst.Node]bool)
+	}
+	switch n := node.(type) {
+	case *dst.CallExpr:
+		fun := n.Fun
+		for {
+			paren, ok := fun.(*dst.ParenExpr)
+			if !ok {
+				break
+			}
+			fun = paren.X
+		}
+		e.callees[fun] = true
+	case *dst.SelectorExpr:
+		e.callees[n.Sel] = true
+		return !e.callees[n]
+	case *dst.Ident:
+		return !e.callees[n]
+	}
+	return true
+}
+
 // processNestedBlocks recurses into nested block structures.
 func (e *Engine) processNestedBlocks(file *dst.File, stmt dst.Stmt) {
 	switch s := stmt.(type) {
@@ -422,6 +572,16 @@ func (e *Engine) processNestedBlocks(file *dst.File, stmt dst.Stmt) {
 				}
 			}
 		}
+	case *dst.ExprStmt:
+		// func() { ... }() that an Advice created — LoopBody moves the rest of
+		// an iteration into such a literal; descend so statement-level Advice
+		// (Hook, CodeInsert) still sees those calls in their own block. User
+		// literals are left to processStmt, as before.
+		if call, ok := s.X.(*dst.CallExpr); ok {
+			if lit, ok := call.Fun.(*dst.FuncLit); ok && e.adviceBlocks[lit.Body] {
+				e.processBlock(file, &lit.Body.List)
+			}
+		}
 	}
 }
 
@@ -441,7 +601,7 @@ func (e *Engine) buildContext(file *dst.File, node dst.Node, target string, rule
 	switch n := node.(type) {
 	case *dst.CallExpr:
 		ctx.Call = n
-		if sel, ok := n.Fun.(*dst.SelectorExpr); ok {
+		if sel, ok := stripInstantiation(n.Fun).(*dst.SelectorExpr); ok {
 			ctx.Sel = sel
 			ctx.FuncName = sel.Sel.Name
 			if ident, ok := sel.X.(*dst.Ident); ok {
@@ -451,7 +611,7 @@ func (e *Engine) buildContext(file *dst.File, node dst.Node, target string, rule
 		}
 	case *dst.CompositeLit:
 		ctx.Lit = n
-		if sel, ok := n.Type.(*dst.SelectorExpr); ok {
+		if sel, ok := stripInstantiation(n.Type).(*dst.SelectorExpr); ok {
 			ctx.Sel = sel
 			ctx.FuncName = sel.Sel.Name
 			if ident, ok := sel.X.(*dst.Ident); ok {
@@ -459,6 +619,29 @@ func (e *Engine) buildContext(file *dst.File, node dst.Node, target string, rule
 				ctx.PkgName = ident.Name
 			}
 		}
+	case *dst.AssignStmt:
+		// resolveAssignTarget guarantees a single selector LHS.
+		ctx.Assign = n
+		if sel, ok := n.Lhs[0].(*dst.SelectorExpr); ok {
+			ctx.Sel = sel
+			ctx.FuncName = sel.Sel.Name
+		}
+	case *dst.Ident:
+		// "value:" match on a bare function name
+		ctx.Ref = n
+		ctx.FuncName = n.Name
+	case *dst.SelectorExpr:
+		// "value:" match on pkg.Func, a method value or a method expression
+		ctx.Ref = n
+		ctx.Sel = n
+		ctx.FuncName = n.Sel.Name
+		if ident, ok := n.X.(*dst.Ident); ok {
+			ctx.Ident = ident
+			ctx.PkgName = ident.Name
+		}
+	case *dst.RangeStmt:
+		// "range:" match on a loop over a channel
+		ctx.Range = n
 	}
 
 	return ctx
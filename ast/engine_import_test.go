@@ -2,6 +2,7 @@ package ast
 
 import (
 	"go/token"
+	"strings"
 	"testing"
 
 	"github.com/dave/dst"
@@ -193,3 +194,77 @@ func makeTestFile(imports map[string]string, refs []pkgRef) *dst.File {
 		Imports: impSpecs,
 	}
 }
+
+// transformCtxEngine builds an engine with one Transform rule whose template
+// renders {{.Ctx}} and lists "context" as a helper import.
+func transformCtxEngine() *Engine {
+	reg := NewRegistry()
+	reg.Register(&Rule{
+		Target: "example.com/cache.Get",
+		Advice: &Transform{
+			Template: `whatapcache.Get({{.Ctx}}, {{.Arg0}})`,
+			Imports:  []string{"github.com/whatap/go-api/instrumentation/example.com/cache/whatapcache", "context"},
+		},
+	})
+	resolve := func(n dst.Node) string {
+		call, ok := n.(*dst.CallExpr)
+		if !ok {
+			return ""
+		}
+		if sel, ok := call.Fun.(*dst.SelectorExpr); ok && sel.Sel.Name == "Get" {
+			if id, ok := sel.X.(*dst.Ident); ok && id.Name == "cache" {
+				return "example.com/cache.Get"
+			}
+		}
+		return ""
+	}
+	return NewEngine(reg, ModeInject, resolve)
+}
+
+// TestResolveImports_HelperImportOnlyWhenUsed — "context" must be added when
+// {{.Ctx}} falls back to context.Background(), and left out when the template
+// rendered the handler's r.Context() (an unused import fails the build).
+func TestResolveImports_HelperImportOnlyWhenUsed(t *testing.T) {
+	tests := []struct {
+		name, src   string
+		wantContext bool
+	}{
+		{"background", `package p
+
+import "example.com/cache"
+
+func f() {
+	v := cache.Get("k")
+	_ = v
+}
+`, true},
+		{"handler ctx", `package p
+
+import (
+	"net/http"
+
+	"example.com/cache"
+)
+
+func h(w http.ResponseWriter, r *http.Request) {
+	v := cache.Get("k")
+	_ = v
+}
+`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := parseTestFile(t, tt.src)
+			if !transformCtxEngine().Process(file) {
+				t.Fatal("expected a transformation")
+			}
+			got := fileToString(t, file)
+			if has := strings.Contains(got, `"context"`); has != tt.wantContext {
+				t.Errorf("context import present = %v, want %v:\n%s", has, tt.wantContext, got)
+			}
+			if !strings.Contains(got, "whatapcache") {
+				t.Errorf("whatap helper import missing:\n%s", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package ast
+
+import (
+	"fmt"
+	goast "go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"testing"
+
+	"github.com/dave/dst"
+	"github.com/dave/dst/decorator"
+	"github.com/whatap/go-api-inst/ast/common"
+)
+
+// stubImporter type-checks third-party packages from stub sources (import
+// path → Go source declaring just the API the rules target), so rule tests
+// run through go/types without the real modules. Other imports (stdlib)
+// come from source.
+type stubImporter struct {
+	fset  *token.FileSet
+	stubs map[string]string
+	pkgs  map[string]*types.Package
+	std   types.Importer
+}
+
+func (im *stubImporter) Import(path string) (*types.Package, error) {
+	if pkg, ok := im.pkgs[path]; ok {
+		return pkg, nil
+	}
+	src, ok := im.stubs[path]
+	if !ok {
+		return im.std.Import(path)
+	}
+	f, err := parser.ParseFile(im.fset, path+"/stub.go", src, 0)
+	if err != nil {
+		return nil, err
+	}
+	conf := &types.Config{Importer: im}
+	pkg, err := conf.Check(path, im.fset, []*goast.File{f}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("stub %s: %v", path, err)
+	}
+	im.pkgs[path] = pkg
+	return pkg, nil
+}
+
+// decorateWithStubs type-checks src (package example.com/app) against stub
+// packages and installs the type context for the returned dst file, as
+// toolexec does before the engine runs.
+func decorateWithStubs(t *testing.T, src string, stubs map[string]string) *dst.File {
+	t.Helper()
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "app.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := &types.Info{
+		Types:     make(map[goast.Expr]types.TypeAndValue),
+		Defs:      make(map[*goast.Ident]types.Object),
+		Uses:      make(map[*goast.Ident]types.Object),
+		Instances: make(map[*goast.Ident]types.Instance),
+	}
+	im := &stubImporter{fset: fset, stubs: stubs, pkgs: map[string]*types.Package{}, std: importer.ForCompiler(fset, "source", nil)}
+	conf := &types.Config{Importer: im}
+	if _, err := conf.Check("example.com/app", fset, []*goast.File{f}, info); err != nil {
+		t.Fatal(err)
+	}
+	dec := decorator.NewDecorator(fset)
+	file, err := dec.DecorateFile(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	common.SetTypeContext(info, dec.Ast.Nodes)
+	t.Cleanup(common.ClearTypeContext)
+	return file
+}
+
+// processBuiltin runs the engine with the built-in rules over file.
+func processBuiltin(file *dst.File) bool {
+	reg := NewRegistry()
+	for _, r := range AllRules() {
+		reg.Register(r)
+	}
+	return NewEngine(reg, ModeInject, resolveTarget).Process(file)
+}
+
+// findRule returns the built-in rule for target, failing the test if absent.
+func findRule(t *testing.T, target string) *Rule {
+	t.Helper()
+	for _, r := range AllRules() {
+		if r.Target == target {
+			return r
+		}
+	}
+	t.Fatalf("no built-in rule for %s", target)
+	return nil
+}
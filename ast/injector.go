@@ -1,9 +1,11 @@
 package ast
 
 import (
+	"errors"
 	"fmt"
 	"go/token"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 
@@ -14,6 +16,7 @@ import (
 
 	"github.com/dave/dst"
 	"github.com/dave/dst/decorator"
+	"golang.org/x/mod/modfile"
 )
 
 // Injector injects monitoring code into source files using the v2 engine.
@@ -36,6 +39,42 @@ type Injector struct {
 	// config.InstrumentationConfig.SkipReplacedModules.
 	SkipReplacedModules bool
 
+	// FailOnRuleError makes rule errors fatal (config
+	// instrumentation.fail_on_rule_error): InjectFile returns an
+	// ErrRuleFailed-wrapped error instead of recording a diagnostic and
+	// carrying on.
+	FailOnRuleError bool
+
+	// TestMode instruments `go test` binaries (config
+	// instrumentation.test_mode, see test_mode.go): the generated test main
+	// starts the agent and _test.go files are processed. Off, the generated
+	// test main is passed through untouched.
+	TestMode bool
+
+	// TestTransactions traces each TestXxx / t.Run subtest as a transaction
+	// (test_mode.transactions). Only with TestMode.
+	TestTransactions bool
+
+	// ImportPath is the import path of the package being compiled
+	// (TOOLEXEC_IMPORTPATH), checked against rule `scope:` blocks. Empty =
+	// derived from the main module path and the file's directory.
+	ImportPath string
+
+	// BuildContext is the target platform and -tags of the build, checked
+	// against rule `build:` clauses. nil = DefaultBuildContext(nil).
+	BuildContext *BuildContext
+
+	// modulePath caches the main module path read from go.mod at
+	// Config.BaseDir (nil = not read yet).
+	modulePath *string
+
+	// skippedRules lists the rules the last buildRegistry left out because
+	// their `build:` / `when:` clause did not hold (see SkippedRules).
+	skippedRules []RuleSkip
+
+	// ruleLoadErr is the LoadCustomRules error from the last buildRegistry.
+	ruleLoadErr error
+
 	// Config holds the full configuration (for custom rules access)
 	Config *config.Config
 
@@ -46,6 +85,11 @@ type Injector struct {
 	registry *Registry
 }
 
+// ErrRuleFailed wraps InjectFile errors caused by a rule under
+// FailOnRuleError. Callers must fail the build rather than fall back to the
+// original source.
+var ErrRuleFailed = errors.New("rule error")
+
 // NewInjector creates a new v2 injector with type checking enabled.
 func NewInjector() *Injector {
 	inj := &Injector{
@@ -67,7 +111,37 @@ func NewInjector() *Injector {
 //     re-built with the user rules.
 func (inj *Injector) buildRegistry() {
 	inj.registry = NewRegistry()
+	inj.ruleLoadErr = nil
 	inj.registry.SetPackageFilter(inj.EnabledPackages, inj.DisabledPackages)
+	inj.registry.SetPackageScopes(inj.builtinScopes())
+	inj.skippedRules = nil
+	versions, versionsRead := map[string]string(nil), false
+	buildCtx := inj.BuildContext
+	if buildCtx == nil {
+		buildCtx = DefaultBuildContext(nil)
+	}
+	admit := func(r *Rule) bool {
+		ok, reason := true, ""
+		if r.DryRunErr != nil {
+			ok, reason = false, r.DryRunErr.Error()
+			if inj.ruleLoadErr == nil {
+				inj.ruleLoadErr = fmt.Errorf("rule %s: %w", ruleID(r), r.DryRunErr)
+			}
+		}
+		if ok && r.Build != nil {
+			ok, reason = r.Build.check(buildCtx)
+		}
+		if ok && r.When != nil {
+			if !versionsRead {
+				versions, versionsRead = inj.moduleVersions(), true
+			}
+			ok, reason = r.When.check(r.Target, versions)
+		}
+		if !ok {
+			inj.skippedRules = append(inj.skippedRules, RuleSkip{RuleID: ruleID(r), Target: r.Target, Reason: reason, Template: r.DryRunErr != nil})
+		}
+		return ok
+	}
 	builtins := LoadBuiltinRules()
 	// §242 Step 11 — warn (never fail) on user yaml paths that do not match
 	// any built-in rule. Run once against the full built-in set before the
@@ -75,7 +149,7 @@ func (inj *Injector) buildRegistry() {
 	// config as-written.
 	ValidatePackageFilter(inj.EnabledPackages, inj.DisabledPackages, builtins)
 	for _, r := range builtins {
-		if r != nil {
+		if r != nil && admit(r) {
 			inj.registry.Register(r)
 		}
 	}
@@ -84,12 +158,14 @@ func (inj *Injector) buildRegistry() {
 		userRules, err := LoadCustomRules(inj.Config)
 		if err != nil {
 			// Don't fail the whole build for a single bad user rule —
-			// surface it via stderr and continue with built-ins.
+			// surface it via stderr and continue with built-ins. Under
+			// FailOnRuleError InjectFile turns ruleLoadErr into a failure.
 			fmt.Fprintf(os.Stderr, "[whatap-go-inst] custom rules: %v\n", err)
+			inj.ruleLoadErr = err
 			return
 		}
 		for _, r := range userRules {
-			if r != nil {
+			if r != nil && admit(r) {
 				inj.registry.RegisterUser(r)
 			}
 		}
@@ -102,14 +178,119 @@ func (inj *Injector) buildRegistry() {
 	}
 }
 
+// RuleSkip is a rule left out of the registry because its `build:` or
+// `when:` clause did not hold for this build, or because one of its
+// templates failed the load-time dry run.
+type RuleSkip struct {
+	RuleID   string // Rule.ID, or Rule.Target when the rule has no id
+	Target   string
+	Reason   string // e.g. `github.com/IBM/sarama v1.37.0 does not satisfy ">=1.38.0"`
+	Template bool   // dry-run failure (Rule.DryRunErr) rather than a condition
+}
+
+// SkippedRules returns the rules the current registry left out because of
+// their `build:` / `when:` clause or a failed template dry run. The caller
+// reports them (once per build — toolexec children rebuild the same registry).
+func (inj *Injector) SkippedRules() []RuleSkip {
+	return inj.skippedRules
+}
+
+func ruleID(r *Rule) string {
+	if r.ID != "" {
+		return r.ID
+	}
+	return r.Target
+}
+
+// moduleVersions reads the required module versions from go.mod at
+// Config.BaseDir (nil when there is none).
+func (inj *Injector) moduleVersions() map[string]string {
+	if inj.Config == nil || inj.Config.BaseDir == "" {
+		return nil
+	}
+	versions, err := report.ReadModuleVersions(filepath.Join(inj.Config.BaseDir, "go.mod"))
+	if err != nil {
+		return nil
+	}
+	return versions
+}
+
+// builtinScopes converts config instrumentation.rule_scopes for
+// Registry.SetPackageScopes. An entry with a bad pattern is dropped with a
+// warning — like unknown enabled_packages entries, it never fails the build.
+func (inj *Injector) builtinScopes() map[string]*RuleScope {
+	if inj.Config == nil || len(inj.Config.Instrumentation.RuleScopes) == 0 {
+		return nil
+	}
+	out := make(map[string]*RuleScope, len(inj.Config.Instrumentation.RuleScopes))
+	for pkg, s := range inj.Config.Instrumentation.RuleScopes {
+		scope := &RuleScope{Include: s.Include, Exclude: s.Exclude}
+		if err := validateScope(scope); err != nil {
+			fmt.Fprintf(os.Stderr, "[whatap-go-inst] warning: rule_scopes[%q]: %v — ignored\n", pkg, err)
+			continue
+		}
+		out[pkg] = scope
+	}
+	return out
+}
+
+// scopeSite describes srcPath for rule scopes: the package import path and
+// the file path relative to Config.BaseDir. Files outside BaseDir (module
+// cache, external modules) get no relative path.
+func (inj *Injector) scopeSite(srcPath string) ScopeSite {
+	site := ScopeSite{ModulePath: inj.mainModulePath()}
+	// "pkg [pkg.test]" — test variants share the package's scope.
+	site.ImportPath, _, _ = strings.Cut(inj.ImportPath, " ")
+	if inj.Config == nil || inj.Config.BaseDir == "" {
+		return site
+	}
+	abs, err := filepath.Abs(srcPath)
+	if err != nil {
+		return site
+	}
+	rel, err := filepath.Rel(inj.Config.BaseDir, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return site
+	}
+	site.File = filepath.ToSlash(rel)
+	if site.ImportPath == "" && site.ModulePath != "" {
+		if dir := path.Dir(site.File); dir == "." {
+			site.ImportPath = site.ModulePath
+		} else {
+			site.ImportPath = site.ModulePath + "/" + dir
+		}
+	}
+	return site
+}
+
+// mainModulePath returns the module path declared in Config.BaseDir/go.mod
+// ("" when there is none).
+func (inj *Injector) mainModulePath() string {
+	if inj.modulePath != nil {
+		return *inj.modulePath
+	}
+	var mod string
+	if inj.Config != nil && inj.Config.BaseDir != "" {
+		if data, err := os.ReadFile(filepath.Join(inj.Config.BaseDir, "go.mod")); err == nil {
+			mod = modfile.ModulePath(data)
+		}
+	}
+	inj.modulePath = &mod
+	return mod
+}
+
 // SetConfig attaches a config.Config to the injector and re-builds the
 // registry so user-defined rules from cfg.Rules are picked up. Use this
 // instead of the bare `inj.Config = cfg` assignment when the caller wants
 // custom rules to take effect.
 func (inj *Injector) SetConfig(cfg *config.Config) {
 	inj.Config = cfg
+	inj.modulePath = nil
 	if cfg != nil {
 		inj.SkipReplacedModules = cfg.Instrumentation.ShouldSkipReplacedModules()
+		inj.FailOnRuleError = cfg.Instrumentation.FailOnRuleError
+		inj.TestMode = cfg.Instrumentation.TestMode.Enabled
+		inj.TestTransactions = cfg.Instrumentation.TestMode.Transactions
 	}
 	inj.buildRegistry()
 }
@@ -125,6 +306,10 @@ func (inj *Injector) Rules() []*Rule {
 
 // InjectFile injects monitoring code into a single file using the v2 engine.
 func (inj *Injector) InjectFile(srcPath, dstPath string) error {
+	if inj.FailOnRuleError && inj.ruleLoadErr != nil {
+		return fmt.Errorf("%w: custom rules: %v", ErrRuleFailed, inj.ruleLoadErr)
+	}
+
 	src, err := os.ReadFile(srcPath)
 	if err != nil {
 		report.Get().AddFile(report.FileReport{
@@ -167,15 +352,45 @@ func (inj *Injector) InjectFile(srcPath, dstPath string) error {
 		return inj.copyFile(srcPath, dstPath)
 	}
 
+	// §185: Always use "whataptrace" alias — the file does not import
+	// go-api/trace yet (checked above). Shared by the test main below.
+	traceAlias := "whataptrace"
+
+	// The main() `go test` generates for a test binary (test_mode.go). It
+	// is not the user's main — only instrumented in test mode.
+	if isGeneratedTestMain(srcPath) {
+		if !inj.TestMode {
+			report.Get().AddFile(report.FileReport{
+				Path:   srcPath,
+				Status: report.StatusSkipped,
+				Reason: "generated test main (instrumentation.test_mode off)",
+			})
+			return inj.copyFile(srcPath, dstPath)
+		}
+		changes := inj.injectTestMain(file, traceAlias)
+		status := report.StatusInstrumented
+		if len(changes) == 0 {
+			status = report.StatusSkipped
+		}
+		report.Get().AddFile(report.FileReport{
+			Path:      srcPath,
+			Status:    status,
+			Changes:   changes,
+			SizeBytes: len(src),
+		})
+		return inj.writeFile(file, dstPath)
+	}
+
 	// §169 Phase 2: Early filtering
 	hasMainFunc := common.FindNonEmptyMainFunc(file) != nil
+	testFile := inj.TestMode && isTestFile(srcPath)
 
 	hasCustomRules := inj.Config != nil && len(inj.Config.Rules) > 0
 
 	// v2: Check if any rule targets could match imports in this file
 	hasTargetImports := inj.hasTargetImports(file)
 
-	if !hasTargetImports && !hasMainFunc && !hasCustomRules {
+	if !hasTargetImports && !hasMainFunc && !hasCustomRules && !testFile {
 		report.Get().AddFile(report.FileReport{
 			Path:   srcPath,
 			Status: report.StatusSkipped,
@@ -204,27 +419,31 @@ func (inj *Injector) InjectFile(srcPath, dstPath string) error {
 	// §169 Phase 4: Inject
 	var changes []string
 
-	// §185: Always use "whataptrace" alias
-	traceAlias := "whataptrace"
-
 	if hasMainFunc {
 		common.AddImportWithAlias(file, "github.com/whatap/go-api/trace", traceAlias)
 		changes = append(changes, "added import: github.com/whatap/go-api/trace (alias whataptrace)")
 	}
 
 	// Add trace.Init/Shutdown to main() function
+	lambdaMain := hasMainFunc && isLambdaMain(file, common.FindNonEmptyMainFunc(file))
 	if hasMainFunc {
 		changes = append(changes, fmt.Sprintf("added: %s.Init(nil)", traceAlias))
-		changes = append(changes, fmt.Sprintf("added: defer %s.Shutdown()", traceAlias))
+		if lambdaMain {
+			changes = append(changes, "skipped: defer Shutdown (aws-lambda-go main)")
+		} else {
+			changes = append(changes, fmt.Sprintf("added: defer %s.Shutdown()", traceAlias))
+		}
 	}
-	inj.injectMainInit(file, traceAlias)
+	inj.injectMainInit(file, traceAlias, lambdaMain)
 
 	// v2 Engine: single traversal with target-based matching
 	engine := NewEngine(inj.registry, ModeInject, newResolveFunc())
 	// §271 — wire go.mod replace skip-list through to the engine
 	engine.SetReplacedModules(inj.ReplacedModules)
 	engine.SetSkipReplacedModules(inj.SkipReplacedModules)
+	engine.SetScopeSite(inj.scopeSite(srcPath))
 	engineTransformed := engine.Process(file)
+	ruleErrs := engine.RuleErrors()
 
 	if engineTransformed {
 		changes = append(changes, "applied: v2 engine rules")
@@ -236,13 +455,22 @@ func (inj *Injector) InjectFile(srcPath, dstPath string) error {
 		changes = append(changes, "added: error tracing")
 	}
 
+	// Test mode: TestMain shutdown and per-test transactions.
+	testChanged := false
+	if testFile {
+		if c := inj.instrumentTestFile(file, traceAlias); len(c) > 0 {
+			changes = append(changes, c...)
+			testChanged = true
+		}
+	}
+
 	// §227 Step 5: custom rules now live in the v2 Engine registry
 	// (see buildRegistry) and run as part of engine.Process above. The
 	// legacy applyCustomRules() pass has been removed.
 
 	// Record in report
 	status := report.StatusInstrumented
-	if !hasMainFunc && !engineTransformed && !hasCustomRules {
+	if !hasMainFunc && !engineTransformed && !hasCustomRules && !testChanged {
 		status = report.StatusSkipped
 	}
 	// §240: record file size/line count so report has rough per-file metrics
@@ -253,13 +481,29 @@ func (inj *Injector) InjectFile(srcPath, dstPath string) error {
 			lineCount++
 		}
 	}
+	var diags []report.Diagnostic
+	for i := range ruleErrs {
+		ruleErrs[i].File = srcPath
+		diags = append(diags, report.Diagnostic{
+			Level:   report.DiagError,
+			File:    srcPath,
+			Line:    ruleErrs[i].Line,
+			RuleID:  ruleErrs[i].RuleID,
+			Message: ruleErrs[i].Err.Error(),
+			Hint:    "match left uninstrumented; set instrumentation.fail_on_rule_error: true to fail the build instead",
+		})
+	}
 	report.Get().AddFile(report.FileReport{
-		Path:      srcPath,
-		Status:    status,
-		Changes:   changes,
-		SizeBytes: len(src),
-		LineCount: lineCount,
+		Path:        srcPath,
+		Status:      status,
+		Changes:     changes,
+		Diagnostics: diags,
+		SizeBytes:   len(src),
+		LineCount:   lineCount,
 	})
+	if inj.FailOnRuleError && len(ruleErrs) > 0 {
+		return fmt.Errorf("%w: %v", ErrRuleFailed, ruleErrs[0])
+	}
 
 	// Generate result file
 	return inj.writeFile(file, dstPath)
@@ -297,9 +541,20 @@ func (inj *Injector) InjectDir(srcDir, dstDir string) error {
 
 	// §227 Step 5: Add rules now live at cfg.Add (top-level). Engine 밖
 	// 처리는 그대로 — ast/custom/add.go 가 cfg.Add 를 소비.
-	if inj.Config != nil && len(inj.Config.Add) > 0 {
-		if err := custom.ApplyAddRules(dstDir, inj.Config.BaseDir, inj.Config.Add); err != nil {
-			return fmt.Errorf("apply add rules: %w", err)
+	// library_init 은 add rule 하나로 변환되어 함께 적용된다.
+	if inj.Config != nil {
+		var tags []string
+		if inj.BuildContext != nil {
+			tags = inj.BuildContext.Tags
+		}
+		addRules, err := custom.AddRules(srcDir, inj.Config, tags)
+		if err != nil {
+			return err
+		}
+		if len(addRules) > 0 {
+			if err := custom.ApplyAddRules(dstDir, inj.Config.BaseDir, addRules); err != nil {
+				return fmt.Errorf("apply add rules: %w", err)
+			}
 		}
 	}
 
@@ -381,8 +636,13 @@ func (inj *Injector) InjectDir(srcDir, dstDir string) error {
 	return nil
 }
 
-// injectMainInit adds trace.Init/Shutdown to main() function
-func (inj *Injector) injectMainInit(file *dst.File, traceAlias string) {
+// injectMainInit adds trace.Init/Shutdown to main() function.
+//
+// skipShutdown is set for aws-lambda-go mains: lambda.Start never returns
+// (the runtime freezes or kills the process between invocations), so a
+// deferred Shutdown would never run. The per-invocation flush is done by
+// whataplambda.WrapHandler instead (see the lambda rules in rules.go).
+func (inj *Injector) injectMainInit(file *dst.File, traceAlias string, skipShutdown bool) {
 	dst.Inspect(file, func(n dst.Node) bool {
 		fn, ok := n.(*dst.FuncDecl)
 		if !ok || fn.Name.Name != "main" || fn.Recv != nil {
@@ -415,7 +675,10 @@ func (inj *Injector) injectMainInit(file *dst.File, traceAlias string) {
 		shutdownStmt.Decs.After = dst.NewLine
 
 		newList := make([]dst.Stmt, 0, len(fn.Body.List)+2)
-		newList = append(newList, initStmt, shutdownStmt)
+		newList = append(newList, initStmt)
+		if !skipShutdown {
+			newList = append(newList, shutdownStmt)
+		}
 		newList = append(newList, fn.Body.List...)
 		fn.Body.List = newList
 
@@ -423,6 +686,41 @@ func (inj *Injector) injectMainInit(file *dst.File, traceAlias string) {
 	})
 }
 
+// lambdaImportPath is the aws-lambda-go runtime entry package.
+const lambdaImportPath = "github.com/aws/aws-lambda-go/lambda"
+
+// lambdaWrappedEntry lists the lambda entry points with a WrapHandler rule
+// (rules.go); only these flush per invocation.
+var lambdaWrappedEntry = map[string]bool{"Start": true, "StartWithOptions": true}
+
+// isLambdaMain reports whether main() hands control to the aws-lambda-go
+// runtime via lambda.Start / StartWithOptions — the entry points the lambda
+// rules wrap with WrapHandler. StartHandler / StartHandlerFunc are not
+// wrapped, so those mains keep the deferred Shutdown. Uses
+// go/types when available (alias-safe); otherwise falls back to the local
+// package name of the lambda import.
+func isLambdaMain(file *dst.File, fn *dst.FuncDecl) bool {
+	if fn == nil || fn.Body == nil || !common.HasImport(file, lambdaImportPath) {
+		return false
+	}
+	pkgName := common.GetPackageNameForImport(file, lambdaImportPath)
+	found := false
+	dst.Inspect(fn.Body, func(n dst.Node) bool {
+		if found {
+			return false
+		}
+		call, ok := n.(*dst.CallExpr)
+		if !ok {
+			return true
+		}
+		if _, name, ok := common.MatchCallPkg(call, pkgName, lambdaImportPath); ok && lambdaWrappedEntry[name] {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
 // writeFile writes the transformed file to disk
 func (inj *Injector) writeFile(file *dst.File, dstPath string) error {
 	return common.WriteDstFile(file, dstPath)
@@ -0,0 +1,76 @@
+package ast
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/whatap/go-api-inst/ast/common"
+)
+
+// TestInjectMainInit_LambdaSkipsShutdown verifies that a main() handing off to
+// lambda.Start gets trace.Init but no deferred Shutdown — the runtime never
+// lets main return, so the flush happens per invocation in WrapHandler.
+func TestInjectMainInit_LambdaSkipsShutdown(t *testing.T) {
+	src := `package main
+
+import awslambda "github.com/aws/aws-lambda-go/lambda"
+
+func main() {
+	awslambda.Start(handle)
+}
+`
+	file := parseTestFile(t, src)
+	fn := common.FindNonEmptyMainFunc(file)
+	if !isLambdaMain(file, fn) {
+		t.Fatal("aliased lambda.Start should be detected")
+	}
+
+	inj := &Injector{}
+	inj.injectMainInit(file, "whataptrace", isLambdaMain(file, fn))
+	got := fileToString(t, file)
+	if !strings.Contains(got, "whataptrace.Init(nil)") {
+		t.Errorf("missing Init:\n%s", got)
+	}
+	if strings.Contains(got, "Shutdown") {
+		t.Errorf("lambda main must not defer Shutdown:\n%s", got)
+	}
+}
+
+// TestInjectMainInit_RegularMainKeepsShutdown guards the default path.
+func TestInjectMainInit_RegularMainKeepsShutdown(t *testing.T) {
+	src := `package main
+
+import "github.com/aws/aws-lambda-go/lambda"
+
+var _ = lambda.Start
+
+func main() {
+	run()
+}
+`
+	file := parseTestFile(t, src)
+	fn := common.FindNonEmptyMainFunc(file)
+	if isLambdaMain(file, fn) {
+		t.Fatal("main without a lambda.Start call must not be treated as lambda")
+	}
+
+	inj := &Injector{}
+	inj.injectMainInit(file, "whataptrace", false)
+	got := fileToString(t, file)
+	if !strings.Contains(got, "defer whataptrace.Shutdown()") {
+		t.Errorf("regular main should defer Shutdown:\n%s", got)
+	}
+}
+
+// TestIsLambdaMain_UnwrappedEntryPoints keeps the deferred Shutdown for
+// StartHandler / StartHandlerFunc: no rule wraps them, so nothing would
+// flush per invocation.
+func TestIsLambdaMain_UnwrappedEntryPoints(t *testing.T) {
+	for _, call := range []string{"lambda.StartHandler(h)", "lambda.StartHandlerFunc(h)"} {
+		src := "package main\n\nimport \"github.com/aws/aws-lambda-go/lambda\"\n\nfunc main() {\n\t" + call + "\n}\n"
+		file := parseTestFile(t, src)
+		if isLambdaMain(file, common.FindNonEmptyMainFunc(file)) {
+			t.Errorf("%s must not be treated as a wrapped lambda main", call)
+		}
+	}
+}
@@ -87,16 +87,64 @@ func matchSignature(ctx *MatchContext, sig *FuncSignature) bool {
 	}
 	// go/types 없으면 → PASS (안전 방향, matching-layer.md Step 3-6)
 
+	// Type arguments of a generic instantiation. Unlike Args/Results this
+	// SKIPs without go/types, as the receiver check does: the filter exists
+	// to narrow a rule to some instantiations, so "cannot tell" must not
+	// mean "all of them".
+	if sig.TypeArgs != nil {
+		if !common.HasTypeInfo() || !matchTypeArgs(ctx, sig.TypeArgs) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matchTypeArgs checks the type arguments of the matched generic function
+// call, method call on an instantiated generic type, or composite literal.
+// Name "_" matches any type argument.
+func matchTypeArgs(ctx *MatchContext, expected []TypeName) bool {
+	actual := contextTypeArgs(ctx)
+	if len(actual) != len(expected) {
+		return false
+	}
+	for i, want := range expected {
+		if want.Name != "_" && !typeNameMatches(want, actual[i]) {
+			return false
+		}
+	}
 	return true
 }
 
+// contextTypeArgs returns the type arguments behind a match: the generic
+// function or type's own instantiation when it has one, else the receiver's
+// (repo.Repo[User].Find).
+func contextTypeArgs(ctx *MatchContext) []types.Type {
+	var fun dst.Expr
+	switch {
+	case ctx.Call != nil:
+		fun = stripInstantiation(ctx.Call.Fun)
+	case ctx.Lit != nil:
+		fun = stripInstantiation(ctx.Lit.Type)
+	default:
+		return nil
+	}
+	if args := common.InstanceTypeArgs(nameIdent(fun)); args != nil {
+		return args
+	}
+	if sel, ok := fun.(*dst.SelectorExpr); ok {
+		return common.NamedTypeArgs(sel.X)
+	}
+	return nil
+}
+
 // resolveFuncSignature extracts a *types.Signature from a call's SelectorExpr.
 // Returns nil if type info is unavailable or the expression doesn't resolve to a function.
 func resolveFuncSignature(call *dst.CallExpr) *types.Signature {
 	if call == nil {
 		return nil
 	}
-	sel, ok := call.Fun.(*dst.SelectorExpr)
+	sel, ok := stripInstantiation(call.Fun).(*dst.SelectorExpr)
 	if !ok {
 		return nil
 	}
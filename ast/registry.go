@@ -14,11 +14,100 @@ type Registry struct {
 	declWildcards []*Rule           // "decl:..." rules whose target contains "*"
 	blankImports  map[string]string // import path → whatap import (e.g. logrus)
 
+	// Call-site glob / "re:" targets (target_pattern.go), in registration
+	// order, the index Lookup narrows them with (built on first use), and a
+	// memo of the patterns matching each target: every call to the same
+	// function resolves to the same target, so each distinct target runs the
+	// patterns once. All matches are kept, in order — a rule the engine
+	// rejects (scope) falls through to the next one.
+	callPatterns []patternRule
+	patternIdx   *patternIndex
+	patternHits  map[string][]*Rule
+
+	// iface: / implements: targets (target_iface.go) and their memo, keyed
+	// by receiver type and method name, again with every matching rule.
+	ifaceRules []ifaceRule
+	ifaceHits  map[string][]*Rule
+
+	// valueRules counts "value:" rules (exact or pattern). The engine only
+	// resolves Ident/SelectorExpr references when there is at least one.
+	valueRules int
+
 	// §242 — package-path filter. Values are Rule.Target's extracted package
 	// path (e.g. "github.com/gin-gonic/gin", "fmt"). Replaces the former
 	// Name-based filter.
 	enabled  map[string]bool // user-requested opt-in packages (OptIn rules register only when listed here)
 	disabled map[string]bool // user-requested exclusion (rules register only when NOT listed here)
+
+	// scopes overrides Rule.Scope of built-in rules by package path
+	// (config instrumentation.rule_scopes).
+	scopes map[string]*RuleScope
+}
+
+// patternRule is a call-site rule with a compiled pattern target.
+type patternRule struct {
+	rule    *Rule
+	pattern *targetPattern
+}
+
+// patternIndex groups callPatterns by the first path segment of their
+// literal prefix ("github.com", "value:mycorp.com"), so a target only runs
+// the patterns of its own segment plus those whose prefix ends before the
+// first "/" ("**.Open", "re:.*Repo.*", "fmt.Print*"). Both lists hold
+// callPatterns indexes in registration order.
+type patternIndex struct {
+	bySegment map[string][]int
+	unkeyed   []int
+}
+
+// firstSegment returns s up to its first "/", or false when s has none.
+func firstSegment(s string) (string, bool) {
+	i := strings.Index(s, "/")
+	if i < 0 {
+		return "", false
+	}
+	return s[:i], true
+}
+
+func newPatternIndex(patterns []patternRule) *patternIndex {
+	idx := &patternIndex{bySegment: make(map[string][]int)}
+	for i, p := range patterns {
+		if seg, ok := firstSegment(p.pattern.prefix); ok {
+			idx.bySegment[seg] = append(idx.bySegment[seg], i)
+		} else {
+			idx.unkeyed = append(idx.unkeyed, i)
+		}
+	}
+	return idx
+}
+
+// candidates returns the indexes of the patterns that can match target, in
+// registration order. A pattern whose prefix holds a "/" matches only targets
+// with the same first segment; the rest are always candidates.
+func (idx *patternIndex) candidates(target string) []int {
+	seg, ok := firstSegment(target)
+	if !ok {
+		return idx.unkeyed
+	}
+	keyed := idx.bySegment[seg]
+	if len(keyed) == 0 {
+		return idx.unkeyed
+	}
+	if len(idx.unkeyed) == 0 {
+		return keyed
+	}
+	out := make([]int, 0, len(keyed)+len(idx.unkeyed))
+	i, j := 0, 0
+	for i < len(keyed) || j < len(idx.unkeyed) {
+		if j == len(idx.unkeyed) || (i < len(keyed) && keyed[i] < idx.unkeyed[j]) {
+			out = append(out, keyed[i])
+			i++
+		} else {
+			out = append(out, idx.unkeyed[j])
+			j++
+		}
+	}
+	return out
 }
 
 // NewRegistry creates an empty Registry.
@@ -34,7 +123,7 @@ func NewRegistry() *Registry {
 //   - if the rule's package is in `disabled`, skip
 //   - if the rule is OptIn and its package is NOT in `enabled`, skip
 func (r *Registry) Register(rule *Rule) {
-	pkg := ExtractRulePackage(rule.Target)
+	pkg := LiteralRulePackage(rule.Target)
 
 	if r.disabled != nil && r.disabled[pkg] {
 		return
@@ -44,6 +133,13 @@ func (r *Registry) Register(rule *Rule) {
 			return
 		}
 	}
+	if scope, ok := r.scopes[pkg]; ok {
+		// Built-in rules are shared (AllRules / the yaml override), so the
+		// scoped rule is a copy.
+		scoped := *rule
+		scoped.Scope = scope
+		rule = &scoped
+	}
 	r.registerInternal(rule)
 }
 
@@ -59,11 +155,39 @@ func (r *Registry) RegisterUser(rule *Rule) {
 // registerInternal is the shared registration body for both Register and
 // RegisterUser. It owns the wildcard split + reverse-target wiring.
 func (r *Registry) registerInternal(rule *Rule) {
+	if strings.HasPrefix(rule.Target, valuePrefix) {
+		r.valueRules++
+	}
 	// Wildcard decl: rules go into a separate slice; lookup iterates on miss.
 	if strings.HasPrefix(rule.Target, "decl:") && strings.Contains(rule.Target, "*") {
 		r.declWildcards = append(r.declWildcards, rule)
 		return
 	}
+	// Interface targets are matched against the receiver's type on a miss.
+	if isInterfaceTarget(rule.Target) {
+		t, err := parseInterfaceTarget(rule.Target)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[whatap-go-inst] skip rule: %v\n", err)
+			return
+		}
+		r.ifaceRules = append(r.ifaceRules, ifaceRule{rule: rule, target: t})
+		r.ifaceHits = nil
+		return
+	}
+	// Call-site patterns go into callPatterns. BuildRules rejects targets
+	// that do not compile, so a failure here can only come from a Go-declared
+	// rule — report it and drop the rule.
+	if isPatternTarget(rule.Target) {
+		pat, err := compileTargetPattern(rule.Target)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[whatap-go-inst] skip rule: %v\n", err)
+			return
+		}
+		r.callPatterns = append(r.callPatterns, patternRule{rule: rule, pattern: pat})
+		r.patternIdx = nil
+		r.patternHits = nil
+		return
+	}
 
 	r.rules[rule.Target] = rule
 	// §272 Phase 3 Step 2 (2026-05-19): reverse mapping for ModeRemove deleted.
@@ -74,21 +198,81 @@ func (r *Registry) registerInternal(rule *Rule) {
 }
 
 // Lookup returns the Rule for a target string (inject mode).
-// First checks exact matches, then falls back to decl wildcards.
+// First checks exact matches, then falls back to decl wildcards or, for
+// call-site targets, to the first registered pattern that matches.
 func (r *Registry) Lookup(target string) *Rule {
-	if rule, ok := r.rules[target]; ok {
+	return r.lookupAccepted(target, nil)
+}
+
+// lookupAccepted is Lookup passing over the rules accept rejects (nil accepts
+// every rule): a rejected exact rule falls through to the patterns, a
+// rejected pattern or decl wildcard to the next one that matches.
+func (r *Registry) lookupAccepted(target string, accept func(*Rule) bool) *Rule {
+	if rule, ok := r.rules[target]; ok && (accept == nil || accept(rule)) {
 		return rule
 	}
 	if strings.HasPrefix(target, "decl:") {
 		for _, rule := range r.declWildcards {
-			if matchDeclWildcard(rule.Target, target) {
+			if matchDeclWildcard(rule.Target, target) && (accept == nil || accept(rule)) {
 				return rule
 			}
 		}
+		return nil
+	}
+	// "range:" targets name a channel's element type, not a call site.
+	if strings.HasPrefix(target, rangePrefix) {
+		return nil
+	}
+	return firstAccepted(r.patternMatches(target), accept)
+}
+
+// patternMatches returns every call-site pattern rule matching target, in
+// registration order.
+func (r *Registry) patternMatches(target string) []*Rule {
+	if len(r.callPatterns) == 0 {
+		return nil
+	}
+	if hits, ok := r.patternHits[target]; ok {
+		return hits
+	}
+	// A pattern reaches "value:" targets only when it starts with "value:"
+	// itself, so call-site globs like "**.Open" never wrap references.
+	isValue := strings.HasPrefix(target, valuePrefix)
+	if r.patternIdx == nil {
+		r.patternIdx = newPatternIndex(r.callPatterns)
+	}
+	var hits []*Rule
+	for _, i := range r.patternIdx.candidates(target) {
+		p := r.callPatterns[i]
+		if strings.HasPrefix(p.rule.Target, valuePrefix) != isValue {
+			continue
+		}
+		if p.pattern.match(target) {
+			hits = append(hits, p.rule)
+		}
+	}
+	if r.patternHits == nil {
+		r.patternHits = make(map[string][]*Rule)
+	}
+	r.patternHits[target] = hits
+	return hits
+}
+
+// firstAccepted returns the first rule accept allows (nil accepts all).
+func firstAccepted(rules []*Rule, accept func(*Rule) bool) *Rule {
+	for _, rule := range rules {
+		if accept == nil || accept(rule) {
+			return rule
+		}
 	}
 	return nil
 }
 
+// hasValueRules reports whether any "value:" rule is registered.
+func (r *Registry) hasValueRules() bool {
+	return r.valueRules > 0
+}
+
 // matchDeclWildcard reports whether a "decl:pkgpath.funcName" target matches a
 // wildcard pattern like "decl:pkgpath.*" or "decl:*".
 //
@@ -143,6 +327,13 @@ func (r *Registry) SetPackageFilter(enabled, disabled []string) {
 	r.disabled = toSet(disabled)
 }
 
+// SetPackageScopes restricts built-in rules of the given package paths to
+// parts of the user's code (see RuleScope). Call before Register. User rules
+// are not affected — they carry their own `scope:`.
+func (r *Registry) SetPackageScopes(scopes map[string]*RuleScope) {
+	r.scopes = scopes
+}
+
 func toSet(paths []string) map[string]bool {
 	if len(paths) == 0 {
 		return nil
@@ -179,7 +370,7 @@ func ValidatePackageFilter(enabled, disabled []string, builtinRules []*Rule) {
 		if rule == nil {
 			continue
 		}
-		pkg := ExtractRulePackage(rule.Target)
+		pkg := LiteralRulePackage(rule.Target)
 		if pkg != "" {
 			known[pkg] = true
 		}
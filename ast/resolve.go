@@ -1,6 +1,8 @@
 package ast
 
 import (
+	"go/token"
+
 	"github.com/dave/dst"
 	"github.com/whatap/go-api-inst/ast/common"
 )
@@ -8,22 +10,77 @@ import (
 // resolveTarget converts a dst.Node to a Target string using go/types.
 // Returns "" if the node is not resolvable or not a target we care about.
 //
-// Handles four patterns:
+// Handles six patterns:
 //   - CallExpr with SelectorExpr: pkg.Func() or receiver.Method()
 //   - CompositeLit with SelectorExpr: pkg.Type{}
+//   - AssignStmt to a struct field: x.Field = v → "pkg.Type.Field="
 //   - FuncDecl: function/method declaration → "decl:name" or "decl:pkg.Type.Method"
+//   - Ident / SelectorExpr naming a function or method: "value:pkg.Func" or
+//     "value:pkg.Type.Method". The engine only asks for these outside call
+//     position (a call's Fun resolves through the CallExpr).
+//   - RangeStmt over a channel of pkg.Type / *pkg.Type: "range:pkg.Type"
 func resolveTarget(node dst.Node) string {
 	switch n := node.(type) {
 	case *dst.CallExpr:
 		return resolveCallTarget(n)
+	case *dst.Ident, *dst.SelectorExpr:
+		return resolveValueTarget(n.(dst.Expr))
 	case *dst.CompositeLit:
 		return resolveLitTarget(n)
+	case *dst.AssignStmt:
+		return resolveAssignTarget(n)
 	case *dst.FuncDecl:
 		return resolveFuncDeclTarget(n)
+	case *dst.RangeStmt:
+		return resolveRangeTarget(n)
 	}
 	return ""
 }
 
+// rangePrefix marks targets of range loops over a channel.
+const rangePrefix = "range:"
+
+// resolveRangeTarget resolves a range loop over a channel to the channel's
+// element type:
+//
+//	for m := range ch { ... }  (ch chan *nats.Msg) → "range:github.com/nats-io/nats.go.Msg"
+//
+// It is the receive a consumer loop anchors on when no call feeds the loop
+// (ChanSubscribe fills ch elsewhere). A range over a call is left to the
+// call's own target — claim.Messages() resolves through its CallExpr.
+func resolveRangeTarget(rng *dst.RangeStmt) string {
+	if _, ok := rng.X.(*dst.CallExpr); ok {
+		return ""
+	}
+	pkgPath, typeName, ok := common.ChanElemTypeOf(rng.X)
+	if !ok {
+		return ""
+	}
+	return rangePrefix + pkgPath + "." + typeName
+}
+
+// resolveAssignTarget resolves a single-value field assignment to a Target string.
+// Example: c.Transport = rt (c *http.Client) → "net/http.Client.Transport="
+//
+// Only the plain `x.F = v` form is resolved (one LHS, one RHS, token `=`) and
+// only when go/types confirms F is a struct field of x's named type. Tuple
+// assignments and `op=` forms are left alone — wrapping one side of
+// `a.T, b = f()` has no single expression to wrap.
+func resolveAssignTarget(assign *dst.AssignStmt) string {
+	if assign.Tok != token.ASSIGN || len(assign.Lhs) != 1 || len(assign.Rhs) != 1 {
+		return ""
+	}
+	sel, ok := assign.Lhs[0].(*dst.SelectorExpr)
+	if !ok {
+		return ""
+	}
+	pkgPath, typeName, ok := common.FieldOwnerOf(sel)
+	if !ok {
+		return ""
+	}
+	return pkgPath + "." + typeName + "." + sel.Sel.Name + "="
+}
+
 // resolveCallTarget resolves a call expression to a Target string.
 // Handles three patterns:
 //
@@ -32,19 +89,9 @@ func resolveTarget(node dst.Node) string {
 //	C. funcName(args)               — bare identifier referring to a (local
 //	                                  or dot-imported) function (§227 Step 5)
 func resolveCallTarget(call *dst.CallExpr) string {
-	switch fun := call.Fun.(type) {
+	switch fun := stripInstantiation(call.Fun).(type) {
 	case *dst.SelectorExpr:
-		// Try Pattern A first: pkg.Func(args) — ident is a package reference
-		if ident, ok := fun.X.(*dst.Ident); ok {
-			target := resolveIdentTarget(ident, fun.Sel.Name)
-			if target != "" {
-				return target
-			}
-			// ident is a local variable (not a package) → fall through to method call
-		}
-		// Pattern B: receiver.Method(args) — sel.X is a variable/expression
-		// Use go/types to resolve receiver type
-		return resolveMethodTarget(fun)
+		return resolveSelectorTarget(fun)
 	case *dst.Ident:
 		// Pattern C: bare-identifier function call. Resolve via go/types Uses
 		// to obtain the function's full importpath.FuncName form. Returns ""
@@ -55,6 +102,85 @@ func resolveCallTarget(call *dst.CallExpr) string {
 	return ""
 }
 
+// stripInstantiation removes an explicit instantiation from a callee or
+// composite-literal type: cache.Get[User] → cache.Get, repo.Page[K, V] →
+// repo.Page. Targets never carry type arguments — rules filter on them with
+// Signature.TypeArgs. An index expression that is not an instantiation
+// (handlers[i](w, r) calls an element of a slice of funcs) is returned as is.
+func stripInstantiation(expr dst.Expr) dst.Expr {
+	var x dst.Expr
+	switch e := expr.(type) {
+	case *dst.IndexExpr:
+		x = e.X
+	case *dst.IndexListExpr:
+		x = e.X
+	default:
+		return expr
+	}
+	if len(common.InstanceTypeArgs(nameIdent(x))) == 0 {
+		return expr
+	}
+	return x
+}
+
+// nameIdent returns the identifier naming a function or type: the Ident
+// itself, or the Sel of pkg.Name / x.Method. nil otherwise.
+func nameIdent(expr dst.Expr) *dst.Ident {
+	switch e := expr.(type) {
+	case *dst.Ident:
+		return e
+	case *dst.SelectorExpr:
+		return e.Sel
+	}
+	return nil
+}
+
+// resolveSelectorTarget resolves the selector of a call or value reference:
+// pkg.Func (Pattern A) or receiver.Method (Pattern B).
+func resolveSelectorTarget(sel *dst.SelectorExpr) string {
+	// Try Pattern A first: pkg.Func(args) — ident is a package reference
+	if ident, ok := sel.X.(*dst.Ident); ok {
+		target := resolveIdentTarget(ident, sel.Sel.Name)
+		if target != "" {
+			return target
+		}
+		// ident is a local variable (not a package) → fall through to method call
+	}
+	// Pattern B: receiver.Method(args) — sel.X is a variable/expression
+	// Use go/types to resolve receiver type
+	return resolveMethodTarget(sel)
+}
+
+// valuePrefix marks targets of functions and methods referenced as values.
+const valuePrefix = "value:"
+
+// resolveValueTarget resolves a function or method referenced as a value:
+//
+//	opener := sql.Open          → "value:database/sql.Open"
+//	http.HandleFunc("/", h.Get) → "value:myapp.Handler.Get" (method value)
+//	do := (*http.Client).Do     → "value:net/http.Client.Do" (method expression)
+//	run(localFunc)              → "value:myapp.localFunc"
+//
+// Requires go/types: without it, nothing tells a function apart from a
+// variable of func type, so the reference is left alone.
+func resolveValueTarget(expr dst.Expr) string {
+	var target string
+	switch n := expr.(type) {
+	case *dst.Ident:
+		if common.IsFuncRef(n) {
+			target = common.GetIdentFuncTarget(n)
+		}
+	case *dst.SelectorExpr:
+		if common.IsFuncRef(n.Sel) {
+			target = resolveSelectorTarget(n)
+		}
+	}
+	if target == "" {
+		return ""
+	}
+	return valuePrefix + target
+}
+
 // resolveIdentTarget resolves pkg.Name where ident is a package identifier.
 //
 // Inject mode (type info available): uses go/types to obtain full import path.
@@ -98,8 +224,9 @@ func resolveMethodTarget(sel *dst.SelectorExpr) string {
 // resolveLitTarget resolves a composite literal to a Target string.
 // Example: &http.Client{} → "net/http.Client{}"
 func resolveLitTarget(lit *dst.CompositeLit) string {
-	// Handle &pkg.Type{} — the & is in parent UnaryExpr, CompositeLit has Type
-	sel, ok := lit.Type.(*dst.SelectorExpr)
+	// Handle &pkg.Type{} — the & is in parent UnaryExpr, CompositeLit has Type.
+	// pkg.Type[Arg]{} resolves like pkg.Type{}.
+	sel, ok := stripInstantiation(lit.Type).(*dst.SelectorExpr)
 	if !ok {
 		return ""
 	}
@@ -1,6 +1,8 @@
 package ast
 
 import (
+	"fmt"
+
 	"github.com/dave/dst"
 	"github.com/whatap/go-api-inst/ast/common"
 )
@@ -24,6 +26,10 @@ type FuncSignature struct {
 	Results []TypeName // nil = skip return type check
 	MinArgs int        // -1 = derive from len(Args); 0+ = minimum arg count
 	MaxArgs int        // -1 = unlimited (variadic); 0+ = maximum arg count
+	// TypeArgs filters generic instantiations: the called function's type
+	// arguments, or the receiver's for methods of generic types. nil = skip;
+	// non-nil = count + types, Name "_" matches any type. SKIP without go/types.
+	TypeArgs []TypeName
 }
 
 // FieldMatch specifies a struct field existence check for CompositeLit matching
@@ -42,6 +48,11 @@ type FieldMatch struct {
 //   - Fields:    struct field existence for CompositeLit (Step 3-9)
 //   - Condition: custom predicate (Step 3-10)
 //
+// Scope restricts where in the user's code the rule applies (package
+// patterns / file globs, see RuleScope); nil = everywhere. When gates
+// registration on a dependency version from go.mod (see ModuleCondition),
+// Build on the target platform and tags (see BuildCondition).
+//
 // OptIn controls default registration. Zero value (false) means the rule is
 // registered by default. Rules with OptIn=true are only registered when the
 // user lists the rule's package path in `enabled_packages`. §242 — fmt.Print*
@@ -50,13 +61,23 @@ type FieldMatch struct {
 type Rule struct {
 	Target string // e.g. "database/sql.Open", "net/http.Client{}"
 	Advice Advice
-	OptIn  bool // §242 — true = opt-in required via enabled_packages
+	OptIn  bool   // §242 — true = opt-in required via enabled_packages
+	ID     string // yaml `id:` — names the rule in diagnostics ("" = use Target)
 
 	// Optional filters — nil means PASS (skip the check)
 	Signature *FuncSignature           // Steps 3-4, 3-5, 3-6, 3-8
 	Receiver  *TypeName                // Step 3-7
 	Fields    []FieldMatch             // Step 3-9
 	Condition func(*MatchContext) bool // Step 3-10
+
+	Scope *RuleScope       // yaml `scope:` or config rule_scopes — nil = everywhere
+	When  *ModuleCondition // yaml `when:` — nil = always registered
+	Build *BuildCondition  // yaml `build:` — nil = every platform
+
+	// DryRunErr is set when a code template failed its load-time dry run.
+	// The injector leaves the rule out and reports it; the other rules of
+	// the config still load.
+	DryRunErr error
 }
 
 // MatchContext carries all context needed for an Advice transformation.
@@ -71,6 +92,15 @@ type MatchContext struct {
 	Call *dst.CallExpr     // non-nil for function/method call matches
 	Lit  *dst.CompositeLit // non-nil for composite literal matches
 	Decl *dst.FuncDecl     // non-nil for function declaration matches ("decl:..." targets)
+	// Assign is non-nil for struct-field assignment matches ("pkg.Type.Field=" targets).
+	// Sel is the LHS selector; the value is Assign.Rhs[0].
+	Assign *dst.AssignStmt
+	// Ref is non-nil for function/method value matches ("value:..." targets):
+	// the *dst.Ident or *dst.SelectorExpr referencing the function outside
+	// call position.
+	Ref dst.Expr
+	// Range is non-nil for range-over-channel matches ("range:..." targets).
+	Range *dst.RangeStmt
 
 	Ident *dst.Ident        // the package identifier (for renaming)
 	Sel   *dst.SelectorExpr // the selector expression
@@ -89,10 +119,62 @@ type MatchContext struct {
 	// Extra imports (populated by Transform Advice for multi-import support)
 	ExtraImports map[string]string // import path → alias
 
+	// Blocks are statement blocks the Advice created around existing code
+	// (LoopBody's per-iteration func literal). The engine processes them like
+	// nested blocks so statement-level Advice still applies inside.
+	Blocks []*dst.BlockStmt
+
 	// Applied indicates whether the Advice actually performed a transformation.
 	// Advice types that may skip (e.g., MainInsert when not in main()) set this to false.
 	// Engine checks this before collecting imports.
 	Applied bool
+
+	// Err is set (together with Applied=false) when the Advice could not be
+	// applied because of the rule itself — a template that fails to execute
+	// or expands to code that does not parse. The engine records it as a
+	// RuleError instead of silently skipping the match.
+	Err error
+}
+
+// matchedNode returns the node the rule matched (call, declaration,
+// composite literal, assignment, value reference or range loop), or nil.
+func (ctx *MatchContext) matchedNode() dst.Node {
+	switch {
+	case ctx.Call != nil:
+		return ctx.Call
+	case ctx.Decl != nil:
+		return ctx.Decl
+	case ctx.Lit != nil:
+		return ctx.Lit
+	case ctx.Assign != nil:
+		return ctx.Assign
+	case ctx.Ref != nil:
+		return ctx.Ref
+	case ctx.Range != nil:
+		return ctx.Range
+	}
+	return nil
+}
+
+// RuleError is a MatchContext.Err collected by the engine, with the rule and
+// source position it belongs to. Line is 0 when no position is available
+// (no type context for the file).
+type RuleError struct {
+	RuleID string // Rule.ID, or Rule.Target when the rule has no id
+	Target string
+	File   string
+	Line   int
+	Err    error
+
+	node dst.Node // matched node, for de-duplication
+}
+
+func (e RuleError) Error() string {
+	loc := e.File
+	if e.Line > 0 {
+		loc = fmt.Sprintf("%s:%d", e.File, e.Line)
+	}
+	return fmt.Sprintf("%s: rule %s: %v", loc, e.RuleID, e.Err)
 }
 
 // AddImport adds an import to the file.
@@ -0,0 +1,119 @@
+package ast
+
+import (
+	"fmt"
+	"go/build"
+	"go/build/constraint"
+	"os"
+	"strings"
+)
+
+// BuildCondition is a rule's `build:` clause — a //go:build expression such
+// as "linux && !cgo". The rule registers only when the expression holds for
+// the build being instrumented (BuildContext), so a wrapper that compiles
+// on linux only is left out of a darwin cross-compile.
+type BuildCondition struct {
+	Expr string
+
+	expr constraint.Expr
+}
+
+// parseBuildCondition parses a `build:` expression. "//go:build" may be
+// written or left out.
+func parseBuildCondition(s string) (*BuildCondition, error) {
+	line := strings.TrimSpace(s)
+	if line == "" {
+		return nil, fmt.Errorf("build: empty expression")
+	}
+	if !constraint.IsGoBuild(line) {
+		line = "//go:build " + line
+	}
+	expr, err := constraint.Parse(line)
+	if err != nil {
+		return nil, fmt.Errorf("build %q: %v", s, err)
+	}
+	return &BuildCondition{Expr: strings.TrimSpace(strings.TrimPrefix(line, "//go:build")), expr: expr}, nil
+}
+
+// BuildContext is the target of the build being instrumented.
+type BuildContext struct {
+	GOOS       string
+	GOARCH     string
+	CgoEnabled bool
+	Tags       []string // -tags
+}
+
+// DefaultBuildContext describes the build configured by the environment
+// (GOOS / GOARCH / CGO_ENABLED, falling back to the host) with the given
+// -tags. toolexec runs with the environment of the go command it serves, so
+// this is the compile invocation's target.
+func DefaultBuildContext(tags []string) *BuildContext {
+	ctx := &BuildContext{
+		GOOS:       build.Default.GOOS,
+		GOARCH:     build.Default.GOARCH,
+		CgoEnabled: build.Default.CgoEnabled,
+		Tags:       tags,
+	}
+	if v := os.Getenv("CGO_ENABLED"); v != "" {
+		ctx.CgoEnabled = v == "1"
+	}
+	return ctx
+}
+
+// unixOS lists the GOOS values satisfying the "unix" tag (go/build's list).
+var unixOS = map[string]bool{
+	"aix": true, "android": true, "darwin": true, "dragonfly": true, "freebsd": true,
+	"hurd": true, "illumos": true, "ios": true, "linux": true, "netbsd": true,
+	"openbsd": true, "solaris": true,
+}
+
+// matchTag reports whether tag is satisfied, following go/build: GOOS and
+// GOARCH (android implies linux, ios darwin, illumos solaris), "unix",
+// "cgo", "gc", the toolchain's goN.M release tags and -tags.
+func (c *BuildContext) matchTag(tag string) bool {
+	switch {
+	case tag == c.GOOS || tag == c.GOARCH || tag == "gc":
+		return true
+	case tag == "unix":
+		return unixOS[c.GOOS]
+	case tag == "cgo":
+		return c.CgoEnabled
+	case tag == "linux" && c.GOOS == "android",
+		tag == "darwin" && c.GOOS == "ios",
+		tag == "solaris" && c.GOOS == "illumos":
+		return true
+	}
+	for _, t := range build.Default.ReleaseTags {
+		if tag == t {
+			return true
+		}
+	}
+	for _, t := range c.Tags {
+		if tag == t {
+			return true
+		}
+	}
+	return false
+}
+
+// String describes the context in skip reasons.
+func (c *BuildContext) String() string {
+	s := "GOOS=" + c.GOOS + " GOARCH=" + c.GOARCH
+	if c.CgoEnabled {
+		s += " cgo"
+	} else {
+		s += " !cgo"
+	}
+	if len(c.Tags) > 0 {
+		s += " tags=" + strings.Join(c.Tags, ",")
+	}
+	return s
+}
+
+// check evaluates the condition; reason explains a false.
+func (b *BuildCondition) check(ctx *BuildContext) (ok bool, reason string) {
+	if b.expr.Eval(ctx.matchTag) {
+		return true, ""
+	}
+	return false, fmt.Sprintf("build constraint %q not satisfied (%s)", b.Expr, ctx)
+}
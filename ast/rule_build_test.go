@@ -0,0 +1,98 @@
+package ast
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/whatap/go-api-inst/config"
+	"gopkg.in/yaml.v3"
+)
+
+func TestBuildCondition(t *testing.T) {
+	linux := &BuildContext{GOOS: "linux", GOARCH: "amd64", CgoEnabled: true}
+	linuxNoCgo := &BuildContext{GOOS: "linux", GOARCH: "arm64"}
+	darwin := &BuildContext{GOOS: "darwin", GOARCH: "arm64", CgoEnabled: true, Tags: []string{"integration"}}
+	android := &BuildContext{GOOS: "android", GOARCH: "arm64"}
+	cases := []struct {
+		expr string
+		ctx  *BuildContext
+		want bool
+	}{
+		{"linux", linux, true},
+		{"linux", darwin, false},
+		{"linux", android, true}, // android implies linux
+		{"linux && !cgo", linux, false},
+		{"linux && !cgo", linuxNoCgo, true},
+		{"amd64 || arm64", darwin, true},
+		{"unix", darwin, true},
+		{"//go:build integration", darwin, true},
+		{"integration", linux, false},
+		{"go1.1", linux, true}, // release tags of the toolchain
+		{"!windows && !plan9", linuxNoCgo, true},
+	}
+	for _, tc := range cases {
+		b, err := parseBuildCondition(tc.expr)
+		if err != nil {
+			t.Fatalf("parse %q: %v", tc.expr, err)
+		}
+		if ok, reason := b.check(tc.ctx); ok != tc.want {
+			t.Errorf("%q on %s = %v (%s), want %v", tc.expr, tc.ctx, ok, reason, tc.want)
+		}
+	}
+	b, _ := parseBuildCondition("//go:build linux && !cgo")
+	if _, reason := b.check(linux); reason != `build constraint "linux && !cgo" not satisfied (GOOS=linux GOARCH=amd64 cgo)` {
+		t.Errorf("reason = %q", reason)
+	}
+}
+
+// TestInjector_BuildClause drops user rules whose `build:` does not hold
+// for the injector's BuildContext.
+func TestInjector_BuildClause(t *testing.T) {
+	var cfg config.Config
+	if err := yaml.Unmarshal([]byte(`
+rules:
+  - id: epoll-only
+    type: hook
+    target: "mycorp.com/netpoll.Open"
+    before: 'trace()'
+    build: "linux && !cgo"
+  - id: anywhere
+    type: hook
+    target: "mycorp.com/netpoll.Close"
+    before: 'trace()'
+`), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		ctx        *BuildContext
+		registered bool
+	}{
+		{&BuildContext{GOOS: "linux", GOARCH: "amd64"}, true},
+		{&BuildContext{GOOS: "darwin", GOARCH: "arm64"}, false},
+		{&BuildContext{GOOS: "linux", GOARCH: "amd64", CgoEnabled: true}, false},
+	} {
+		inj := NewInjector()
+		inj.BuildContext = tc.ctx
+		inj.SetConfig(&cfg)
+		if got := inj.registry.Lookup("mycorp.com/netpoll.Open") != nil; got != tc.registered {
+			t.Errorf("%s: epoll-only registered = %v, want %v", tc.ctx, got, tc.registered)
+		}
+		if inj.registry.Lookup("mycorp.com/netpoll.Close") == nil {
+			t.Errorf("%s: rule without build: must register", tc.ctx)
+		}
+		if skips := inj.SkippedRules(); !tc.registered && (len(skips) != 1 || skips[0].RuleID != "epoll-only") {
+			t.Errorf("%s: SkippedRules = %+v", tc.ctx, skips)
+		}
+	}
+}
+
+func TestBuildRules_BuildErrors(t *testing.T) {
+	for _, expr := range []string{"linux &&", "linux || (cgo", "   "} {
+		_, err := BuildRules(&RulesConfig{Rules: []RuleSpec{{
+			Type: "hook", Target: "mycorp.com/netpoll.Open", Before: "x()", Build: expr,
+		}}})
+		if err == nil || !strings.Contains(err.Error(), "build") {
+			t.Errorf("build %q: err = %v", expr, err)
+		}
+	}
+}
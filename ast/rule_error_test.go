@@ -0,0 +1,190 @@
+package ast
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dave/dst"
+	"github.com/whatap/go-api-inst/config"
+	"gopkg.in/yaml.v3"
+)
+
+// ruleErrorResolve resolves `audit.Log` and `db.Query` calls by their text.
+func ruleErrorResolve(n dst.Node) string {
+	call, ok := n.(*dst.CallExpr)
+	if !ok {
+		return ""
+	}
+	sel, ok := call.Fun.(*dst.SelectorExpr)
+	if !ok {
+		return ""
+	}
+	if x, ok := sel.X.(*dst.Ident); ok && (x.Name == "audit" || x.Name == "db") {
+		return x.Name + "." + sel.Sel.Name
+	}
+	return ""
+}
+
+// TestEngine_RecordsRuleErrors checks that template execute and parse
+// failures become RuleErrors (one per match, keyed by rule ID or target)
+// and leave the matched calls untouched.
+func TestEngine_RecordsRuleErrors(t *testing.T) {
+	reg := NewRegistry()
+	reg.RegisterUser(&Rule{Target: "audit.Log", ID: "audit-hook", Advice: &Hook{Before: `begin({{index .ArgsList 3}})`}})
+	reg.RegisterUser(&Rule{Target: "db.Query", Advice: &Transform{Template: `wrap({{.Original}}`}})
+	src := `package p
+
+func f() {
+	audit.Log(a)
+	rows := db.Query(q)
+	if ok {
+		audit.Log(b)
+	}
+	_ = rows
+}
+`
+	file := parseTestFile(t, src)
+	engine := NewEngine(reg, ModeInject, ruleErrorResolve)
+	if engine.Process(file) {
+		t.Error("Process reported a transformation")
+	}
+	got := fileToString(t, file)
+	for _, want := range []string{"audit.Log(a)", "rows := db.Query(q)", "audit.Log(b)"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("want %q untouched in:\n%s", want, got)
+		}
+	}
+
+	errs := engine.RuleErrors()
+	if len(errs) != 3 {
+		t.Fatalf("RuleErrors() = %v, want 3", errs)
+	}
+	var hooks, transforms int
+	for _, re := range errs {
+		switch re.RuleID {
+		case "audit-hook":
+			hooks++
+			if !strings.Contains(re.Err.Error(), "before:") || !strings.Contains(re.Err.Error(), "index out of range") {
+				t.Errorf("hook error = %v", re.Err)
+			}
+		case "db.Query":
+			transforms++
+			if !strings.Contains(re.Err.Error(), "expanded code does not parse") {
+				t.Errorf("transform error = %v", re.Err)
+			}
+		default:
+			t.Errorf("unexpected RuleID %q", re.RuleID)
+		}
+	}
+	if hooks != 2 || transforms != 1 {
+		t.Errorf("hooks=%d transforms=%d, want 2/1", hooks, transforms)
+	}
+}
+
+func TestInjectAdvice_ParseErrorSetsErr(t *testing.T) {
+	file := parseTestFile(t, "package p\nfunc Do() {}\n")
+	fn := findFuncDecl(file, "Do")
+	ctx := &MatchContext{File: file, Mode: ModeInject, Decl: fn, FuncName: "Do", EnclosingFunc: fn}
+	(&Inject{Start: `println({{quote .FuncName}}`}).Apply(ctx)
+	if ctx.Applied || ctx.Err == nil || !strings.HasPrefix(ctx.Err.Error(), "start: expanded code does not parse") {
+		t.Fatalf("Applied=%v Err=%v", ctx.Applied, ctx.Err)
+	}
+}
+
+// TestInjectFile_FailOnRuleError runs the injector on a file whose custom
+// rule fails at the match: the default records the error and writes the
+// file, fail_on_rule_error returns ErrRuleFailed.
+func TestInjectFile_FailOnRuleError(t *testing.T) {
+	dir := t.TempDir()
+	srcPath := filepath.Join(dir, "main.go")
+	src := "package main\n\nfunc Do(a int) {}\n"
+	if err := os.WriteFile(srcPath, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var cfg config.Config
+	if err := yaml.Unmarshal([]byte(`
+rules:
+  - id: do-inject
+    type: inject
+    target: "decl:Do"
+    start: 'println({{.Arg0}})'
+`), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	// The yaml template passes the dry run; break it after load so the
+	// failure happens at the match.
+	newInjector := func() *Injector {
+		inj := NewInjector()
+		inj.SetConfig(&cfg)
+		for _, r := range inj.Rules() {
+			if r.ID == "do-inject" {
+				r.Advice.(*Inject).Start = `println({{.Arg0}}`
+			}
+		}
+		return inj
+	}
+
+	if err := newInjector().InjectFile(srcPath, filepath.Join(dir, "out.go")); err != nil {
+		t.Fatalf("default mode: %v", err)
+	}
+
+	cfg.Instrumentation.FailOnRuleError = true
+	err := newInjector().InjectFile(srcPath, filepath.Join(dir, "out2.go"))
+	if !errors.Is(err, ErrRuleFailed) || !strings.Contains(err.Error(), "rule do-inject") {
+		t.Fatalf("strict mode err = %v", err)
+	}
+}
+
+// TestBuildRegistry_DryRunSkipsOnlyThatRule — a template that fails its
+// load-time dry run leaves that rule out (reported as a skipped rule) while
+// the config's other rules still register.
+func TestBuildRegistry_DryRunSkipsOnlyThatRule(t *testing.T) {
+	var cfg config.Config
+	if err := yaml.Unmarshal([]byte(`
+importAliases:
+  whatapdb: "github.com/whatap/go-api/sql"
+rules:
+  - id: bad-wrap
+    type: transform
+    target: "github.com/example/db.Client.Query"
+    template: 'whatapdb.Wrap({{.Ctx}}, ...)'
+  - id: do-hook
+    type: hook
+    target: "mypkg.Do"
+    before: 'println({{quote .FuncName}})'
+`), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	inj := NewInjector()
+	inj.SetConfig(&cfg)
+
+	var ids []string
+	for _, r := range inj.Rules() {
+		if r.ID != "" {
+			ids = append(ids, r.ID)
+		}
+	}
+	if strings.Join(ids, ",") != "do-hook" {
+		t.Errorf("registered user rules = %v, want [do-hook]", ids)
+	}
+	skips := inj.SkippedRules()
+	if len(skips) != 1 || skips[0].RuleID != "bad-wrap" || !skips[0].Template || !strings.Contains(skips[0].Reason, "dry run") {
+		t.Fatalf("skipped = %+v", skips)
+	}
+
+	// fail_on_rule_error still turns the dry-run failure into a build error.
+	cfg.Instrumentation.FailOnRuleError = true
+	inj = NewInjector()
+	inj.SetConfig(&cfg)
+	dir := t.TempDir()
+	srcPath := filepath.Join(dir, "main.go")
+	if err := os.WriteFile(srcPath, []byte("package main\n\nfunc main() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := inj.InjectFile(srcPath, filepath.Join(dir, "out.go")); !errors.Is(err, ErrRuleFailed) || !strings.Contains(err.Error(), "rule bad-wrap") {
+		t.Errorf("strict mode err = %v", err)
+	}
+}
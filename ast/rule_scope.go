@@ -0,0 +1,158 @@
+package ast
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// RuleScope restricts a rule to parts of the user's code — where the match
+// happens, not which library it targets (that is the package filter's job).
+// nil = everywhere.
+//
+// Each pattern is either a package pattern or, with a "file:" prefix, a
+// file glob:
+//
+//	./internal/api/...      the module's internal/api and every package below
+//	./cmd/cli               exactly that package
+//	mycorp.com/app/...      import-path pattern ("..." matches any string)
+//	file:**/*_handler.go    file path relative to the project root (doublestar);
+//	file:*_gen.go           a glob without "/" matches the base name
+//
+// A rule applies when Include is empty or some Include pattern matches, and
+// no Exclude pattern matches.
+type RuleScope struct {
+	Include []string
+	Exclude []string
+
+	// pkgRes holds the compiled "..." package patterns, keyed by the pattern
+	// with any leading "." dropped. validateScope fills it; a scope that was
+	// never validated compiles on every match.
+	pkgRes map[string]*regexp.Regexp
+}
+
+// ScopeSite is where the engine is applying rules: the package being built
+// and the file being processed.
+type ScopeSite struct {
+	ImportPath string // e.g. TOOLEXEC_IMPORTPATH; "" = unknown
+	ModulePath string // main module path, resolves "./" patterns; "" = unknown
+	File       string // slash path relative to the project root
+}
+
+const scopeFilePrefix = "file:"
+
+// Allows reports whether the rule may apply at site. An unknown import path
+// or module path matches no package pattern.
+func (s *RuleScope) Allows(site ScopeSite) bool {
+	if s == nil {
+		return true
+	}
+	for _, p := range s.Exclude {
+		if s.matchPattern(p, site) {
+			return false
+		}
+	}
+	if len(s.Include) == 0 {
+		return true
+	}
+	for _, p := range s.Include {
+		if s.matchPattern(p, site) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *RuleScope) matchPattern(pattern string, site ScopeSite) bool {
+	if glob, ok := strings.CutPrefix(pattern, scopeFilePrefix); ok {
+		if site.File == "" {
+			return false
+		}
+		if ok, _ := doublestar.Match(glob, site.File); ok {
+			return true
+		}
+		if !strings.Contains(glob, "/") {
+			ok, _ := doublestar.Match(glob, path.Base(site.File))
+			return ok
+		}
+		return false
+	}
+	if site.ImportPath == "" {
+		return false
+	}
+	importPath := site.ImportPath
+	if rel, ok := relativePackagePattern(pattern); ok {
+		// "./internal/..." matches below the main module: compare what
+		// follows the module path with what follows the ".".
+		if site.ModulePath == "" {
+			return false
+		}
+		rest, ok := strings.CutPrefix(importPath, site.ModulePath)
+		if !ok {
+			return false
+		}
+		pattern, importPath = rel, rest
+	}
+	if !strings.Contains(pattern, "...") {
+		return pattern == importPath
+	}
+	// A trailing "/..." also matches the bare prefix ("net/..." matches "net").
+	if prefix, ok := strings.CutSuffix(pattern, "/..."); ok && importPath == prefix {
+		return true
+	}
+	re := s.pkgRes[pattern]
+	if re == nil {
+		re = compilePackagePattern(pattern)
+	}
+	return re.MatchString(importPath)
+}
+
+// relativePackagePattern returns a "." or "./..." pattern without its ".".
+func relativePackagePattern(pattern string) (string, bool) {
+	if pattern == "." || strings.HasPrefix(pattern, "./") {
+		return pattern[1:], true
+	}
+	return "", false
+}
+
+// compilePackagePattern implements `go list` pattern matching: "..." matches
+// any string. Every other character is literal, so it always compiles.
+func compilePackagePattern(pattern string) *regexp.Regexp {
+	re := regexp.QuoteMeta(pattern)
+	re = strings.ReplaceAll(re, `\.\.\.`, `.*`)
+	return regexp.MustCompile("^" + re + "$")
+}
+
+// validateScope rejects empty patterns and malformed file globs at load time,
+// and compiles the "..." package patterns once for Allows.
+func validateScope(s *RuleScope) error {
+	if s == nil {
+		return nil
+	}
+	for _, list := range [][]string{s.Include, s.Exclude} {
+		for _, p := range list {
+			glob, isFile := strings.CutPrefix(p, scopeFilePrefix)
+			switch {
+			case strings.TrimSpace(p) == "" || (isFile && glob == ""):
+				return fmt.Errorf("scope: empty pattern")
+			case isFile && !doublestar.ValidatePattern(glob):
+				return fmt.Errorf("scope: invalid file glob %q", glob)
+			case isFile:
+				continue
+			}
+			if rel, ok := relativePackagePattern(p); ok {
+				p = rel
+			}
+			if strings.Contains(p, "...") {
+				if s.pkgRes == nil {
+					s.pkgRes = make(map[string]*regexp.Regexp)
+				}
+				s.pkgRes[p] = compilePackagePattern(p)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+package ast
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/whatap/go-api-inst/config"
+)
+
+func TestRuleScope_Allows(t *testing.T) {
+	site := func(pkg, file string) ScopeSite {
+		return ScopeSite{ImportPath: pkg, ModulePath: "mycorp.com/app", File: file}
+	}
+	cases := []struct {
+		scope *RuleScope
+		site  ScopeSite
+		want  bool
+	}{
+		{nil, ScopeSite{}, true},
+		{&RuleScope{Include: []string{"./internal/api/..."}}, site("mycorp.com/app/internal/api", "internal/api/h.go"), true},
+		{&RuleScope{Include: []string{"./internal/api/..."}}, site("mycorp.com/app/internal/api/v2", "internal/api/v2/h.go"), true},
+		{&RuleScope{Include: []string{"./internal/api/..."}}, site("mycorp.com/app/internal/apix", "internal/apix/h.go"), false},
+		{&RuleScope{Include: []string{"./internal/api/..."}}, ScopeSite{File: "internal/api/h.go"}, false}, // unknown package
+		{&RuleScope{Include: []string{"."}}, site("mycorp.com/app", "main.go"), true},
+		{&RuleScope{Include: []string{"mycorp.com/.../api"}}, site("mycorp.com/app/internal/api", ""), true},
+		{&RuleScope{Exclude: []string{"./cmd/cli"}}, site("mycorp.com/app/cmd/cli", "cmd/cli/main.go"), false},
+		{&RuleScope{Exclude: []string{"./cmd/cli"}}, site("mycorp.com/app/cmd/cli/sub", "cmd/cli/sub/x.go"), true},
+		{&RuleScope{Exclude: []string{"./cmd/cli"}}, ScopeSite{}, true},
+		{&RuleScope{Include: []string{"file:**/*_handler.go"}}, site("mycorp.com/app/api", "api/user_handler.go"), true},
+		{&RuleScope{Include: []string{"file:*_handler.go"}}, site("mycorp.com/app/api", "api/user_handler.go"), true}, // base name
+		{&RuleScope{Include: []string{"file:api/*.go"}}, site("mycorp.com/app/api/v2", "api/v2/h.go"), false},
+		{&RuleScope{Include: []string{"./..."}, Exclude: []string{"file:*_gen.go"}}, site("mycorp.com/app/db", "db/models_gen.go"), false},
+		{&RuleScope{Include: []string{"./..."}, Exclude: []string{"file:*_gen.go"}}, site("mycorp.com/app/db", "db/models.go"), true},
+	}
+	for _, tc := range cases {
+		if got := tc.scope.Allows(tc.site); got != tc.want {
+			t.Errorf("%+v.Allows(%+v) = %v, want %v", tc.scope, tc.site, got, tc.want)
+		}
+	}
+}
+
+// TestEngine_RuleScope applies the same hook in and out of scope.
+func TestEngine_RuleScope(t *testing.T) {
+	rules, err := BuildRules(&RulesConfig{Rules: []RuleSpec{{
+		Type: "hook", Target: "strings.ToUpper", Before: `trace()`,
+		Scope: &ScopeSpec{Include: []string{"./internal/api/..."}, Exclude: []string{"file:*_test.go"}},
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg := NewRegistry()
+	reg.RegisterUser(rules[0])
+	src := `package p
+
+import "strings"
+
+func trace() {}
+
+func f() {
+	strings.ToUpper("x")
+}
+`
+	for _, tc := range []struct {
+		pkg, file string
+		want      bool
+	}{
+		{"mycorp.com/app/internal/api", "internal/api/db.go", true},
+		{"mycorp.com/app/internal/api", "internal/api/db_test.go", false},
+		{"mycorp.com/app/cmd/cli", "cmd/cli/db.go", false},
+	} {
+		file := decorateTyped(t, src)
+		eng := NewEngine(reg, ModeInject, resolveTarget)
+		eng.SetScopeSite(ScopeSite{ImportPath: tc.pkg, ModulePath: "mycorp.com/app", File: tc.file})
+		eng.Process(file)
+		if got := strings.Contains(fileToString(t, file), "\ttrace()"); got != tc.want {
+			t.Errorf("%s: hooked = %v, want %v", tc.file, got, tc.want)
+		}
+	}
+}
+
+// TestEngine_RuleScopeFallsThrough passes a match whose exact rule is out of
+// scope on to the pattern rule covering the same call.
+func TestEngine_RuleScopeFallsThrough(t *testing.T) {
+	rules, err := BuildRules(&RulesConfig{Rules: []RuleSpec{
+		{Type: "hook", Target: "strings.ToUpper", Before: `apiTrace()`, Scope: &ScopeSpec{Include: []string{"./internal/api/..."}}},
+		{Type: "hook", Target: "strings.To*", Before: `trace()`},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg := NewRegistry()
+	for _, r := range rules {
+		reg.RegisterUser(r)
+	}
+	src := `package p
+
+import "strings"
+
+func apiTrace() {}
+func trace()    {}
+
+func f() {
+	strings.ToUpper("x")
+}
+`
+	for _, tc := range []struct {
+		pkg, file, want string
+	}{
+		{"mycorp.com/app/internal/api", "internal/api/db.go", "\tapiTrace()\n\tstrings.ToUpper"},
+		{"mycorp.com/app/cmd/cli", "cmd/cli/db.go", "\ttrace()\n\tstrings.ToUpper"},
+	} {
+		file := decorateTyped(t, src)
+		eng := NewEngine(reg, ModeInject, resolveTarget)
+		eng.SetScopeSite(ScopeSite{ImportPath: tc.pkg, ModulePath: "mycorp.com/app", File: tc.file})
+		eng.Process(file)
+		if got := fileToString(t, file); !strings.Contains(got, tc.want) {
+			t.Errorf("%s: want %q in:\n%s", tc.file, tc.want, got)
+		}
+	}
+}
+
+// TestValidateScope_CompilesPackagePatterns compiles "..." patterns once,
+// relative ones without their leading ".".
+func TestValidateScope_CompilesPackagePatterns(t *testing.T) {
+	scope := &RuleScope{Include: []string{"./internal/...", "mycorp.com/.../api", "./cmd/cli"}, Exclude: []string{"file:*_gen.go"}}
+	if err := validateScope(scope); err != nil {
+		t.Fatal(err)
+	}
+	if len(scope.pkgRes) != 2 || scope.pkgRes["/internal/..."] == nil || scope.pkgRes["mycorp.com/.../api"] == nil {
+		t.Errorf("pkgRes = %v", scope.pkgRes)
+	}
+	site := ScopeSite{ImportPath: "mycorp.com/app/internal/db", ModulePath: "mycorp.com/app"}
+	if !scope.Allows(site) {
+		t.Errorf("Allows(%+v) = false", site)
+	}
+}
+
+// TestRegistry_PackageScopes scopes a built-in rule from config without
+// touching the shared rule value.
+func TestRegistry_PackageScopes(t *testing.T) {
+	builtin := &Rule{Target: "fmt.Println", Advice: &Hook{Before: "x()"}}
+	scope := &RuleScope{Exclude: []string{"./cmd/cli/..."}}
+	reg := NewRegistry()
+	reg.SetPackageScopes(map[string]*RuleScope{"fmt": scope})
+	reg.Register(builtin)
+	reg.Register(&Rule{Target: "log.Println", Advice: &Hook{Before: "y()"}})
+
+	if got := reg.Lookup("fmt.Println"); got == nil || got.Scope != scope {
+		t.Errorf("fmt.Println scope = %+v", got)
+	}
+	if builtin.Scope != nil {
+		t.Error("Register modified the shared built-in rule")
+	}
+	if got := reg.Lookup("log.Println"); got == nil || got.Scope != nil {
+		t.Errorf("log.Println scope = %+v", got)
+	}
+}
+
+// TestInjector_ScopeSite derives the import path from go.mod when
+// TOOLEXEC_IMPORTPATH is unknown.
+func TestInjector_ScopeSite(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module mycorp.com/app\n\ngo 1.22\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	inj := &Injector{Config: &config.Config{BaseDir: dir}}
+	for src, want := range map[string]ScopeSite{
+		filepath.Join(dir, "main.go"):                 {ImportPath: "mycorp.com/app", ModulePath: "mycorp.com/app", File: "main.go"},
+		filepath.Join(dir, "internal", "api", "h.go"): {ImportPath: "mycorp.com/app/internal/api", ModulePath: "mycorp.com/app", File: "internal/api/h.go"},
+		filepath.Join(filepath.Dir(dir), "x", "y.go"): {ModulePath: "mycorp.com/app"},
+	} {
+		if got := inj.scopeSite(src); got != want {
+			t.Errorf("scopeSite(%s) = %+v, want %+v", src, got, want)
+		}
+	}
+	inj.ImportPath = "mycorp.com/app/internal/api [mycorp.com/app/internal/api.test]"
+	if got := inj.scopeSite(filepath.Join(dir, "internal", "api", "h_test.go")); got.ImportPath != "mycorp.com/app/internal/api" {
+		t.Errorf("test variant ImportPath = %q", got.ImportPath)
+	}
+}
+
+func TestBuildRules_ScopeErrors(t *testing.T) {
+	for _, tc := range []struct {
+		scope   *ScopeSpec
+		wantErr string
+	}{
+		{&ScopeSpec{Include: []string{""}}, "empty pattern"},
+		{&ScopeSpec{Exclude: []string{"file:"}}, "empty pattern"},
+		{&ScopeSpec{Include: []string{"file:api/[x.go"}}, "invalid file glob"},
+	} {
+		_, err := BuildRules(&RulesConfig{Rules: []RuleSpec{{
+			Type: "hook", Target: "database/sql.Open", Before: "x()", Scope: tc.scope,
+		}}})
+		if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("%+v: err = %v, want %q", tc.scope, err, tc.wantErr)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package ast
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/mod/semver"
+)
+
+// ModuleCondition is a rule's `when:` clause: the rule registers only if
+// the main module requires Module at a version satisfying Version.
+//
+//	when:
+//	  module: github.com/IBM/sarama   # "" = the module providing the target package
+//	  version: ">=1.38.0 <2"
+//
+// Versions come from go.mod's require directives with its replace
+// directives applied (report.ReadModuleVersions). Without go.mod, when the
+// module is not required, or when it is replaced by a local directory, the
+// condition is false — a wrapper written for a version range is never
+// applied on a guess.
+type ModuleCondition struct {
+	Module  string
+	Version string
+
+	constraint versionConstraint
+}
+
+// versionConstraint is a parsed Version: alternatives joined by "||", each
+// a list of comparisons that must all hold.
+type versionConstraint [][]versionCmp
+
+type versionCmp struct {
+	op      string // "=", "!=", "<", "<=", ">", ">="
+	version string // canonical semver ("v1.38.0")
+}
+
+// parseVersionConstraint parses ">=1.38.0 <2", ">= v1.7, < v1.10",
+// "1.9.1" (exact) and "<1.5 || >=2". A version without "v" gets one, and
+// "2" / "1.38" mean v2.0.0 / v1.38.0 (semver shorthand).
+func parseVersionConstraint(s string) (versionConstraint, error) {
+	var out versionConstraint
+	for _, alt := range strings.Split(s, "||") {
+		fields := strings.Fields(strings.ReplaceAll(alt, ",", " "))
+		var cmps []versionCmp
+		for i := 0; i < len(fields); i++ {
+			tok := fields[i]
+			op := cmpOperator(tok)
+			ver := tok[len(op):]
+			if ver == "" && op != "" && i+1 < len(fields) { // ">= 1.38"
+				i++
+				ver = fields[i]
+			}
+			if op == "" || op == "==" {
+				op = "="
+			}
+			if !strings.HasPrefix(ver, "v") {
+				ver = "v" + ver
+			}
+			if !semver.IsValid(ver) {
+				return nil, fmt.Errorf("when.version %q: invalid version %q", s, strings.TrimPrefix(ver, "v"))
+			}
+			cmps = append(cmps, versionCmp{op: op, version: ver})
+		}
+		if len(cmps) == 0 {
+			return nil, fmt.Errorf("when.version %q: empty constraint", s)
+		}
+		out = append(out, cmps)
+	}
+	return out, nil
+}
+
+func cmpOperator(tok string) string {
+	for _, op := range []string{">=", "<=", "!=", "==", ">", "<", "="} {
+		if strings.HasPrefix(tok, op) {
+			return op
+		}
+	}
+	return ""
+}
+
+// allows reports whether version satisfies the constraint.
+func (c versionConstraint) allows(version string) bool {
+	if !semver.IsValid(version) {
+		return false
+	}
+	for _, alt := range c {
+		ok := true
+		for _, cmp := range alt {
+			if !cmp.holds(semver.Compare(version, cmp.version)) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (c versionCmp) holds(d int) bool {
+	switch c.op {
+	case "=":
+		return d == 0
+	case "!=":
+		return d != 0
+	case "<":
+		return d < 0
+	case "<=":
+		return d <= 0
+	case ">":
+		return d > 0
+	default: // ">="
+		return d >= 0
+	}
+}
+
+// check evaluates the condition for a rule targeting target against the
+// required module versions (nil = no go.mod). reason explains a false.
+func (c *ModuleCondition) check(target string, versions map[string]string) (ok bool, reason string) {
+	if versions == nil {
+		return false, "module versions unknown (no go.mod)"
+	}
+	mod := c.Module
+	if mod == "" {
+		// buildWhen requires module: for targets without a literal package
+		// (patterns), so pkg is only "" for Go-declared rules.
+		pkg := LiteralRulePackage(target)
+		if pkg == "" {
+			return false, "when: needs module: for a target without a package"
+		}
+		mod = providingModule(pkg, versions)
+		if mod == "" {
+			return false, fmt.Sprintf("no go.mod requirement provides %s", pkg)
+		}
+	}
+	version, required := versions[mod]
+	if !required {
+		return false, fmt.Sprintf("%s is not required in go.mod", mod)
+	}
+	if version == "" {
+		return false, fmt.Sprintf("%s is replaced by a local directory (version unknown)", mod)
+	}
+	if !c.constraint.allows(version) {
+		return false, fmt.Sprintf("%s %s does not satisfy %q", mod, version, c.Version)
+	}
+	return true, ""
+}
+
+// providingModule returns the longest required module path that is pkg or
+// a prefix of it ("" = none).
+func providingModule(pkg string, versions map[string]string) string {
+	best := ""
+	for mod := range versions {
+		if (pkg == mod || strings.HasPrefix(pkg, mod+"/")) && len(mod) > len(best) {
+			best = mod
+		}
+	}
+	return best
+}
+
+// buildWhen validates a `when:` block for a rule targeting target. A target
+// with no literal package (a glob or re: pattern) cannot name the module to
+// check, so module: is required there.
+func buildWhen(spec *WhenSpec, target string) (*ModuleCondition, error) {
+	if spec == nil {
+		return nil, nil
+	}
+	if strings.TrimSpace(spec.Version) == "" {
+		return nil, fmt.Errorf("when: version is required")
+	}
+	if strings.TrimSpace(spec.Module) == "" && LiteralRulePackage(target) == "" {
+		return nil, fmt.Errorf("when: module is required for target %q (no package to derive it from)", target)
+	}
+	c, err := parseVersionConstraint(spec.Version)
+	if err != nil {
+		return nil, err
+	}
+	return &ModuleCondition{Module: strings.TrimSpace(spec.Module), Version: spec.Version, constraint: c}, nil
+}
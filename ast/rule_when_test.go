@@ -0,0 +1,152 @@
+package ast
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/whatap/go-api-inst/config"
+	"gopkg.in/yaml.v3"
+)
+
+func TestVersionConstraint(t *testing.T) {
+	cases := []struct {
+		constraint string
+		version    string
+		want       bool
+	}{
+		{">=1.38.0 <2", "v1.38.0", true},
+		{">=1.38.0 <2", "v1.45.2", true},
+		{">=1.38.0 <2", "v1.37.9", false},
+		{">=1.38.0 <2", "v2.0.0", false},
+		{">= v1.7, < v1.10", "v1.9.1", true},
+		{">= v1.7, < v1.10", "v1.10.0", false},
+		{"1.9.1", "v1.9.1", true},
+		{"==1.9.1", "v1.9.2", false},
+		{"!=1.9.1", "v1.9.2", true},
+		{"<1.5 || >=2", "v1.4.0", true},
+		{"<1.5 || >=2", "v1.6.0", false},
+		{"<1.5 || >=2", "v2.1.0", true},
+		{">1.2", "v1.2.1-0.20230101000000-abcdef123456", true}, // pseudo-version after v1.2.0
+		{">=1.0", "latest", false},
+	}
+	for _, tc := range cases {
+		c, err := parseVersionConstraint(tc.constraint)
+		if err != nil {
+			t.Fatalf("parse %q: %v", tc.constraint, err)
+		}
+		if got := c.allows(tc.version); got != tc.want {
+			t.Errorf("%q allows %s = %v, want %v", tc.constraint, tc.version, got, tc.want)
+		}
+	}
+	for _, bad := range []string{"", ">=", ">=1.x", "<1 ||", "~1.2"} {
+		if _, err := parseVersionConstraint(bad); err == nil {
+			t.Errorf("parseVersionConstraint(%q) should fail", bad)
+		}
+	}
+}
+
+func TestModuleCondition_Check(t *testing.T) {
+	versions := map[string]string{
+		"github.com/IBM/sarama":        "v1.37.0",
+		"github.com/gin-gonic/gin":     "v1.9.1",
+		"github.com/redis/go-redis/v9": "v9.5.1",
+		"github.com/redis/rueidis":     "", // replaced by a local directory
+	}
+	cases := []struct {
+		when    WhenSpec
+		target  string
+		want    bool
+		wantMsg string
+	}{
+		{WhenSpec{Version: ">=1.7"}, "github.com/gin-gonic/gin.New", true, ""},
+		{WhenSpec{Version: ">=1.38.0 <2"}, "github.com/IBM/sarama.NewSyncProducer", false, `v1.37.0 does not satisfy ">=1.38.0 <2"`},
+		{WhenSpec{Version: ">=9"}, "github.com/redis/go-redis/v9.NewClient", true, ""},
+		{WhenSpec{Module: "github.com/IBM/sarama", Version: ">=1"}, "mycorp.com/kafka.Send", true, ""},
+		{WhenSpec{Version: ">=1"}, "github.com/labstack/echo/v4.New", false, "no go.mod requirement provides github.com/labstack/echo/v4"},
+		{WhenSpec{Module: "github.com/segmentio/kafka-go", Version: ">=0.4"}, "github.com/segmentio/kafka-go.NewWriter", false, "not required in go.mod"},
+		{WhenSpec{Version: ">=1"}, "github.com/redis/rueidis.NewClient", false, "replaced by a local directory"},
+		{WhenSpec{Version: ">=1"}, "iface:github.com/IBM/sarama.SyncProducer.SendMessage", true, ""},
+		{WhenSpec{Module: "github.com/gin-gonic/gin", Version: ">=1.9"}, "github.com/gin-gonic/gin.*.Use", true, ""},
+	}
+	for _, tc := range cases {
+		c, err := buildWhen(&tc.when, tc.target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ok, reason := c.check(tc.target, versions)
+		if ok != tc.want || !strings.Contains(reason, tc.wantMsg) {
+			t.Errorf("%+v on %s = %v %q, want %v %q", tc.when, tc.target, ok, reason, tc.want, tc.wantMsg)
+		}
+	}
+	c, _ := buildWhen(&WhenSpec{Version: ">=1"}, "github.com/gin-gonic/gin.New")
+	if ok, reason := c.check("github.com/gin-gonic/gin.New", nil); ok || !strings.Contains(reason, "no go.mod") {
+		t.Errorf("without go.mod = %v %q", ok, reason)
+	}
+}
+
+// TestInjector_WhenClause registers only the user rules whose `when:`
+// matches the project's go.mod and lists the others in SkippedRules.
+func TestInjector_WhenClause(t *testing.T) {
+	dir := t.TempDir()
+	gomod := "module mycorp.com/app\n\ngo 1.22\n\nrequire (\n\tgithub.com/IBM/sarama v1.37.0\n\tgithub.com/gin-gonic/gin v1.9.1 // indirect\n)\n"
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte(gomod), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var cfg config.Config
+	if err := yaml.Unmarshal([]byte(`
+rules:
+  - id: sarama-new
+    type: hook
+    target: "github.com/IBM/sarama.NewSyncProducer"
+    before: 'trace()'
+    when: {version: ">=1.38.0 <2"}
+  - id: gin-new
+    type: hook
+    target: "github.com/gin-gonic/gin.New"
+    before: 'trace()'
+    when: {module: github.com/gin-gonic/gin, version: ">=1.7"}
+`), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	cfg.BaseDir = dir
+	inj := NewInjector()
+	inj.SetConfig(&cfg)
+
+	if inj.registry.Lookup("github.com/gin-gonic/gin.New") == nil {
+		t.Error("gin-new should be registered")
+	}
+	if inj.registry.Lookup("github.com/IBM/sarama.NewSyncProducer") != nil {
+		t.Error("sarama-new should be skipped")
+	}
+	skips := inj.SkippedRules()
+	if len(skips) != 1 || skips[0].RuleID != "sarama-new" || !strings.Contains(skips[0].Reason, "v1.37.0") {
+		t.Errorf("SkippedRules = %+v", skips)
+	}
+}
+
+func TestBuildRules_WhenErrors(t *testing.T) {
+	for _, tc := range []struct {
+		when    *WhenSpec
+		wantErr string
+	}{
+		{&WhenSpec{Module: "github.com/IBM/sarama"}, "version is required"},
+		{&WhenSpec{Version: ">=1.38.x"}, `invalid version "1.38.x"`},
+	} {
+		_, err := BuildRules(&RulesConfig{Rules: []RuleSpec{{
+			Type: "hook", Target: "github.com/IBM/sarama.NewSyncProducer", Before: "x()", When: tc.when,
+		}}})
+		if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("%+v: err = %v, want %q", tc.when, err, tc.wantErr)
+		}
+	}
+
+	// A pattern target names no package to derive the module from.
+	_, err := BuildRules(&RulesConfig{Rules: []RuleSpec{{
+		Type: "hook", Target: "github.com/IBM/sarama.*.Send*", Before: "x()", When: &WhenSpec{Version: ">=1"},
+	}}})
+	if err == nil || !strings.Contains(err.Error(), "module is required") {
+		t.Errorf("pattern target without module: err = %v", err)
+	}
+}
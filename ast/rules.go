@@ -3,7 +3,7 @@ package ast
 // AllRules returns all rules (Tier 1: 37 + Phase 2: 8 + Phase 3a: 15 + Phase 3b: 6 + Phase 3c: 26 = 92).
 func AllRules() []*Rule {
 	return []*Rule{
-		// ── ReplaceFunction (25) ──────────────────────────────────────
+		// ── ReplaceFunction (28) ──────────────────────────────────────
 
 		// sql (1)
 		{Target: "database/sql.Open", Advice: &ReplaceFunction{
@@ -37,6 +37,22 @@ func AllRules() []*Rule {
 			WhatapPkg: "github.com/whatap/go-api/instrumentation/github.com/jinzhu/gorm/whatapgorm", WhatapAlias: "whatapgorm", WhatapFunc: "Open",
 		}},
 
+		// ent (1) — the generated client's ent.Open(driver, dsn) lives in the
+		// user module and calls entgo.io/ent/dialect/sql.Open, which opens
+		// database/sql inside the ent module (never rewritten). whatapent.Open
+		// opens through whatapsql and returns entsql.OpenDB(driver, db) — same
+		// (*entsql.Driver, error). entsql.OpenDB(name, db) with a user-opened
+		// db is traced wherever that db was opened through whatapsql.
+		{Target: "entgo.io/ent/dialect/sql.Open", Advice: &ReplaceFunction{
+			WhatapPkg: "github.com/whatap/go-api/instrumentation/entgo.io/ent/whatapent", WhatapAlias: "whatapent", WhatapFunc: "Open",
+		}},
+
+		// xorm (1) — NewEngine(driver, dsn) opens inside xorm; whatapxorm opens
+		// through whatapsql and hands the *sql.DB to NewEngineWithDB.
+		{Target: "xorm.io/xorm.NewEngine", Advice: &ReplaceFunction{
+			WhatapPkg: "github.com/whatap/go-api/instrumentation/xorm.io/xorm/whatapxorm", WhatapAlias: "whatapxorm", WhatapFunc: "NewEngine",
+		}},
+
 		// goredis v9 (4)
 		{Target: "github.com/redis/go-redis/v9.NewClient", Advice: &ReplaceFunction{
 			WhatapPkg: "github.com/whatap/go-api/instrumentation/github.com/redis/go-redis/v9/whatapgoredis", WhatapAlias: "whatapgoredis", WhatapFunc: "NewClient",
@@ -79,6 +95,14 @@ func AllRules() []*Rule {
 			WhatapPkg: "github.com/whatap/go-api/instrumentation/github.com/gomodule/redigo/whatapredigo", WhatapAlias: "whatapredigo", WhatapFunc: "DialURLContext",
 		}},
 
+		// rueidis (1) — whataprueidis.NewClient = rueidis.NewClient +
+		// rueidishook.WithHook(c, hook). Same (rueidis.Client, error) result.
+		// The hook records Do / DoMulti / DoCache / DoMultiCache / Receive as
+		// steps; DoCache marks hit/miss from RedisResult.IsCacheHit().
+		{Target: "github.com/redis/rueidis.NewClient", Advice: &ReplaceFunction{
+			WhatapPkg: "github.com/whatap/go-api/instrumentation/github.com/redis/rueidis/whataprueidis", WhatapAlias: "whataprueidis", WhatapFunc: "NewClient",
+		}},
+
 		// mongo (2)
 		{Target: "go.mongodb.org/mongo-driver/mongo.Connect", Advice: &ReplaceFunction{
 			WhatapPkg: "github.com/whatap/go-api/instrumentation/go.mongodb.org/mongo-driver/mongo/whatapmongo", WhatapAlias: "whatapmongo", WhatapFunc: "Connect",
@@ -100,7 +124,7 @@ func AllRules() []*Rule {
 			WhatapPkg: "github.com/whatap/go-api/instrumentation/fmt/whatapfmt", WhatapAlias: "whatapfmt", WhatapFunc: "Println",
 		}},
 
-		// ── WrapCall (12) ─────────────────────────────────────────────
+		// ── WrapCall (17) ─────────────────────────────────────────────
 
 		// gin (2)
 		{Target: "github.com/gin-gonic/gin.Default", Advice: &WrapCall{
@@ -153,6 +177,41 @@ func AllRules() []*Rule {
 			WhatapPkg: "github.com/whatap/go-api/instrumentation/github.com/sirupsen/logrus/whataplogrus", WhatapAlias: "whataplogrus", WhatapFunc: "WrapLogger",
 		}},
 
+		// resty v2 (2) — resty builds its own *http.Client internally, so no
+		// net/http.Client{} literal is ever seen at the call site. WrapClient
+		// installs the whatap RoundTripper on the client's transport after
+		// construction; resty retries re-enter RoundTrip, so every attempt is
+		// recorded as its own HTTP step.
+		{Target: "github.com/go-resty/resty/v2.New", Advice: &WrapCall{
+			WhatapPkg: "github.com/whatap/go-api/instrumentation/github.com/go-resty/resty/v2/whatapresty", WhatapAlias: "whatapresty", WhatapFunc: "WrapClient",
+		}},
+		{Target: "github.com/go-resty/resty/v2.NewWithClient", Advice: &WrapCall{
+			WhatapPkg: "github.com/whatap/go-api/instrumentation/github.com/go-resty/resty/v2/whatapresty", WhatapAlias: "whatapresty", WhatapFunc: "WrapClient",
+		}},
+
+		// retryablehttp (1) — same shape: WrapClient wraps c.HTTPClient.Transport
+		// (per-attempt step) and chains RequestLogHook to tag the attempt number.
+		{Target: "github.com/hashicorp/go-retryablehttp.NewClient", Advice: &WrapCall{
+			WhatapPkg: "github.com/whatap/go-api/instrumentation/github.com/hashicorp/go-retryablehttp/whatapretryablehttp", WhatapAlias: "whatapretryablehttp", WhatapFunc: "WrapClient",
+		}},
+
+		// bun (1) — bun.NewDB(sqldb, dialect) takes a pre-opened *sql.DB.
+		// WrapDB adds a bun QueryHook (query + operation + table) and returns
+		// the same *bun.DB. The hook skips queries whose ctx already carries a
+		// whatapsql step, so a sqldb already opened through whatapsql is not
+		// double-counted.
+		{Target: "github.com/uptrace/bun.NewDB", Advice: &WrapCall{
+			WhatapPkg: "github.com/whatap/go-api/instrumentation/github.com/uptrace/bun/whatapbun", WhatapAlias: "whatapbun", WhatapFunc: "WrapDB",
+		}},
+
+		// gomemcache (1) — *memcache.Client has no hook API. WrapClient
+		// installs a DialContext wrapper (server address → dbhost) and returns
+		// the same *memcache.Client; the per-op steps come from the Transform
+		// rules below. WrapCall keeps a `servers...` spread intact.
+		{Target: "github.com/bradfitz/gomemcache/memcache.New", Advice: &WrapCall{
+			WhatapPkg: "github.com/whatap/go-api/instrumentation/github.com/bradfitz/gomemcache/memcache/whatapmemcache", WhatapAlias: "whatapmemcache", WhatapFunc: "WrapClient",
+		}},
+
 		// ── Phase 2: ArgInsert + CodeInsert + MainInsert + ArgWrap ─────────
 
 		// grpc — ArgInsert (4)
@@ -192,6 +251,25 @@ func AllRules() []*Rule {
 			Ellipsis: true,
 		}, Signature: &FuncSignature{MinArgs: 1, MaxArgs: -1}},
 
+		// database/sql — ArgWrap (2). sql.OpenDB(connector) is how pgx stdlib,
+		// mysql.NewConnector, cloudsqlconn etc. reach a *sql.DB without
+		// sql.Open. WrapConnector wraps the driver.Connector (DSN host taken
+		// from the connector/driver by the wrapper) so the resulting *sql.DB
+		// is traced exactly like whatapsql.Open.
+		{Target: "database/sql.OpenDB", Advice: &ArgWrap{
+			WhatapPkg: "github.com/whatap/go-api/instrumentation/database/sql/whatapsql", WhatapAlias: "whatapsql",
+			WhatapFunc: "WrapConnector", ArgIndex: 0,
+		}, Signature: &FuncSignature{MinArgs: 1, MaxArgs: 1}},
+		// sql.Register(name, drv) — OptIn (enabled_packages: [database/sql]).
+		// Only Register calls in user code are seen (drivers usually register
+		// in their own init()), and a wrapped registration plus whatapsql.Open
+		// on the same name relies on WrapDriver's already-wrapped check, so
+		// it stays off by default.
+		{Target: "database/sql.Register", OptIn: true, Advice: &ArgWrap{
+			WhatapPkg: "github.com/whatap/go-api/instrumentation/database/sql/whatapsql", WhatapAlias: "whatapsql",
+			WhatapFunc: "WrapDriver", ArgIndex: 1,
+		}, Signature: &FuncSignature{MinArgs: 2, MaxArgs: 2}},
+
 		// k8s — CodeInsert (2)
 		{Target: "k8s.io/client-go/kubernetes.NewForConfig", Advice: &CodeInsert{
 			WhatapPkg:   "github.com/whatap/go-api/instrumentation/k8s.io/client-go/kubernetes/whatapkubernetes",
@@ -204,6 +282,81 @@ func AllRules() []*Rule {
 			ArgSource: 0, MethodName: "Wrap", WhatapFunc: "WrapRoundTripper",
 		}, Signature: &FuncSignature{MinArgs: 1, MaxArgs: 1}},
 
+		// k8s — ArgWrap (9): every other constructor that takes a *rest.Config.
+		// CodeInsert above needs a plain identifier (cfg.Wrap(...) statement);
+		// operators usually pass ctrl.GetConfigOrDie() inline, so these wrap
+		// the argument expression instead. WrapConfig sets WrapTransport on the
+		// config and returns it — a config already wrapped (shared with
+		// kubernetes.NewForConfig) is left as is by the wrapper.
+		{Target: "k8s.io/client-go/dynamic.NewForConfig", Advice: &ArgWrap{
+			WhatapPkg: "github.com/whatap/go-api/instrumentation/k8s.io/client-go/kubernetes/whatapkubernetes", WhatapAlias: "whatapkubernetes",
+			WhatapFunc: "WrapConfig", ArgIndex: 0,
+		}, Signature: &FuncSignature{MinArgs: 1, MaxArgs: 1}},
+		{Target: "k8s.io/client-go/dynamic.NewForConfigOrDie", Advice: &ArgWrap{
+			WhatapPkg: "github.com/whatap/go-api/instrumentation/k8s.io/client-go/kubernetes/whatapkubernetes", WhatapAlias: "whatapkubernetes",
+			WhatapFunc: "WrapConfig", ArgIndex: 0,
+		}, Signature: &FuncSignature{MinArgs: 1, MaxArgs: 1}},
+		{Target: "k8s.io/client-go/metadata.NewForConfig", Advice: &ArgWrap{
+			WhatapPkg: "github.com/whatap/go-api/instrumentation/k8s.io/client-go/kubernetes/whatapkubernetes", WhatapAlias: "whatapkubernetes",
+			WhatapFunc: "WrapConfig", ArgIndex: 0,
+		}, Signature: &FuncSignature{MinArgs: 1, MaxArgs: 1}},
+		{Target: "k8s.io/client-go/metadata.NewForConfigOrDie", Advice: &ArgWrap{
+			WhatapPkg: "github.com/whatap/go-api/instrumentation/k8s.io/client-go/kubernetes/whatapkubernetes", WhatapAlias: "whatapkubernetes",
+			WhatapFunc: "WrapConfig", ArgIndex: 0,
+		}, Signature: &FuncSignature{MinArgs: 1, MaxArgs: 1}},
+		{Target: "k8s.io/client-go/discovery.NewDiscoveryClientForConfig", Advice: &ArgWrap{
+			WhatapPkg: "github.com/whatap/go-api/instrumentation/k8s.io/client-go/kubernetes/whatapkubernetes", WhatapAlias: "whatapkubernetes",
+			WhatapFunc: "WrapConfig", ArgIndex: 0,
+		}, Signature: &FuncSignature{MinArgs: 1, MaxArgs: 1}},
+		{Target: "sigs.k8s.io/controller-runtime.NewManager", Advice: &ArgWrap{
+			WhatapPkg: "github.com/whatap/go-api/instrumentation/k8s.io/client-go/kubernetes/whatapkubernetes", WhatapAlias: "whatapkubernetes",
+			WhatapFunc: "WrapConfig", ArgIndex: 0,
+		}, Signature: &FuncSignature{MinArgs: 2, MaxArgs: 2}},
+		{Target: "sigs.k8s.io/controller-runtime/pkg/manager.New", Advice: &ArgWrap{
+			WhatapPkg: "github.com/whatap/go-api/instrumentation/k8s.io/client-go/kubernetes/whatapkubernetes", WhatapAlias: "whatapkubernetes",
+			WhatapFunc: "WrapConfig", ArgIndex: 0,
+		}, Signature: &FuncSignature{MinArgs: 2, MaxArgs: 2}},
+		{Target: "sigs.k8s.io/controller-runtime/pkg/client.New", Advice: &ArgWrap{
+			WhatapPkg: "github.com/whatap/go-api/instrumentation/k8s.io/client-go/kubernetes/whatapkubernetes", WhatapAlias: "whatapkubernetes",
+			WhatapFunc: "WrapConfig", ArgIndex: 0,
+		}, Signature: &FuncSignature{MinArgs: 2, MaxArgs: 2}},
+		{Target: "sigs.k8s.io/controller-runtime/pkg/client.NewWithWatch", Advice: &ArgWrap{
+			WhatapPkg: "github.com/whatap/go-api/instrumentation/k8s.io/client-go/kubernetes/whatapkubernetes", WhatapAlias: "whatapkubernetes",
+			WhatapFunc: "WrapConfig", ArgIndex: 0,
+		}, Signature: &FuncSignature{MinArgs: 2, MaxArgs: 2}},
+
+		// controller-runtime — ArgWrap (4) + FieldWrap (2): each Reconcile
+		// invocation becomes one transaction. The reconciler is wrapped where it
+		// is handed to the controller (builder Complete/Build or
+		// controller.Options.Reconciler), not at its method declaration, so
+		// user Reconcile code is left untouched. Newer releases made these
+		// generic (Builder = TypedBuilder[reconcile.Request]); NamedTypeOf
+		// reports the origin type, so both names are listed.
+		{Target: "sigs.k8s.io/controller-runtime/pkg/builder.Builder.Complete", Advice: &ArgWrap{
+			WhatapPkg: "github.com/whatap/go-api/instrumentation/sigs.k8s.io/controller-runtime/whatapcontrollerruntime", WhatapAlias: "whatapcontrollerruntime",
+			WhatapFunc: "WrapReconciler", ArgIndex: 0,
+		}, Signature: &FuncSignature{MinArgs: 1, MaxArgs: 1}},
+		{Target: "sigs.k8s.io/controller-runtime/pkg/builder.TypedBuilder.Complete", Advice: &ArgWrap{
+			WhatapPkg: "github.com/whatap/go-api/instrumentation/sigs.k8s.io/controller-runtime/whatapcontrollerruntime", WhatapAlias: "whatapcontrollerruntime",
+			WhatapFunc: "WrapReconciler", ArgIndex: 0,
+		}, Signature: &FuncSignature{MinArgs: 1, MaxArgs: 1}},
+		{Target: "sigs.k8s.io/controller-runtime/pkg/builder.Builder.Build", Advice: &ArgWrap{
+			WhatapPkg: "github.com/whatap/go-api/instrumentation/sigs.k8s.io/controller-runtime/whatapcontrollerruntime", WhatapAlias: "whatapcontrollerruntime",
+			WhatapFunc: "WrapReconciler", ArgIndex: 0,
+		}, Signature: &FuncSignature{MinArgs: 1, MaxArgs: 1}},
+		{Target: "sigs.k8s.io/controller-runtime/pkg/builder.TypedBuilder.Build", Advice: &ArgWrap{
+			WhatapPkg: "github.com/whatap/go-api/instrumentation/sigs.k8s.io/controller-runtime/whatapcontrollerruntime", WhatapAlias: "whatapcontrollerruntime",
+			WhatapFunc: "WrapReconciler", ArgIndex: 0,
+		}, Signature: &FuncSignature{MinArgs: 1, MaxArgs: 1}},
+		{Target: "sigs.k8s.io/controller-runtime/pkg/controller.Options{}", Advice: &FieldWrap{
+			WhatapPkg: "github.com/whatap/go-api/instrumentation/sigs.k8s.io/controller-runtime/whatapcontrollerruntime", WhatapAlias: "whatapcontrollerruntime",
+			WhatapFunc: "WrapReconciler", FieldName: "Reconciler",
+		}},
+		{Target: "sigs.k8s.io/controller-runtime/pkg/controller.TypedOptions{}", Advice: &FieldWrap{
+			WhatapPkg: "github.com/whatap/go-api/instrumentation/sigs.k8s.io/controller-runtime/whatapcontrollerruntime", WhatapAlias: "whatapcontrollerruntime",
+			WhatapFunc: "WrapReconciler", FieldName: "Reconciler",
+		}},
+
 		// zap — 보류 (§63 TraceLogWriter 방식으로 전환 예정, HookStderr 폐기)
 
 		// log — ArgWrap (1) + MainInsert (1)
@@ -267,6 +420,21 @@ func AllRules() []*Rule {
 			WhatapFunc: "WrapHandler", ArgIndex: -1,
 		}, Signature: &FuncSignature{MinArgs: 2, MaxArgs: 2}},
 
+		// aws-lambda-go — ArgWrap (2): handler wrapping. lambda.Start never
+		// returns, so main()'s deferred Shutdown is skipped (injectMainInit) and
+		// WrapHandler instead starts a transaction per invocation and flushes
+		// before handing the result back to the runtime (the sandbox may be
+		// frozen right after). Handler is `interface{}`, so the wrap keeps the
+		// static type — no compile risk.
+		{Target: "github.com/aws/aws-lambda-go/lambda.Start", Advice: &ArgWrap{
+			WhatapPkg: "github.com/whatap/go-api/instrumentation/github.com/aws/aws-lambda-go/lambda/whataplambda", WhatapAlias: "whataplambda",
+			WhatapFunc: "WrapHandler", ArgIndex: 0,
+		}, Signature: &FuncSignature{MinArgs: 1, MaxArgs: 1}},
+		{Target: "github.com/aws/aws-lambda-go/lambda.StartWithOptions", Advice: &ArgWrap{
+			WhatapPkg: "github.com/whatap/go-api/instrumentation/github.com/aws/aws-lambda-go/lambda/whataplambda", WhatapAlias: "whataplambda",
+			WhatapFunc: "WrapHandler", ArgIndex: 0,
+		}, Signature: &FuncSignature{MinArgs: 1, MaxArgs: -1}},
+
 		// nethttp — FieldWrap (1): Server{Handler}
 		{Target: "net/http.Server{}", Advice: &FieldWrap{
 			WhatapPkg: "github.com/whatap/go-api/instrumentation/net/http/whataphttp", WhatapAlias: "whataphttp",
@@ -283,6 +451,31 @@ func AllRules() []*Rule {
 			CtxAware:   true,
 		}},
 
+		// nethttp — FieldAssignWrap (1): `c.Transport = rt` after construction.
+		// The Client{} rule above only sees the literal; a later assignment
+		// would silently replace the wrapped Transport.
+		{Target: "net/http.Client.Transport=", Advice: &FieldAssignWrap{
+			WhatapPkg: "github.com/whatap/go-api/instrumentation/net/http/whataphttp", WhatapAlias: "whataphttp",
+			WhatapFunc: "NewRoundTrip", CtxAware: true,
+		}},
+
+		// httputil — ReverseProxy upstream calls (3). The proxy forwards the
+		// inbound request (its ctx carries the transaction), so these wrappers
+		// are not CtxAware — the RoundTripper reads req.Context() per call.
+		{Target: "net/http/httputil.NewSingleHostReverseProxy", Advice: &WrapCall{
+			WhatapPkg: "github.com/whatap/go-api/instrumentation/net/http/whataphttp", WhatapAlias: "whataphttp", WhatapFunc: "WrapReverseProxy",
+		}},
+		{Target: "net/http/httputil.ReverseProxy{}", Advice: &FieldWrapOrInsert{
+			WhatapPkg: "github.com/whatap/go-api/instrumentation/net/http/whataphttp", WhatapAlias: "whataphttp",
+			WrapFunc:   "WrapProxyTransport", // when Transport exists
+			InsertFunc: "NewProxyTransport",  // when Transport missing (http.DefaultTransport)
+			FieldName:  "Transport",
+		}},
+		{Target: "net/http/httputil.ReverseProxy.Transport=", Advice: &FieldAssignWrap{
+			WhatapPkg: "github.com/whatap/go-api/instrumentation/net/http/whataphttp", WhatapAlias: "whataphttp",
+			WhatapFunc: "WrapProxyTransport",
+		}},
+
 		// ── Phase 3a: fasthttp ArgWrap + FieldWrap ────────────────────────
 
 		// fasthttp — ArgWrap (8): router method handler wrapping
@@ -325,6 +518,69 @@ func AllRules() []*Rule {
 			WhatapFunc: "WrapHandler", FieldName: "Handler",
 		}, Fields: []FieldMatch{{Name: "Handler", Required: true}}},
 
+		// ── Search clients: elasticsearch v8 / opensearch v2·v4 ─────────────
+
+		// elasticsearch v8 — FieldWrapOrInsert (1) + Transform (1).
+		// NewClient(cfg) and NewTypedClient(cfg) both take elasticsearch.Config,
+		// so the literal rule covers them at the point cfg is built. The whatap
+		// Transport records each request as a DB step (index + operation parsed
+		// from the request path) and reads the transaction from req.Context(),
+		// hence not CtxAware.
+		{Target: "github.com/elastic/go-elasticsearch/v8.Config{}", Advice: &FieldWrapOrInsert{
+			WhatapPkg: "github.com/whatap/go-api/instrumentation/github.com/elastic/go-elasticsearch/whatapelasticsearch", WhatapAlias: "whatapelasticsearch",
+			WrapFunc:   "WrapTransport", // when Transport exists
+			InsertFunc: "NewTransport",  // when Transport missing (http.DefaultTransport)
+			FieldName:  "Transport",
+		}},
+		// NewDefaultClient() has no Config literal to hook — rewrite it into the
+		// equivalent NewClient(Config{Transport: ...}). {{.TargetPkg}} keeps the
+		// user's alias; return type (*Client, error) is unchanged.
+		{Target: "github.com/elastic/go-elasticsearch/v8.NewDefaultClient", Advice: &Transform{
+			Template: `{{.TargetPkg}}.NewClient({{.TargetPkg}}.Config{Transport: whatapelasticsearch.NewTransport()})`,
+			Imports:  []string{"github.com/whatap/go-api/instrumentation/github.com/elastic/go-elasticsearch/whatapelasticsearch"},
+		}, Signature: &FuncSignature{MinArgs: 0, MaxArgs: 0}},
+
+		// opensearch-go v2/v4 (4) — same shape. v4's opensearchapi.Config embeds
+		// an opensearch.Config literal (Client field), which this rule still sees.
+		// The whatap package only wraps http.RoundTripper, so both majors share it.
+		{Target: "github.com/opensearch-project/opensearch-go/v2.Config{}", Advice: &FieldWrapOrInsert{
+			WhatapPkg: "github.com/whatap/go-api/instrumentation/github.com/opensearch-project/opensearch-go/whatapopensearch", WhatapAlias: "whatapopensearch",
+			WrapFunc: "WrapTransport", InsertFunc: "NewTransport", FieldName: "Transport",
+		}},
+		{Target: "github.com/opensearch-project/opensearch-go/v2.NewDefaultClient", Advice: &Transform{
+			Template: `{{.TargetPkg}}.NewClient({{.TargetPkg}}.Config{Transport: whatapopensearch.NewTransport()})`,
+			Imports:  []string{"github.com/whatap/go-api/instrumentation/github.com/opensearch-project/opensearch-go/whatapopensearch"},
+		}, Signature: &FuncSignature{MinArgs: 0, MaxArgs: 0}},
+		{Target: "github.com/opensearch-project/opensearch-go/v4.Config{}", Advice: &FieldWrapOrInsert{
+			WhatapPkg: "github.com/whatap/go-api/instrumentation/github.com/opensearch-project/opensearch-go/whatapopensearch", WhatapAlias: "whatapopensearch",
+			WrapFunc: "WrapTransport", InsertFunc: "NewTransport", FieldName: "Transport",
+		}},
+		{Target: "github.com/opensearch-project/opensearch-go/v4.NewDefaultClient", Advice: &Transform{
+			Template: `{{.TargetPkg}}.NewClient({{.TargetPkg}}.Config{Transport: whatapopensearch.NewTransport()})`,
+			Imports:  []string{"github.com/whatap/go-api/instrumentation/github.com/opensearch-project/opensearch-go/whatapopensearch"},
+		}, Signature: &FuncSignature{MinArgs: 0, MaxArgs: 0}},
+
+		// ── Temporal: go.temporal.io/sdk interceptors (2) ─────────────────
+		// client.Options{} gets the whatap ClientInterceptor (outbound
+		// ExecuteWorkflow / SignalWorkflow / QueryWorkflow steps + trace context
+		// written to Temporal headers); worker.Options{} gets the
+		// WorkerInterceptor (activity = transaction continuing the caller's
+		// trace from headers; workflow steps recorded only when not replaying).
+		// Existing Interceptors slices are kept — Append* returns them with the
+		// whatap interceptor appended. Not CtxAware: Temporal passes ctx per call.
+		{Target: "go.temporal.io/sdk/client.Options{}", Advice: &FieldWrapOrInsert{
+			WhatapPkg: "github.com/whatap/go-api/instrumentation/go.temporal.io/sdk/whataptemporal", WhatapAlias: "whataptemporal",
+			WrapFunc:   "AppendClientInterceptors", // when Interceptors exists
+			InsertFunc: "ClientInterceptors",       // when Interceptors missing
+			FieldName:  "Interceptors",
+		}},
+		{Target: "go.temporal.io/sdk/worker.Options{}", Advice: &FieldWrapOrInsert{
+			WhatapPkg: "github.com/whatap/go-api/instrumentation/go.temporal.io/sdk/whataptemporal", WhatapAlias: "whataptemporal",
+			WrapFunc:   "AppendWorkerInterceptors",
+			InsertFunc: "WorkerInterceptors",
+			FieldName:  "Interceptors",
+		}},
+
 		// ── Phase 3c: Transform — aerospike (26 Rule) ─────────────────────
 
 		// aerospike — WrapOpen (3): NewClient, NewClientWithPolicy, NewClientWithPolicyAndHost
@@ -476,6 +732,51 @@ func AllRules() []*Rule {
 			ImportAliases: map[string]string{"github.com/whatap/go-api/sql": "whatapdb"},
 		}},
 
+		// gomemcache ops (10) — c.Get(key) → whatapmemcache.Get(ctx, c, key).
+		// Helpers mirror the method signatures (ctx + receiver prepended), so
+		// one template covers all of them. Get / GetMulti record hit or miss
+		// (memcache.ErrCacheMiss is a miss, not an error).
+		{Target: "github.com/bradfitz/gomemcache/memcache.Client.Get", Advice: &Transform{
+			Template: `whatapmemcache.{{.FuncName}}({{.Ctx}}, {{.Receiver}}, {{.Args}})`,
+			Imports:  []string{"github.com/whatap/go-api/instrumentation/github.com/bradfitz/gomemcache/memcache/whatapmemcache", "context"},
+		}},
+		{Target: "github.com/bradfitz/gomemcache/memcache.Client.GetMulti", Advice: &Transform{
+			Template: `whatapmemcache.{{.FuncName}}({{.Ctx}}, {{.Receiver}}, {{.Args}})`,
+			Imports:  []string{"github.com/whatap/go-api/instrumentation/github.com/bradfitz/gomemcache/memcache/whatapmemcache", "context"},
+		}},
+		{Target: "github.com/bradfitz/gomemcache/memcache.Client.Set", Advice: &Transform{
+			Template: `whatapmemcache.{{.FuncName}}({{.Ctx}}, {{.Receiver}}, {{.Args}})`,
+			Imports:  []string{"github.com/whatap/go-api/instrumentation/github.com/bradfitz/gomemcache/memcache/whatapmemcache", "context"},
+		}},
+		{Target: "github.com/bradfitz/gomemcache/memcache.Client.Add", Advice: &Transform{
+			Template: `whatapmemcache.{{.FuncName}}({{.Ctx}}, {{.Receiver}}, {{.Args}})`,
+			Imports:  []string{"github.com/whatap/go-api/instrumentation/github.com/bradfitz/gomemcache/memcache/whatapmemcache", "context"},
+		}},
+		{Target: "github.com/bradfitz/gomemcache/memcache.Client.Replace", Advice: &Transform{
+			Template: `whatapmemcache.{{.FuncName}}({{.Ctx}}, {{.Receiver}}, {{.Args}})`,
+			Imports:  []string{"github.com/whatap/go-api/instrumentation/github.com/bradfitz/gomemcache/memcache/whatapmemcache", "context"},
+		}},
+		{Target: "github.com/bradfitz/gomemcache/memcache.Client.CompareAndSwap", Advice: &Transform{
+			Template: `whatapmemcache.{{.FuncName}}({{.Ctx}}, {{.Receiver}}, {{.Args}})`,
+			Imports:  []string{"github.com/whatap/go-api/instrumentation/github.com/bradfitz/gomemcache/memcache/whatapmemcache", "context"},
+		}},
+		{Target: "github.com/bradfitz/gomemcache/memcache.Client.Delete", Advice: &Transform{
+			Template: `whatapmemcache.{{.FuncName}}({{.Ctx}}, {{.Receiver}}, {{.Args}})`,
+			Imports:  []string{"github.com/whatap/go-api/instrumentation/github.com/bradfitz/gomemcache/memcache/whatapmemcache", "context"},
+		}},
+		{Target: "github.com/bradfitz/gomemcache/memcache.Client.Increment", Advice: &Transform{
+			Template: `whatapmemcache.{{.FuncName}}({{.Ctx}}, {{.Receiver}}, {{.Args}})`,
+			Imports:  []string{"github.com/whatap/go-api/instrumentation/github.com/bradfitz/gomemcache/memcache/whatapmemcache", "context"},
+		}},
+		{Target: "github.com/bradfitz/gomemcache/memcache.Client.Decrement", Advice: &Transform{
+			Template: `whatapmemcache.{{.FuncName}}({{.Ctx}}, {{.Receiver}}, {{.Args}})`,
+			Imports:  []string{"github.com/whatap/go-api/instrumentation/github.com/bradfitz/gomemcache/memcache/whatapmemcache", "context"},
+		}},
+		{Target: "github.com/bradfitz/gomemcache/memcache.Client.Touch", Advice: &Transform{
+			Template: `whatapmemcache.{{.FuncName}}({{.Ctx}}, {{.Receiver}}, {{.Args}})`,
+			Imports:  []string{"github.com/whatap/go-api/instrumentation/github.com/bradfitz/gomemcache/memcache/whatapmemcache", "context"},
+		}},
+
 		// §254 — sashabaranov/go-openai 메서드 wrap (Transform): user
 		// code's `c.CreateChatCompletion(ctx, req)` → wrap helper call so
 		// the *openai.Client variable type stays unchanged. The helpers
@@ -668,5 +969,169 @@ func AllRules() []*Rule {
 			Template: `whatapopenaigo.NewClient({{.Args}})`,
 			Imports:  []string{"github.com/whatap/go-api/instrumentation/llm/github.com/openai/openai-go/whatapopenaigo"},
 		}},
+
+		// google.golang.org/genai constructor wrap (Transform): user code's
+		// `genai.NewClient(ctx, cfg)` → `whatapgenai.NewClient(ctx, cfg)`. The
+		// helper copies cfg (nil-safe) and installs a wrapped HTTPClient, so
+		// every Models.GenerateContent / GenerateContentStream / EmbedContent
+		// call goes through one RoundTrip. Model comes from the request path
+		// (`models/<name>:generateContent`), tokens from `usageMetadata`.
+		// Returned *genai.Client type unchanged.
+		{Target: "google.golang.org/genai.NewClient", Advice: &Transform{
+			Template: `whatapgenai.NewClient({{.Arg0}}, {{.Arg1}})`,
+			Imports:  []string{"github.com/whatap/go-api/instrumentation/llm/google.golang.org/genai/whatapgenai"},
+		}, Signature: &FuncSignature{MinArgs: 2, MaxArgs: 2}},
+
+		// ollama/api constructors: ClientFromEnvironment() → whatapollama helper
+		// (same OLLAMA_HOST resolution, wrapped http.Client); NewClient(base, hc)
+		// → the http.Client argument is wrapped (nil → wrapped default client).
+		// /api/chat and /api/generate stream NDJSON by default — the adapter
+		// takes model / prompt_eval_count / eval_count from the final
+		// `"done": true` chunk.
+		{Target: "github.com/ollama/ollama/api.ClientFromEnvironment", Advice: &Transform{
+			Template: `whatapollama.ClientFromEnvironment()`,
+			Imports:  []string{"github.com/whatap/go-api/instrumentation/llm/github.com/ollama/ollama/api/whatapollama"},
+		}, Signature: &FuncSignature{MinArgs: 0, MaxArgs: 0}},
+		{Target: "github.com/ollama/ollama/api.NewClient", Advice: &ArgWrap{
+			WhatapPkg: "github.com/whatap/go-api/instrumentation/llm/github.com/ollama/ollama/api/whatapollama", WhatapAlias: "whatapollama",
+			WhatapFunc: "WrapHTTPClient", ArgIndex: 1,
+		}, Signature: &FuncSignature{MinArgs: 2, MaxArgs: 2}},
+
+		// tmc/langchaingo — provider-agnostic (6). langchaingo has no shared
+		// HTTP client hook across providers, so the adapter works at the
+		// llms.Model level instead: model from llms.WithModel (else provider
+		// default), tokens from ContentResponse.Choices[i].GenerationInfo.
+		//   - GenerateFromSinglePrompt(ctx, llm, prompt, ...) → wrap the llm arg
+		//   - m.GenerateContent(ctx, msgs, opts...) on the llms.Model interface
+		//     or on the concrete *LLM of the bundled providers → helper call.
+		// {{.Args1Plus}} keeps `opts...` spread intact.
+		{Target: "github.com/tmc/langchaingo/llms.GenerateFromSinglePrompt", Advice: &ArgWrap{
+			WhatapPkg: "github.com/whatap/go-api/instrumentation/llm/github.com/tmc/langchaingo/whataplangchaingo", WhatapAlias: "whataplangchaingo",
+			WhatapFunc: "WrapModel", ArgIndex: 1,
+		}, Signature: &FuncSignature{MinArgs: 3, MaxArgs: -1}},
+		{Target: "github.com/tmc/langchaingo/llms.Model.GenerateContent", Advice: &Transform{
+			Template: `whataplangchaingo.GenerateContent({{.Receiver}}, {{.Arg0}}, {{.Args1Plus}})`,
+			Imports:  []string{"github.com/whatap/go-api/instrumentation/llm/github.com/tmc/langchaingo/whataplangchaingo"},
+		}, Signature: &FuncSignature{MinArgs: 2, MaxArgs: -1}},
+		{Target: "github.com/tmc/langchaingo/llms/openai.LLM.GenerateContent", Advice: &Transform{
+			Template: `whataplangchaingo.GenerateContent({{.Receiver}}, {{.Arg0}}, {{.Args1Plus}})`,
+			Imports:  []string{"github.com/whatap/go-api/instrumentation/llm/github.com/tmc/langchaingo/whataplangchaingo"},
+		}, Signature: &FuncSignature{MinArgs: 2, MaxArgs: -1}},
+		{Target: "github.com/tmc/langchaingo/llms/anthropic.LLM.GenerateContent", Advice: &Transform{
+			Template: `whataplangchaingo.GenerateContent({{.Receiver}}, {{.Arg0}}, {{.Args1Plus}})`,
+			Imports:  []string{"github.com/whatap/go-api/instrumentation/llm/github.com/tmc/langchaingo/whataplangchaingo"},
+		}, Signature: &FuncSignature{MinArgs: 2, MaxArgs: -1}},
+		{Target: "github.com/tmc/langchaingo/llms/ollama.LLM.GenerateContent", Advice: &Transform{
+			Template: `whataplangchaingo.GenerateContent({{.Receiver}}, {{.Arg0}}, {{.Args1Plus}})`,
+			Imports:  []string{"github.com/whatap/go-api/instrumentation/llm/github.com/tmc/langchaingo/whataplangchaingo"},
+		}, Signature: &FuncSignature{MinArgs: 2, MaxArgs: -1}},
+		{Target: "github.com/tmc/langchaingo/llms/googleai.GoogleAI.GenerateContent", Advice: &Transform{
+			Template: `whataplangchaingo.GenerateContent({{.Receiver}}, {{.Arg0}}, {{.Args1Plus}})`,
+			Imports:  []string{"github.com/whatap/go-api/instrumentation/llm/github.com/tmc/langchaingo/whataplangchaingo"},
+		}, Signature: &FuncSignature{MinArgs: 2, MaxArgs: -1}},
+
+		// MCP (Model Context Protocol) — server handlers + client CallTool.
+		// Each tool / resource / prompt invocation becomes a step (tool name
+		// from req.Params.Name, duration, error = handler error or
+		// CallToolResult.IsError) on the transaction in the handler ctx, next
+		// to the LLM steps. Handler wrap is ArgWrap on the registration call,
+		// so the handler's own type is preserved and re-registration is
+		// idempotent (isWrappedBy).
+
+		// mark3labs/mcp-go (4): MCPServer.AddTool / AddResource / AddPrompt
+		// handler arg, client.Client.CallTool → helper call.
+		{Target: "github.com/mark3labs/mcp-go/server.MCPServer.AddTool", Advice: &ArgWrap{
+			WhatapPkg: "github.com/whatap/go-api/instrumentation/llm/github.com/mark3labs/mcp-go/whatapmcp", WhatapAlias: "whatapmcp",
+			WhatapFunc: "WrapToolHandler", ArgIndex: 1,
+		}, Signature: &FuncSignature{MinArgs: 2, MaxArgs: 2}},
+		{Target: "github.com/mark3labs/mcp-go/server.MCPServer.AddResource", Advice: &ArgWrap{
+			WhatapPkg: "github.com/whatap/go-api/instrumentation/llm/github.com/mark3labs/mcp-go/whatapmcp", WhatapAlias: "whatapmcp",
+			WhatapFunc: "WrapResourceHandler", ArgIndex: 1,
+		}, Signature: &FuncSignature{MinArgs: 2, MaxArgs: 2}},
+		{Target: "github.com/mark3labs/mcp-go/server.MCPServer.AddPrompt", Advice: &ArgWrap{
+			WhatapPkg: "github.com/whatap/go-api/instrumentation/llm/github.com/mark3labs/mcp-go/whatapmcp", WhatapAlias: "whatapmcp",
+			WhatapFunc: "WrapPromptHandler", ArgIndex: 1,
+		}, Signature: &FuncSignature{MinArgs: 2, MaxArgs: 2}},
+		{Target: "github.com/mark3labs/mcp-go/client.Client.CallTool", Advice: &Transform{
+			Template: `whatapmcp.WrapCallTool({{.Arg0}}, {{.Receiver}}, {{.Arg1}})`,
+			Imports:  []string{"github.com/whatap/go-api/instrumentation/llm/github.com/mark3labs/mcp-go/whatapmcp"},
+		}, Signature: &FuncSignature{MinArgs: 2, MaxArgs: 2}},
+
+		// modelcontextprotocol/go-sdk (5): generic mcp.AddTool(s, t, h) wraps
+		// h with the generic WrapToolHandlerFor (In/Out inferred from h);
+		// Server.AddTool / AddResource / AddPrompt take untyped handlers;
+		// ClientSession.CallTool → helper call.
+		{Target: "github.com/modelcontextprotocol/go-sdk/mcp.AddTool", Advice: &ArgWrap{
+			WhatapPkg: "github.com/whatap/go-api/instrumentation/llm/github.com/modelcontextprotocol/go-sdk/mcp/whatapmcpsdk", WhatapAlias: "whatapmcpsdk",
+			WhatapFunc: "WrapToolHandlerFor", ArgIndex: 2,
+		}, Signature: &FuncSignature{MinArgs: 3, MaxArgs: 3}},
+		{Target: "github.com/modelcontextprotocol/go-sdk/mcp.Server.AddTool", Advice: &ArgWrap{
+			WhatapPkg: "github.com/whatap/go-api/instrumentation/llm/github.com/modelcontextprotocol/go-sdk/mcp/whatapmcpsdk", WhatapAlias: "whatapmcpsdk",
+			WhatapFunc: "WrapToolHandler", ArgIndex: 1,
+		}, Signature: &FuncSignature{MinArgs: 2, MaxArgs: 2}},
+		{Target: "github.com/modelcontextprotocol/go-sdk/mcp.Server.AddResource", Advice: &ArgWrap{
+			WhatapPkg: "github.com/whatap/go-api/instrumentation/llm/github.com/modelcontextprotocol/go-sdk/mcp/whatapmcpsdk", WhatapAlias: "whatapmcpsdk",
+			WhatapFunc: "WrapResourceHandler", ArgIndex: 1,
+		}, Signature: &FuncSignature{MinArgs: 2, MaxArgs: 2}},
+		{Target: "github.com/modelcontextprotocol/go-sdk/mcp.Server.AddPrompt", Advice: &ArgWrap{
+			WhatapPkg: "github.com/whatap/go-api/instrumentation/llm/github.com/modelcontextprotocol/go-sdk/mcp/whatapmcpsdk", WhatapAlias: "whatapmcpsdk",
+			WhatapFunc: "WrapPromptHandler", ArgIndex: 1,
+		}, Signature: &FuncSignature{MinArgs: 2, MaxArgs: 2}},
+		{Target: "github.com/modelcontextprotocol/go-sdk/mcp.ClientSession.CallTool", Advice: &Transform{
+			Template: `whatapmcpsdk.WrapCallTool({{.Arg0}}, {{.Receiver}}, {{.Arg1}})`,
+			Imports:  []string{"github.com/whatap/go-api/instrumentation/llm/github.com/modelcontextprotocol/go-sdk/mcp/whatapmcpsdk"},
+		}, Signature: &FuncSignature{MinArgs: 2, MaxArgs: 2}},
+
+		// ── Consumer loops: LoopBody — one transaction per received message ──
+		// sarama (4): for msg := range claim.Messages() { ... }. WrapConfig's
+		// consumer interceptor only sees the fetch; the handler work happens in
+		// the range body, so that body is the transaction. A receive inside a
+		// select case is not a loop body and is left alone.
+		// kafka-go (2) / NATS (3): for { m, err := r.ReadMessage(ctx); ... }.
+		// StartConsume gets (msg, err) and returns nil on error, EndConsume is
+		// nil-safe; JetStream's Msg interface gets its own start func.
+		// NATS channel (1): for m := range ch over a `chan *nats.Msg` fed by
+		// ChanSubscribe — the range loop is the anchor, the element the only
+		// received value.
+		{Target: "github.com/IBM/sarama.ConsumerGroupClaim.Messages", Advice: &LoopBody{
+			WhatapPkg: "github.com/whatap/go-api/instrumentation/github.com/IBM/sarama/whatapsarama", WhatapAlias: "whatapsarama",
+			StartFunc: "StartConsume", EndFunc: "EndConsume",
+		}, Signature: &FuncSignature{MinArgs: 0, MaxArgs: 0}},
+		{Target: "github.com/IBM/sarama.PartitionConsumer.Messages", Advice: &LoopBody{
+			WhatapPkg: "github.com/whatap/go-api/instrumentation/github.com/IBM/sarama/whatapsarama", WhatapAlias: "whatapsarama",
+			StartFunc: "StartConsume", EndFunc: "EndConsume",
+		}, Signature: &FuncSignature{MinArgs: 0, MaxArgs: 0}},
+		{Target: "github.com/Shopify/sarama.ConsumerGroupClaim.Messages", Advice: &LoopBody{
+			WhatapPkg: "github.com/whatap/go-api/instrumentation/github.com/Shopify/sarama/whatapsarama", WhatapAlias: "whatapsarama",
+			StartFunc: "StartConsume", EndFunc: "EndConsume",
+		}, Signature: &FuncSignature{MinArgs: 0, MaxArgs: 0}},
+		{Target: "github.com/Shopify/sarama.PartitionConsumer.Messages", Advice: &LoopBody{
+			WhatapPkg: "github.com/whatap/go-api/instrumentation/github.com/Shopify/sarama/whatapsarama", WhatapAlias: "whatapsarama",
+			StartFunc: "StartConsume", EndFunc: "EndConsume",
+		}, Signature: &FuncSignature{MinArgs: 0, MaxArgs: 0}},
+		{Target: "github.com/segmentio/kafka-go.Reader.ReadMessage", Advice: &LoopBody{
+			WhatapPkg: "github.com/whatap/go-api/instrumentation/github.com/segmentio/kafka-go/whatapkafka", WhatapAlias: "whatapkafka",
+			StartFunc: "StartConsume", EndFunc: "EndConsume",
+		}, Signature: &FuncSignature{MinArgs: 1, MaxArgs: 1}},
+		{Target: "github.com/segmentio/kafka-go.Reader.FetchMessage", Advice: &LoopBody{
+			WhatapPkg: "github.com/whatap/go-api/instrumentation/github.com/segmentio/kafka-go/whatapkafka", WhatapAlias: "whatapkafka",
+			StartFunc: "StartConsume", EndFunc: "EndConsume",
+		}, Signature: &FuncSignature{MinArgs: 1, MaxArgs: 1}},
+		{Target: "github.com/nats-io/nats.go.Subscription.NextMsg", Advice: &LoopBody{
+			WhatapPkg: "github.com/whatap/go-api/instrumentation/github.com/nats-io/nats.go/whatapnats", WhatapAlias: "whatapnats",
+			StartFunc: "StartConsume", EndFunc: "EndConsume",
+		}, Signature: &FuncSignature{MinArgs: 1, MaxArgs: 1}},
+		{Target: "github.com/nats-io/nats.go.Subscription.NextMsgWithContext", Advice: &LoopBody{
+			WhatapPkg: "github.com/whatap/go-api/instrumentation/github.com/nats-io/nats.go/whatapnats", WhatapAlias: "whatapnats",
+			StartFunc: "StartConsume", EndFunc: "EndConsume",
+		}, Signature: &FuncSignature{MinArgs: 1, MaxArgs: 1}},
+		{Target: "range:github.com/nats-io/nats.go.Msg", Advice: &LoopBody{
+			WhatapPkg: "github.com/whatap/go-api/instrumentation/github.com/nats-io/nats.go/whatapnats", WhatapAlias: "whatapnats",
+			StartFunc: "StartChanConsume", EndFunc: "EndConsume",
+		}},
+		{Target: "github.com/nats-io/nats.go/jetstream.MessagesContext.Next", Advice: &LoopBody{
+			WhatapPkg: "github.com/whatap/go-api/instrumentation/github.com/nats-io/nats.go/whatapnats", WhatapAlias: "whatapnats",
+			StartFunc: "StartJetStreamConsume", EndFunc: "EndConsume",
+		}, Signature: &FuncSignature{MinArgs: 0, MaxArgs: -1}},
 	}
 }
@@ -0,0 +1,62 @@
+package ast
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestCacheRules_Engine runs the built-in rules over a type-checked
+// gomemcache / rueidis file: constructors are wrapped or replaced, and
+// memcache operations get the handler's ctx.
+func TestCacheRules_Engine(t *testing.T) {
+	src := `package app
+
+import (
+	"net/http"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/redis/rueidis"
+)
+
+func handler(w http.ResponseWriter, r *http.Request) {
+	mc := memcache.New(servers...)
+	item, err := mc.Get("k")
+	_, _ = item, err
+	rc, err := rueidis.NewClient(rueidis.ClientOption{})
+	_, _ = rc, err
+}
+
+var servers []string
+`
+	file := decorateWithStubs(t, src, map[string]string{
+		"github.com/bradfitz/gomemcache/memcache": `package memcache
+
+type Client struct{}
+type Item struct{}
+
+func New(server ...string) *Client { return nil }
+
+func (c *Client) Get(key string) (*Item, error) { return nil, nil }
+`,
+		"github.com/redis/rueidis": `package rueidis
+
+type ClientOption struct{}
+type Client interface{}
+
+func NewClient(option ClientOption) (Client, error) { return nil, nil }
+`,
+	})
+	if !processBuiltin(file) {
+		t.Fatal("expected a transformation")
+	}
+	got := fileToString(t, file)
+	for _, want := range []string{
+		"mc := whatapmemcache.WrapClient(memcache.New(servers...))",
+		`item, err := whatapmemcache.Get(r.Context(), mc, "k")`,
+		"rc, err := whataprueidis.NewClient(rueidis.ClientOption{})",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("want %q in:\n%s", want, got)
+		}
+	}
+}
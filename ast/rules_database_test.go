@@ -0,0 +1,241 @@
+package ast
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dave/dst"
+)
+
+// TestSQLOpenDB_WrapsConnector verifies sql.OpenDB(connector) wraps the
+// driver.Connector argument, and that sql.Register stays opt-in.
+func TestSQLOpenDB_WrapsConnector(t *testing.T) {
+	src := `package p
+
+import "database/sql"
+
+func f() {
+	db := sql.OpenDB(stdlib.GetConnector(*cfg))
+	_ = db
+}
+`
+	file := parseTestFile(t, src)
+	fn := findFuncDecl(file, "f")
+	call := findFirstCall(file)
+	ctx := &MatchContext{
+		File: file, Mode: ModeInject, Target: "database/sql.OpenDB",
+		Call: call, Sel: call.Fun.(*dst.SelectorExpr), FuncName: "OpenDB",
+		EnclosingFunc: fn, EnclosingStmt: fn.Body.List[0],
+		ParentBlock: &fn.Body.List, StmtIndex: 0, Applied: true,
+	}
+	findRule(t, ctx.Target).Advice.Apply(ctx)
+	want := "db := sql.OpenDB(whatapsql.WrapConnector(stdlib.GetConnector(*cfg)))"
+	if got := fileToString(t, file); !strings.Contains(got, want) {
+		t.Errorf("want %q in:\n%s", want, got)
+	}
+
+	if !findRule(t, "database/sql.Register").OptIn {
+		t.Error("database/sql.Register must be OptIn")
+	}
+}
+
+// TestSQLOpenDB_Engine runs the built-in rules over a type-checked file:
+// OpenDB is wrapped, the opt-in Register rule stays off.
+func TestSQLOpenDB_Engine(t *testing.T) {
+	src := `package app
+
+import (
+	"database/sql"
+
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+func open() *sql.DB {
+	sql.Register("pgx-traced", stdlib.GetDefaultDriver())
+	return sql.OpenDB(stdlib.GetConnector())
+}
+`
+	file := decorateWithStubs(t, src, map[string]string{
+		"github.com/jackc/pgx/v5/stdlib": `package stdlib
+
+import "database/sql/driver"
+
+func GetConnector() driver.Connector { return nil }
+func GetDefaultDriver() driver.Driver { return nil }
+`,
+	})
+	if !processBuiltin(file) {
+		t.Fatal("expected a transformation")
+	}
+	got := fileToString(t, file)
+	if want := "return sql.OpenDB(whatapsql.WrapConnector(stdlib.GetConnector()))"; !strings.Contains(got, want) {
+		t.Errorf("want %q in:\n%s", want, got)
+	}
+	if strings.Contains(got, "WrapDriver") {
+		t.Errorf("sql.Register is opt-in but was wrapped:\n%s", got)
+	}
+}
+
+// entDialectSQLStub is entgo.io/ent/dialect/sql: Open opens database/sql
+// inside the ent module, OpenDB takes a user-opened *sql.DB.
+const entDialectSQLStub = `package sql
+
+import "database/sql"
+
+type Driver struct{}
+
+func Open(driverName, source string) (*Driver, error) { return nil, nil }
+func OpenDB(driver string, db *sql.DB) *Driver       { return nil }
+`
+
+// TestEntRules_Engine runs the built-in rules over a generated ent client
+// (ent.Open → entsql.Open, rewritten to whatapent.Open) and over a driver
+// the user builds from a database/sql handle for ent.NewClient(ent.Driver(drv)):
+// there only sql.Open is rewritten, the ent calls stay as written.
+func TestEntRules_Engine(t *testing.T) {
+	generated := `package ent
+
+import "entgo.io/ent/dialect/sql"
+
+type Client struct{}
+
+func NewClient(drv *sql.Driver) *Client { return nil }
+
+func Open(driverName, dataSourceName string) (*Client, error) {
+	drv, err := sql.Open(driverName, dataSourceName)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(drv), nil
+}
+`
+	file := decorateWithStubs(t, generated, map[string]string{"entgo.io/ent/dialect/sql": entDialectSQLStub})
+	if !processBuiltin(file) {
+		t.Fatal("expected a transformation")
+	}
+	got := fileToString(t, file)
+	for _, want := range []string{
+		"drv, err := whatapent.Open(driverName, dataSourceName)",
+		`"github.com/whatap/go-api/instrumentation/entgo.io/ent/whatapent"`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("generated client: want %q in:\n%s", want, got)
+		}
+	}
+
+	user := `package app
+
+import (
+	"database/sql"
+
+	entsql "entgo.io/ent/dialect/sql"
+
+	"example.com/app/ent"
+)
+
+func open(dsn string) (*ent.Client, error) {
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, err
+	}
+	drv := entsql.OpenDB("postgres", db)
+	return ent.NewClient(ent.Driver(drv)), nil
+}
+`
+	file = decorateWithStubs(t, user, map[string]string{
+		"entgo.io/ent/dialect/sql": entDialectSQLStub,
+		"example.com/app/ent": `package ent
+
+import "entgo.io/ent/dialect/sql"
+
+type Client struct{}
+type Option func(*Client)
+
+func Driver(drv *sql.Driver) Option      { return nil }
+func NewClient(opts ...Option) *Client { return nil }
+`,
+	})
+	if !processBuiltin(file) {
+		t.Fatal("expected a transformation")
+	}
+	got = fileToString(t, file)
+	for _, want := range []string{
+		`db, err := whatapsql.Open("pgx", dsn)`,
+		`drv := entsql.OpenDB("postgres", db)`,
+		"return ent.NewClient(ent.Driver(drv)), nil",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("user-built driver: want %q in:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "whatapent") {
+		t.Errorf("user-built driver must not go through whatapent:\n%s", got)
+	}
+}
+
+// TestBunXormRules_Engine runs the built-in rules over type-checked bun and
+// xorm constructors: bun.NewDB is wrapped, xorm.NewEngine is replaced.
+func TestBunXormRules_Engine(t *testing.T) {
+	src := `package app
+
+import (
+	"database/sql"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
+	"xorm.io/xorm"
+)
+
+func open(sqldb *sql.DB, dsn string) error {
+	db := bun.NewDB(sqldb, pgdialect.New())
+	_ = db
+	engine, err := xorm.NewEngine("mysql", dsn)
+	_ = engine
+	return err
+}
+`
+	file := decorateWithStubs(t, src, map[string]string{
+		"github.com/uptrace/bun/schema": `package schema
+
+type Dialect interface{}
+`,
+		"github.com/uptrace/bun": `package bun
+
+import (
+	"database/sql"
+
+	"github.com/uptrace/bun/schema"
+)
+
+type DB struct{}
+
+func NewDB(sqldb *sql.DB, dialect schema.Dialect) *DB { return nil }
+`,
+		"github.com/uptrace/bun/dialect/pgdialect": `package pgdialect
+
+type Dialect struct{}
+
+func New() *Dialect { return nil }
+`,
+		"xorm.io/xorm": `package xorm
+
+type Engine struct{}
+
+func NewEngine(driverName, dataSourceName string) (*Engine, error) { return nil, nil }
+`,
+	})
+	if !processBuiltin(file) {
+		t.Fatal("expected a transformation")
+	}
+	got := fileToString(t, file)
+	for _, want := range []string{
+		"db := whatapbun.WrapDB(bun.NewDB(sqldb, pgdialect.New()))",
+		`engine, err := whatapxorm.NewEngine("mysql", dsn)`,
+		`"github.com/whatap/go-api/instrumentation/github.com/uptrace/bun/whatapbun"`,
+		`"github.com/whatap/go-api/instrumentation/xorm.io/xorm/whatapxorm"`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("want %q in:\n%s", want, got)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package ast
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestHTTPClientRules_Engine runs the built-in rules over a type-checked
+// resty / retryablehttp file: the constructors are wrapped with WrapClient,
+// while same-named functions of other packages (resty v3, a local package)
+// are left alone.
+func TestHTTPClientRules_Engine(t *testing.T) {
+	src := `package app
+
+import (
+	"net/http"
+
+	"github.com/go-resty/resty/v2"
+	restyv3 "github.com/go-resty/resty/v3"
+	"github.com/hashicorp/go-retryablehttp"
+
+	"example.com/app/internal/retry"
+)
+
+func clients(hc *http.Client) {
+	r1 := resty.New()
+	r2 := resty.NewWithClient(hc)
+	r3 := restyv3.New()
+	rc := retryablehttp.NewClient()
+	lc := retry.NewClient()
+	_, _, _, _, _ = r1, r2, r3, rc, lc
+}
+`
+	file := decorateWithStubs(t, src, map[string]string{
+		"github.com/go-resty/resty/v2": `package resty
+
+import "net/http"
+
+type Client struct{}
+
+func New() *Client                         { return nil }
+func NewWithClient(hc *http.Client) *Client { return nil }
+`,
+		"github.com/go-resty/resty/v3": `package resty
+
+type Client struct{}
+
+func New() *Client { return nil }
+`,
+		"github.com/hashicorp/go-retryablehttp": `package retryablehttp
+
+type Client struct{}
+
+func NewClient() *Client { return nil }
+`,
+		"example.com/app/internal/retry": `package retry
+
+type Client struct{}
+
+func NewClient() *Client { return nil }
+`,
+	})
+	if !processBuiltin(file) {
+		t.Fatal("expected a transformation")
+	}
+	got := fileToString(t, file)
+	for _, want := range []string{
+		"r1 := whatapresty.WrapClient(resty.New())",
+		"r2 := whatapresty.WrapClient(resty.NewWithClient(hc))",
+		"rc := whatapretryablehttp.WrapClient(retryablehttp.NewClient())",
+		"r3 := restyv3.New()",
+		"lc := retry.NewClient()",
+		`"github.com/whatap/go-api/instrumentation/github.com/go-resty/resty/v2/whatapresty"`,
+		`"github.com/whatap/go-api/instrumentation/github.com/hashicorp/go-retryablehttp/whatapretryablehttp"`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("want %q in:\n%s", want, got)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package ast
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dave/dst"
+)
+
+// TestControllerRuntime_WrapsConfigAndReconciler verifies the *rest.Config
+// argument is wrapped even when passed inline, and that the reconciler handed
+// to builder.Complete is wrapped at the registration site.
+func TestControllerRuntime_WrapsConfigAndReconciler(t *testing.T) {
+	src := `package p
+
+func f() {
+	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{})
+	err = ctrl.NewControllerManagedBy(mgr).For(&corev1.Pod{}).Complete(&PodReconciler{})
+	_ = err
+}
+`
+	file := parseTestFile(t, src)
+	fn := findFuncDecl(file, "f")
+	cases := []struct {
+		target, funcName string
+		stmt             int
+		call             *dst.CallExpr
+	}{
+		{target: "sigs.k8s.io/controller-runtime.NewManager", funcName: "NewManager", stmt: 0,
+			call: fn.Body.List[0].(*dst.AssignStmt).Rhs[0].(*dst.CallExpr)},
+		{target: "sigs.k8s.io/controller-runtime/pkg/builder.TypedBuilder.Complete", funcName: "Complete", stmt: 1,
+			call: fn.Body.List[1].(*dst.AssignStmt).Rhs[0].(*dst.CallExpr)},
+	}
+	for _, tc := range cases {
+		ctx := &MatchContext{
+			File: file, Mode: ModeInject, Target: tc.target,
+			Call: tc.call, Sel: tc.call.Fun.(*dst.SelectorExpr), FuncName: tc.funcName,
+			EnclosingFunc: fn, EnclosingStmt: fn.Body.List[tc.stmt],
+			ParentBlock: &fn.Body.List, StmtIndex: tc.stmt, Applied: true,
+		}
+		rule := findRule(t, tc.target)
+		if !matchSignature(ctx, rule.Signature) {
+			t.Fatalf("%s: signature should match", tc.target)
+		}
+		rule.Advice.Apply(ctx)
+	}
+	got := fileToString(t, file)
+	for _, want := range []string{
+		"ctrl.NewManager(whatapkubernetes.WrapConfig(ctrl.GetConfigOrDie()), ctrl.Options{})",
+		".Complete(whatapcontrollerruntime.WrapReconciler(&PodReconciler{}))",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("want %q in:\n%s", want, got)
+		}
+	}
+}
+
+// TestK8sRules_Engine runs the built-in rules over a type-checked operator
+// main: the inline config handed to ctrl.NewManager and dynamic.NewForConfig
+// is wrapped.
+func TestK8sRules_Engine(t *testing.T) {
+	src := `package app
+
+import (
+	"k8s.io/client-go/dynamic"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func run() error {
+	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{})
+	if err != nil {
+		return err
+	}
+	_ = mgr
+	dc, err := dynamic.NewForConfig(ctrl.GetConfigOrDie())
+	_ = dc
+	return err
+}
+`
+	file := decorateWithStubs(t, src, map[string]string{
+		"k8s.io/client-go/rest": `package rest
+
+type Config struct{ Host string }
+`,
+		"k8s.io/client-go/dynamic": `package dynamic
+
+import "k8s.io/client-go/rest"
+
+type DynamicClient struct{}
+
+func NewForConfig(inConfig *rest.Config) (*DynamicClient, error) { return nil, nil }
+`,
+		// NewManager is a package variable (= manager.New) upstream.
+		"sigs.k8s.io/controller-runtime": `package controllerruntime
+
+import "k8s.io/client-go/rest"
+
+type Options struct{}
+type Manager interface{}
+
+func GetConfigOrDie() *rest.Config { return nil }
+
+var NewManager = func(config *rest.Config, options Options) (Manager, error) { return nil, nil }
+`,
+	})
+	if !processBuiltin(file) {
+		t.Fatal("expected a transformation")
+	}
+	got := fileToString(t, file)
+	for _, want := range []string{
+		"ctrl.NewManager(whatapkubernetes.WrapConfig(ctrl.GetConfigOrDie()), ctrl.Options{})",
+		"dynamic.NewForConfig(whatapkubernetes.WrapConfig(ctrl.GetConfigOrDie()))",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("want %q in:\n%s", want, got)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package ast
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dave/dst"
+)
+
+// TestLangchaingoGenerateContent_KeepsSpread verifies the llms.Model rule
+// forwards `opts...` unchanged so the rewritten call still compiles.
+func TestLangchaingoGenerateContent_KeepsSpread(t *testing.T) {
+	src := `package p
+
+func f() {
+	resp, err := m.GenerateContent(ctx, msgs, opts...)
+	_, _ = resp, err
+}
+`
+	file := parseTestFile(t, src)
+	fn := findFuncDecl(file, "f")
+	call := findFirstCall(file)
+	ctx := &MatchContext{
+		File: file, Mode: ModeInject, Target: "github.com/tmc/langchaingo/llms.Model.GenerateContent",
+		Call: call, Sel: call.Fun.(*dst.SelectorExpr), FuncName: "GenerateContent",
+		EnclosingFunc: fn, EnclosingStmt: fn.Body.List[0],
+		ParentBlock: &fn.Body.List, StmtIndex: 0, Applied: true,
+	}
+	rule := findRule(t, ctx.Target)
+	if !matchSignature(ctx, rule.Signature) {
+		t.Fatal("signature should accept (ctx, msgs, opts...)")
+	}
+	rule.Advice.Apply(ctx)
+	want := "resp, err := whataplangchaingo.GenerateContent(m, ctx, msgs, opts...)"
+	if got := fileToString(t, file); !strings.Contains(got, want) {
+		t.Errorf("want %q in:\n%s", want, got)
+	}
+}
+
+// TestOllamaNewClient_WrapsHTTPClient verifies api.NewClient(base, hc) wraps
+// the http.Client argument, including the common nil form.
+func TestOllamaNewClient_WrapsHTTPClient(t *testing.T) {
+	src := `package p
+
+import "github.com/ollama/ollama/api"
+
+func f() {
+	c := api.NewClient(base, nil)
+	_ = c
+}
+`
+	file := parseTestFile(t, src)
+	fn := findFuncDecl(file, "f")
+	call := findFirstCall(file)
+	ctx := &MatchContext{
+		File: file, Mode: ModeInject, Target: "github.com/ollama/ollama/api.NewClient",
+		Call: call, Sel: call.Fun.(*dst.SelectorExpr), FuncName: "NewClient",
+		EnclosingFunc: fn, EnclosingStmt: fn.Body.List[0],
+		ParentBlock: &fn.Body.List, StmtIndex: 0, Applied: true,
+	}
+	findRule(t, ctx.Target).Advice.Apply(ctx)
+	want := "api.NewClient(base, whatapollama.WrapHTTPClient(nil))"
+	if got := fileToString(t, file); !strings.Contains(got, want) {
+		t.Errorf("want %q in:\n%s", want, got)
+	}
+}
+
+// TestMCPSDKAddTool_WrapsHandler verifies the generic go-sdk mcp.AddTool(s, t, h)
+// rule wraps the third (handler) argument and leaves server/tool untouched.
+func TestMCPSDKAddTool_WrapsHandler(t *testing.T) {
+	src := `package p
+
+import "github.com/modelcontextprotocol/go-sdk/mcp"
+
+func f() {
+	mcp.AddTool(s, &mcp.Tool{Name: "greet"}, greet)
+}
+`
+	file := parseTestFile(t, src)
+	fn := findFuncDecl(file, "f")
+	call := findFirstCall(file)
+	ctx := &MatchContext{
+		File: file, Mode: ModeInject, Target: "github.com/modelcontextprotocol/go-sdk/mcp.AddTool",
+		Call: call, Sel: call.Fun.(*dst.SelectorExpr), FuncName: "AddTool",
+		EnclosingFunc: fn, EnclosingStmt: fn.Body.List[0],
+		ParentBlock: &fn.Body.List, StmtIndex: 0, Applied: true,
+	}
+	findRule(t, ctx.Target).Advice.Apply(ctx)
+	want := `mcp.AddTool(s, &mcp.Tool{Name: "greet"}, whatapmcpsdk.WrapToolHandlerFor(greet))`
+	if got := fileToString(t, file); !strings.Contains(got, want) {
+		t.Errorf("want %q in:\n%s", want, got)
+	}
+}
+
+// TestLLMRules_Engine runs the built-in rules over a type-checked file, so
+// the ollama and langchaingo targets are resolved by go/types.
+func TestLLMRules_Engine(t *testing.T) {
+	src := `package app
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/ollama/ollama/api"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/llms/ollama"
+)
+
+func chat(ctx context.Context, base *url.URL, opts ...llms.CallOption) error {
+	c := api.NewClient(base, nil)
+	_ = c
+	m, err := ollama.New()
+	if err != nil {
+		return err
+	}
+	_, err = m.GenerateContent(ctx, nil, opts...)
+	return err
+}
+`
+	file := decorateWithStubs(t, src, map[string]string{
+		"github.com/ollama/ollama/api": `package api
+
+import (
+	"net/http"
+	"net/url"
+)
+
+type Client struct{}
+
+func NewClient(base *url.URL, http *http.Client) *Client { return nil }
+`,
+		"github.com/tmc/langchaingo/llms": `package llms
+
+type MessageContent struct{}
+type ContentResponse struct{}
+type CallOption func()
+`,
+		"github.com/tmc/langchaingo/llms/ollama": `package ollama
+
+import (
+	"context"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+type LLM struct{}
+
+func New() (*LLM, error) { return nil, nil }
+
+func (o *LLM) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	return nil, nil
+}
+`,
+	})
+	if !processBuiltin(file) {
+		t.Fatal("expected a transformation")
+	}
+	got := fileToString(t, file)
+	for _, want := range []string{
+		"c := api.NewClient(base, whatapollama.WrapHTTPClient(nil))",
+		"_, err = whataplangchaingo.GenerateContent(m, ctx, nil, opts...)",
+		`"github.com/whatap/go-api/instrumentation/llm/github.com/ollama/ollama/api/whatapollama"`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("want %q in:\n%s", want, got)
+		}
+	}
+}
+
+// TestMCPRules_Engine runs the built-in rules over a type-checked mcp-go
+// server and client.
+func TestMCPRules_Engine(t *testing.T) {
+	src := `package app
+
+import (
+	"context"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+func serve(s *server.MCPServer, h server.ToolHandlerFunc) {
+	s.AddTool(mcp.Tool{Name: "greet"}, h)
+}
+
+func call(ctx context.Context, c *client.Client, req mcp.CallToolRequest) error {
+	_, err := c.CallTool(ctx, req)
+	return err
+}
+`
+	file := decorateWithStubs(t, src, map[string]string{
+		"github.com/mark3labs/mcp-go/mcp": `package mcp
+
+type Tool struct{ Name string }
+type CallToolRequest struct{}
+type CallToolResult struct{}
+`,
+		"github.com/mark3labs/mcp-go/server": `package server
+
+import (
+	"context"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+type ToolHandlerFunc func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error)
+type MCPServer struct{}
+
+func (s *MCPServer) AddTool(tool mcp.Tool, handler ToolHandlerFunc) {}
+`,
+		"github.com/mark3labs/mcp-go/client": `package client
+
+import (
+	"context"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+type Client struct{}
+
+func (c *Client) CallTool(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return nil, nil
+}
+`,
+	})
+	if !processBuiltin(file) {
+		t.Fatal("expected a transformation")
+	}
+	got := fileToString(t, file)
+	for _, want := range []string{
+		`s.AddTool(mcp.Tool{Name: "greet"}, whatapmcp.WrapToolHandler(h))`,
+		"_, err := whatapmcp.WrapCallTool(ctx, c, req)",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("want %q in:\n%s", want, got)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package ast
 
 import (
 	"fmt"
+	"go/types"
 	"os"
 	"strings"
 
@@ -52,7 +53,7 @@ type RuleSpec struct {
 	WrapExpr    string `yaml:"wrapExpr,omitempty"`
 	ExtraImport string `yaml:"extraImport,omitempty"`
 
-	// type=field-wrap / field-wrap-or-insert
+	// type=field-wrap / field-wrap-or-insert / field-assign-wrap
 	FieldName  string `yaml:"fieldName,omitempty"`
 	WrapWith   string `yaml:"wrapWith,omitempty"`
 	InsertWith string `yaml:"insertWith,omitempty"`
@@ -67,7 +68,7 @@ type RuleSpec struct {
 	Before string `yaml:"before,omitempty"`
 	After  string `yaml:"after,omitempty"`
 
-	// type=inject
+	// type=inject / around (code templates) / loop-body ("alias.Func")
 	Start string `yaml:"start,omitempty"`
 	End   string `yaml:"end,omitempty"`
 
@@ -79,6 +80,25 @@ type RuleSpec struct {
 	Signature *SignatureSpec    `yaml:"signature,omitempty"`
 	Receiver  *ReceiverSpec     `yaml:"receiver,omitempty"`
 	Fields    []FieldMatchSpec  `yaml:"fields,omitempty"`
+
+	// Restricts the rule to parts of the user's code (see RuleScope).
+	Scope *ScopeSpec `yaml:"scope,omitempty"`
+	// Registers the rule only for matching dependency versions (see ModuleCondition).
+	When *WhenSpec `yaml:"when,omitempty"`
+	// //go:build expression the build must satisfy, e.g. "linux && !cgo".
+	Build string `yaml:"build,omitempty"`
+}
+
+// WhenSpec maps to ModuleCondition.
+type WhenSpec struct {
+	Module  string `yaml:"module,omitempty"`
+	Version string `yaml:"version"`
+}
+
+// ScopeSpec maps to RuleScope.
+type ScopeSpec struct {
+	Include []string `yaml:"include,omitempty"`
+	Exclude []string `yaml:"exclude,omitempty"`
 }
 
 // InsertedArgSpec mirrors InsertedArg for arg-insert.
@@ -93,6 +113,9 @@ type SignatureSpec struct {
 	Results []TypeNameSpec `yaml:"results,omitempty"`
 	MinArgs *int           `yaml:"minArgs,omitempty"`
 	MaxArgs *int           `yaml:"maxArgs,omitempty"`
+	// TypeArgs filters generic instantiations; name "_" matches any type.
+	// Also written inline in the target: "mycorp/cache.Get[mycorp/model.User]".
+	TypeArgs []TypeNameSpec `yaml:"typeArgs,omitempty"`
 }
 
 // TypeNameSpec maps to TypeName.
@@ -264,9 +287,64 @@ func buildSignature(spec *SignatureSpec) *FuncSignature {
 	for _, r := range spec.Results {
 		sig.Results = append(sig.Results, TypeName{ImportPath: r.Package, Name: r.Name})
 	}
+	for _, a := range spec.TypeArgs {
+		sig.TypeArgs = append(sig.TypeArgs, TypeName{ImportPath: a.Package, Name: a.Name})
+	}
 	return sig
 }
 
+// splitTargetTypeArgs moves type arguments written in a target into a
+// TypeArgs filter:
+//
+//	"mycorp/cache.Get[mycorp/model.User]"      → "mycorp/cache.Get", [mycorp/model.User]
+//	"mycorp/repo.Repo[*mycorp/model.User].Find" → "mycorp/repo.Repo.Find", [*mycorp/model.User]
+//	"mycorp/cache.Map[K, string]"              → "mycorp/cache.Map", [_, string]
+//
+// A bare identifier that is not a predeclared type (T, K, _) is a
+// placeholder matching any type; brackets holding only placeholders add no
+// filter. "re:" targets are returned untouched — brackets there are
+// character classes.
+func splitTargetTypeArgs(target string) (string, []TypeName, error) {
+	open := strings.Index(target, "[")
+	if open < 0 || strings.HasPrefix(target, "re:") {
+		return target, nil, nil
+	}
+	end := strings.Index(target[open:], "]")
+	if end < 0 {
+		return "", nil, fmt.Errorf("target %q: unclosed \"[\"", target)
+	}
+	end += open
+	list := target[open+1 : end]
+	rest := target[end+1:]
+	if strings.ContainsAny(list, "[") || strings.ContainsAny(rest, "[]") {
+		return "", nil, fmt.Errorf("target %q: one flat type-argument list allowed", target)
+	}
+	var args []TypeName
+	concrete := false
+	for _, arg := range strings.Split(list, ",") {
+		arg = strings.TrimSpace(arg)
+		name := strings.TrimPrefix(arg, "*")
+		ptr := arg[:len(arg)-len(name)]
+		if name == "" {
+			return "", nil, fmt.Errorf("target %q: empty type argument", target)
+		}
+		_, predeclared := types.Universe.Lookup(name).(*types.TypeName)
+		if dot := strings.LastIndex(name, "."); dot >= 0 {
+			args = append(args, TypeName{ImportPath: name[:dot], Name: ptr + name[dot+1:]})
+			concrete = true
+		} else if predeclared {
+			args = append(args, TypeName{Name: ptr + name})
+			concrete = true
+		} else {
+			args = append(args, TypeName{Name: "_"})
+		}
+	}
+	if !concrete {
+		args = nil
+	}
+	return target[:open] + rest, args, nil
+}
+
 // buildReceiver converts the yaml ReceiverSpec into *TypeName.
 func buildReceiver(spec *ReceiverSpec) *TypeName {
 	if spec == nil {
@@ -287,6 +365,13 @@ func buildFields(specs []FieldMatchSpec) []FieldMatch {
 	return out
 }
 
+func buildScope(spec *ScopeSpec) *RuleScope {
+	if spec == nil || (len(spec.Include) == 0 && len(spec.Exclude) == 0) {
+		return nil
+	}
+	return &RuleScope{Include: spec.Include, Exclude: spec.Exclude}
+}
+
 // reverseAliases converts alias→path into path→alias (used by Transform).
 func reverseAliases(in map[string]string) map[string]string {
 	if len(in) == 0 {
@@ -299,17 +384,101 @@ func reverseAliases(in map[string]string) map[string]string {
 	return out
 }
 
+// nonCallTypes are the rule types whose targets are not call sites: composite
+// literals (field-wrap*), field assignments and function declarations.
+var nonCallTypes = map[string]bool{
+	"field-wrap":           true,
+	"field-wrap-or-insert": true,
+	"field-assign-wrap":    true,
+	"inject":               true,
+	"around":               true,
+}
+
 // buildRule dispatches on spec.Type and produces a single Rule.
 func buildRule(cfg *RulesConfig, spec *RuleSpec) (*Rule, error) {
-	target := normalizeTarget(spec.Target)
+	target, typeArgs, err := splitTargetTypeArgs(normalizeTarget(spec.Target))
+	if err != nil {
+		return nil, err
+	}
 	aliases := mergeAliases(cfg.ImportAliases, spec.ImportAliases)
 
+	if typeArgs != nil && spec.Signature != nil && spec.Signature.TypeArgs != nil {
+		return nil, fmt.Errorf("type arguments given both in the target and in signature.typeArgs")
+	}
+	if typeArgs != nil || (spec.Signature != nil && spec.Signature.TypeArgs != nil) {
+		for _, prefix := range []string{"decl:", valuePrefix, rangePrefix, ifacePrefix, implementsPrefix} {
+			if strings.HasPrefix(target, prefix) {
+				return nil, fmt.Errorf("type arguments filter call sites and composite literals; %q targets do not take them", prefix)
+			}
+		}
+	}
+
+	targetKind := ""
+	if isInterfaceTarget(target) {
+		if _, err := parseInterfaceTarget(target); err != nil {
+			return nil, err
+		}
+		targetKind = "an interface"
+		// Interface targets match method calls only; these types rewrite
+		// composite literals, field assignments or declarations.
+		if nonCallTypes[spec.Type] {
+			return nil, fmt.Errorf("type %q does not accept an interface target (iface:/implements: match method calls)", spec.Type)
+		}
+	} else if isPatternTarget(target) {
+		if _, err := compileTargetPattern(target); err != nil {
+			return nil, err
+		}
+		targetKind = "a pattern"
+	}
+	if targetKind != "" && spec.Type == "replace-with-ctx" {
+		return nil, fmt.Errorf("replace-with-ctx derives the replaced call from its target and does not accept %s target", targetKind)
+	}
+
+	// "value:" targets name function references, which only value-wrap
+	// knows how to rewrite; every other type expects a call, literal,
+	// assignment or declaration.
+	if isValue := strings.HasPrefix(target, valuePrefix); isValue != (spec.Type == "value-wrap") {
+		if isValue {
+			return nil, fmt.Errorf("type %q does not accept a value: target (use value-wrap)", spec.Type)
+		}
+		return nil, fmt.Errorf("value-wrap target must start with %q", valuePrefix)
+	}
+	// "range:" targets name the loop over a channel, which only loop-body
+	// instruments; the element type is spelled out, never a pattern.
+	if strings.HasPrefix(target, rangePrefix) {
+		if spec.Type != "loop-body" {
+			return nil, fmt.Errorf("type %q does not accept a range: target (use loop-body)", spec.Type)
+		}
+		if isPatternTarget(target) {
+			return nil, fmt.Errorf("range: targets name one element type and do not take patterns")
+		}
+	}
+
 	rule := &Rule{
 		Target:    target,
 		OptIn:     spec.OptIn,
+		ID:        spec.ID,
 		Signature: buildSignature(spec.Signature),
 		Receiver:  buildReceiver(spec.Receiver),
 		Fields:    buildFields(spec.Fields),
+		Scope:     buildScope(spec.Scope),
+	}
+	if err := validateScope(rule.Scope); err != nil {
+		return nil, err
+	}
+	if rule.When, err = buildWhen(spec.When, target); err != nil {
+		return nil, err
+	}
+	if spec.Build != "" {
+		if rule.Build, err = parseBuildCondition(spec.Build); err != nil {
+			return nil, err
+		}
+	}
+	if typeArgs != nil {
+		if rule.Signature == nil {
+			rule.Signature = &FuncSignature{MinArgs: -1, MaxArgs: -1}
+		}
+		rule.Signature.TypeArgs = typeArgs
 	}
 
 	switch spec.Type {
@@ -367,6 +536,17 @@ func buildRule(cfg *RulesConfig, spec *RuleSpec) (*Rule, error) {
 			ArgIndex: ptrInt(spec.ArgIndex, -1),
 		}
 
+	case "value-wrap":
+		alias, fn, err := splitWith(spec.With)
+		if err != nil {
+			return nil, err
+		}
+		pkg := resolveAlias(alias, aliases)
+		if pkg == "" {
+			return nil, fmt.Errorf("unknown importAlias %q for %q", alias, spec.With)
+		}
+		rule.Advice = &ValueWrap{WhatapPkg: pkg, WhatapAlias: alias, WhatapFunc: fn}
+
 	case "arg-insert":
 		if spec.WhatapAlias == "" {
 			return nil, fmt.Errorf("arg-insert requires whatapAlias")
@@ -462,7 +642,27 @@ func buildRule(cfg *RulesConfig, spec *RuleSpec) (*Rule, error) {
 			FieldName: spec.FieldName, CtxAware: spec.CtxAware,
 		}
 
+	case "field-assign-wrap":
+		alias, fn, err := splitWith(spec.With)
+		if err != nil {
+			return nil, err
+		}
+		pkg := resolveAlias(alias, aliases)
+		if pkg == "" {
+			return nil, fmt.Errorf("unknown importAlias %q for %q", alias, spec.With)
+		}
+		if !strings.HasSuffix(target, "=") {
+			return nil, fmt.Errorf(`field-assign-wrap target must be "pkg.Type.Field=", got %q`, spec.Target)
+		}
+		rule.Advice = &FieldAssignWrap{
+			WhatapPkg: pkg, WhatapAlias: alias, WhatapFunc: fn,
+			CtxAware: spec.CtxAware,
+		}
+
 	case "transform":
+		if err := validateTemplate("template", spec.Template); err != nil {
+			return nil, err
+		}
 		// imports: list of import paths. Resolve aliases via the shared map
 		// and convert to internal path→alias form.
 		paths := append([]string(nil), spec.Imports...)
@@ -475,6 +675,12 @@ func buildRule(cfg *RulesConfig, spec *RuleSpec) (*Rule, error) {
 		}
 
 	case "hook":
+		if err := validateTemplate("before", spec.Before); err != nil {
+			return nil, err
+		}
+		if err := validateTemplate("after", spec.After); err != nil {
+			return nil, err
+		}
 		localAliases := mergeAliases(cfg.ImportAliases, spec.ImportAliases)
 		paths := append([]string(nil), spec.Imports...)
 		rule.Advice = &Hook{
@@ -488,6 +694,12 @@ func buildRule(cfg *RulesConfig, spec *RuleSpec) (*Rule, error) {
 		if !strings.HasPrefix(spec.Target, "decl:") {
 			return nil, fmt.Errorf(`inject rule target must start with "decl:"`)
 		}
+		if err := validateTemplate("start", spec.Start); err != nil {
+			return nil, err
+		}
+		if err := validateTemplate("end", spec.End); err != nil {
+			return nil, err
+		}
 		localAliases := mergeAliases(cfg.ImportAliases, spec.ImportAliases)
 		paths := append([]string(nil), spec.Imports...)
 		rule.Advice = &Inject{
@@ -497,6 +709,52 @@ func buildRule(cfg *RulesConfig, spec *RuleSpec) (*Rule, error) {
 			ImportAliases: pathAliasMap(paths, localAliases),
 		}
 
+	case "around":
+		if !strings.HasPrefix(spec.Target, "decl:") {
+			return nil, fmt.Errorf(`around rule target must start with "decl:"`)
+		}
+		if strings.TrimSpace(spec.End) == "" {
+			return nil, fmt.Errorf(`around requires "end"`)
+		}
+		if err := validateTemplate("start", spec.Start); err != nil {
+			return nil, err
+		}
+		if err := validateTemplate("end", spec.End); err != nil {
+			return nil, err
+		}
+		localAliases := mergeAliases(cfg.ImportAliases, spec.ImportAliases)
+		paths := append([]string(nil), spec.Imports...)
+		rule.Advice = &Around{
+			Start:         spec.Start,
+			End:           spec.End,
+			Imports:       paths,
+			ImportAliases: pathAliasMap(paths, localAliases),
+		}
+
+	case "loop-body":
+		if spec.Start == "" || spec.End == "" {
+			return nil, fmt.Errorf(`loop-body requires "start" and "end" ("alias.Func")`)
+		}
+		alias, startFn, err := splitWith(spec.Start)
+		if err != nil {
+			return nil, err
+		}
+		endAlias, endFn, err := splitWith(spec.End)
+		if err != nil {
+			return nil, err
+		}
+		if endAlias != alias {
+			return nil, fmt.Errorf("loop-body start/end must use the same alias, got %q and %q", alias, endAlias)
+		}
+		pkg := resolveAlias(alias, aliases)
+		if pkg == "" {
+			return nil, fmt.Errorf("unknown importAlias %q for %q", alias, spec.Start)
+		}
+		rule.Advice = &LoopBody{
+			WhatapPkg: pkg, WhatapAlias: alias,
+			StartFunc: startFn, EndFunc: endFn,
+		}
+
 	case "add":
 		return nil, fmt.Errorf(`type "add" is handled outside the Engine (ast/custom/add.go)`)
 
@@ -505,9 +763,30 @@ func buildRule(cfg *RulesConfig, spec *RuleSpec) (*Rule, error) {
 	default:
 		return nil, fmt.Errorf("unknown rule type %q", spec.Type)
 	}
+	rule.DryRunErr = dryRunSpec(spec)
 	return rule, nil
 }
 
+// dryRunSpec dry-runs the code templates of a transform / hook / inject /
+// around rule (see dryRunTemplate). Returns the first failure.
+func dryRunSpec(spec *RuleSpec) error {
+	var fields [][2]string
+	switch spec.Type {
+	case "transform":
+		fields = [][2]string{{"template", spec.Template}}
+	case "hook":
+		fields = [][2]string{{"before", spec.Before}, {"after", spec.After}}
+	case "inject", "around":
+		fields = [][2]string{{"start", spec.Start}, {"end", spec.End}}
+	}
+	for _, f := range fields {
+		if err := dryRunTemplate(f[0], f[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // resolveTransformImports is a hook for transform rules to include any additional
 // import paths referenced by aliases (e.g. "whatapas" in the template implies the
 // whatapas package should be imported, but only if the user lists it in imports).
@@ -107,6 +107,8 @@ func adviceSignature(a Advice) string {
 		return "replace-with-ctx:" + v.WhatapPkg + "." + v.WhatapFunc
 	case *ArgWrap:
 		return fmt.Sprintf("arg-wrap:%s.%s@%d", v.WhatapPkg, v.WhatapFunc, v.ArgIndex)
+	case *ValueWrap:
+		return "value-wrap:" + v.WhatapPkg + "." + v.WhatapFunc
 	case *ArgInsert:
 		return "arg-insert:" + v.WhatapAlias
 	case *CodeInsert:
@@ -117,6 +119,8 @@ func adviceSignature(a Advice) string {
 		return "field-wrap:" + v.WhatapPkg + "." + v.WhatapFunc
 	case *FieldWrapOrInsert:
 		return "field-wrap-or-insert:" + v.WhatapPkg + "." + v.WrapFunc
+	case *FieldAssignWrap:
+		return "field-assign-wrap:" + v.WhatapPkg + "." + v.WhatapFunc
 	case *Transform:
 		// §272 Phase 3 Step 4 — ReverseTarget field removed; identify
 		// Transform by the first import path (transformer fingerprint).
@@ -129,6 +133,10 @@ func adviceSignature(a Advice) string {
 		return "hook"
 	case *Inject:
 		return "inject"
+	case *Around:
+		return "around"
+	case *LoopBody:
+		return "loop-body:" + v.WhatapPkg + "." + v.StartFunc
 	case *OnMatchFunc:
 		return "on-match"
 	}
@@ -215,6 +223,16 @@ func diffAdvice(a, b Advice) string {
 		if !reflect.DeepEqual(ga, gb) {
 			return fmt.Sprintf("%+v vs %+v", ga, gb)
 		}
+	case *FieldAssignWrap:
+		gb := b.(*FieldAssignWrap)
+		if !reflect.DeepEqual(ga, gb) {
+			return fmt.Sprintf("%+v vs %+v", ga, gb)
+		}
+	case *LoopBody:
+		gb := b.(*LoopBody)
+		if !reflect.DeepEqual(ga, gb) {
+			return fmt.Sprintf("%+v vs %+v", ga, gb)
+		}
 	case *Transform:
 		// §272 Phase 3 Step 4 — ReverseTarget field removed; no longer
 		// part of the yaml↔Go field-level diff.
@@ -0,0 +1,54 @@
+package ast
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestSearchDefaultClient_Transform verifies NewDefaultClient() is rewritten to
+// NewClient(Config{Transport: ...}) under the user's import name — explicit
+// alias for elasticsearch, the declared package name (go/types) for
+// opensearch-go/v4, whose path base is "opensearch-go".
+func TestSearchDefaultClient_Transform(t *testing.T) {
+	cases := []struct {
+		importPath, importLine, call, want string
+	}{
+		{
+			importPath: "github.com/elastic/go-elasticsearch/v8",
+			importLine: `es "github.com/elastic/go-elasticsearch/v8"`,
+			call:       "es.NewDefaultClient()",
+			want:       "c, err := es.NewClient(es.Config{Transport: whatapelasticsearch.NewTransport()})",
+		},
+		{
+			importPath: "github.com/opensearch-project/opensearch-go/v4",
+			importLine: `"github.com/opensearch-project/opensearch-go/v4"`,
+			call:       "opensearch.NewDefaultClient()",
+			want:       "c, err := opensearch.NewClient(opensearch.Config{Transport: whatapopensearch.NewTransport()})",
+		},
+	}
+	for _, tc := range cases {
+		src := "package p\n\nimport " + tc.importLine + "\n\nfunc f() {\n\tc, err := " + tc.call + "\n\t_, _ = c, err\n}\n"
+		pkgName := "elasticsearch"
+		if strings.Contains(tc.importPath, "opensearch") {
+			pkgName = "opensearch"
+		}
+		file := decorateWithStubs(t, src, map[string]string{tc.importPath: "package " + pkgName + searchClientStub})
+		processBuiltin(file)
+		if got := fileToString(t, file); !strings.Contains(got, tc.want) {
+			t.Errorf("%s: want %q in:\n%s", tc.importPath, tc.want, got)
+		}
+	}
+}
+
+// searchClientStub is the client API elasticsearch v8 and opensearch-go
+// share, minus the package clause.
+const searchClientStub = `
+
+import "net/http"
+
+type Config struct{ Transport http.RoundTripper }
+type Client struct{}
+
+func NewClient(Config) (*Client, error) { return nil, nil }
+func NewDefaultClient() (*Client, error) { return nil, nil }
+`
@@ -0,0 +1,76 @@
+package ast
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestTemporalRules_Engine runs the built-in rules over type-checked
+// client.Options{} / worker.Options{} literals: Interceptors is inserted
+// when absent and an existing slice is wrapped.
+func TestTemporalRules_Engine(t *testing.T) {
+	src := `package app
+
+import (
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/worker"
+)
+
+func setup(c client.Client, ci []interceptor.ClientInterceptor, wi []interceptor.WorkerInterceptor) {
+	o1 := client.Options{HostPort: "localhost:7233"}
+	o2 := client.Options{HostPort: "localhost:7233", Interceptors: ci}
+	w1 := worker.New(c, "orders", worker.Options{})
+	w2 := worker.New(c, "orders", worker.Options{Interceptors: wi})
+	_, _, _, _ = o1, o2, w1, w2
+}
+`
+	file := decorateWithStubs(t, src, map[string]string{
+		"go.temporal.io/sdk/interceptor": `package interceptor
+
+type ClientInterceptor interface{}
+type WorkerInterceptor interface{}
+`,
+		"go.temporal.io/sdk/client": `package client
+
+import "go.temporal.io/sdk/interceptor"
+
+type Client interface{}
+
+type Options struct {
+	HostPort     string
+	Interceptors []interceptor.ClientInterceptor
+}
+`,
+		"go.temporal.io/sdk/worker": `package worker
+
+import (
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/interceptor"
+)
+
+type Worker interface{}
+
+type Options struct {
+	Interceptors []interceptor.WorkerInterceptor
+}
+
+func New(c client.Client, taskQueue string, options Options) Worker { return nil }
+`,
+	})
+	if !processBuiltin(file) {
+		t.Fatal("expected a transformation")
+	}
+	got := fileToString(t, file)
+	for _, want := range []string{
+		`o1 := client.Options{HostPort: "localhost:7233", Interceptors: whataptemporal.ClientInterceptors()}`,
+		`o2 := client.Options{HostPort: "localhost:7233", Interceptors: whataptemporal.AppendClientInterceptors(ci)}`,
+		`w1 := worker.New(c, "orders", worker.Options{Interceptors: whataptemporal.WorkerInterceptors()})`,
+		`w2 := worker.New(c, "orders", worker.Options{Interceptors: whataptemporal.AppendWorkerInterceptors(wi)})`,
+		`"github.com/whatap/go-api/instrumentation/go.temporal.io/sdk/whataptemporal"`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("want %q in:\n%s", want, got)
+		}
+	}
+}
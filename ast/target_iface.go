@@ -0,0 +1,162 @@
+package ast
+
+import (
+	"fmt"
+	"go/types"
+	"strings"
+
+	"github.com/dave/dst"
+	"github.com/whatap/go-api-inst/ast/common"
+)
+
+// Interface targets. A call-site rule can name an interface instead of a
+// concrete receiver type:
+//
+//	iface:io.Writer.Write             w.Write(p) where w's static type is an
+//	                                  interface that includes io.Writer
+//	implements:mycorp/store.Store     any Store method called on a value whose
+//	                                  static type implements Store (concrete
+//	                                  repositories and the interface itself)
+//	implements:mycorp/store.Store.Get only Get
+//
+// Matching uses go/types method sets, so it needs type info and the
+// interface's package must be in the calling package's import graph
+// (common.LookupNamedType). Exact and pattern targets win over interface
+// targets; the receiver must be of a named type (the call must resolve to a
+// "pkg.Type.Method" target).
+const (
+	ifacePrefix      = "iface:"
+	implementsPrefix = "implements:"
+)
+
+// isInterfaceTarget reports whether target is an iface: / implements: target.
+func isInterfaceTarget(target string) bool {
+	return strings.HasPrefix(target, ifacePrefix) || strings.HasPrefix(target, implementsPrefix)
+}
+
+// ifaceTarget is a parsed interface target.
+type ifaceTarget struct {
+	pkgPath string
+	name    string // interface type name
+	method  string // "" = any method of the interface (implements: only)
+	// concrete allows receivers of non-interface types (implements:);
+	// iface: only matches calls dispatched through an interface value.
+	concrete bool
+}
+
+// parseInterfaceTarget splits "iface:pkg.Iface.Method" or
+// "implements:pkg.Iface[.Method]". The package path is split off as in
+// ExtractRulePackage.
+func parseInterfaceTarget(target string) (*ifaceTarget, error) {
+	body, concrete := strings.CutPrefix(target, implementsPrefix)
+	want := "pkg.Interface[.Method]"
+	if !concrete {
+		body = strings.TrimPrefix(target, ifacePrefix)
+		want = "pkg.Interface.Method"
+	}
+	pkg := ExtractRulePackage(body)
+	if pkg == "" || len(pkg) >= len(body) {
+		return nil, fmt.Errorf("target %q: want %s", target, want)
+	}
+	parts := strings.Split(body[len(pkg)+1:], ".")
+	t := &ifaceTarget{pkgPath: pkg, name: parts[0], concrete: concrete}
+	if len(parts) == 2 {
+		t.method = parts[1]
+	}
+	if len(parts) > 2 || (t.method == "" && !concrete) || t.name == "" || (len(parts) == 2 && t.method == "") {
+		return nil, fmt.Errorf("target %q: want %s", target, want)
+	}
+	return t, nil
+}
+
+// ifaceRule is a call-site rule with a parsed interface target.
+type ifaceRule struct {
+	rule   *Rule
+	target *ifaceTarget
+}
+
+// matches reports whether calling method on a receiver of type recv is
+// covered by the target. ok is false when the interface cannot be found in
+// the current type context — the caller must not memoise that answer.
+func (t *ifaceTarget) matches(recv types.Type, method string) (hit, ok bool) {
+	if t.method != "" && t.method != method {
+		return false, true
+	}
+	named := common.LookupNamedType(t.pkgPath, t.name)
+	if named == nil {
+		return false, false
+	}
+	iface, isIface := named.Underlying().(*types.Interface)
+	if !isIface {
+		return false, true
+	}
+	if t.method == "" && !hasMethod(iface, method) {
+		return false, true
+	}
+	if types.IsInterface(recv) {
+		return types.Implements(recv, iface), true
+	}
+	if !t.concrete {
+		return false, true
+	}
+	// x.M() on an addressable T also reaches *T's methods.
+	if types.Implements(recv, iface) {
+		return true, true
+	}
+	if _, isPtr := recv.(*types.Pointer); !isPtr {
+		return types.Implements(types.NewPointer(recv), iface), true
+	}
+	return false, true
+}
+
+func hasMethod(iface *types.Interface, name string) bool {
+	for i := 0; i < iface.NumMethods(); i++ {
+		if iface.Method(i).Name() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupInterface returns the first interface rule accept allows (nil
+// accepts all) covering a method call whose exact/pattern lookup missed.
+// Matches are memoised per receiver type and method: the target string alone
+// does not decide the match — Box[int].Get and Box[string].Get share
+// "pkg.Box.Get".
+func (r *Registry) lookupInterface(node dst.Node, accept func(*Rule) bool) *Rule {
+	if len(r.ifaceRules) == 0 || !common.HasTypeInfo() {
+		return nil
+	}
+	call, ok := node.(*dst.CallExpr)
+	if !ok {
+		return nil
+	}
+	sel, ok := call.Fun.(*dst.SelectorExpr)
+	if !ok {
+		return nil
+	}
+	recv := common.ResolveType(sel.X)
+	if recv == nil || recv == types.Typ[types.Invalid] {
+		return nil // package-qualified call or unresolved receiver
+	}
+	key := types.TypeString(recv, nil) + "." + sel.Sel.Name
+	if hits, ok := r.ifaceHits[key]; ok {
+		return firstAccepted(hits, accept)
+	}
+	var hits []*Rule
+	memo := true
+	for _, ir := range r.ifaceRules {
+		matched, ok := ir.target.matches(recv, sel.Sel.Name)
+		memo = memo && ok
+		if matched {
+			hits = append(hits, ir.rule)
+		}
+	}
+	if memo {
+		if r.ifaceHits == nil {
+			r.ifaceHits = make(map[string][]*Rule)
+		}
+		r.ifaceHits[key] = hits
+	}
+	return firstAccepted(hits, accept)
+}
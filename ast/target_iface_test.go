@@ -0,0 +1,227 @@
+package ast
+
+import (
+	goast "go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+
+	"github.com/dave/dst"
+	"github.com/dave/dst/decorator"
+	"github.com/whatap/go-api-inst/ast/common"
+)
+
+// decorateTyped type-checks src as package example.com/store and installs
+// the type context for the returned dst file. Imports come from source.
+func decorateTyped(t *testing.T, src string) *dst.File {
+	t.Helper()
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "store.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := &types.Info{
+		Types:     make(map[goast.Expr]types.TypeAndValue),
+		Uses:      make(map[*goast.Ident]types.Object),
+		Instances: make(map[*goast.Ident]types.Instance),
+	}
+	conf := &types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	if _, err := conf.Check("example.com/store", fset, []*goast.File{f}, info); err != nil {
+		t.Fatal(err)
+	}
+	dec := decorator.NewDecorator(fset)
+	file, err := dec.DecorateFile(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	common.SetTypeContext(info, dec.Ast.Nodes)
+	t.Cleanup(common.ClearTypeContext)
+	return file
+}
+
+func TestParseInterfaceTarget(t *testing.T) {
+	for target, want := range map[string]ifaceTarget{
+		"iface:io.Writer.Write":                 {pkgPath: "io", name: "Writer", method: "Write"},
+		"implements:mycorp/store.Store":         {pkgPath: "mycorp/store", name: "Store", concrete: true},
+		"implements:mycorp/store.Store.Get":     {pkgPath: "mycorp/store", name: "Store", method: "Get", concrete: true},
+		"iface:gopkg.in/yaml.v3.Marshaler.Save": {pkgPath: "gopkg.in/yaml.v3", name: "Marshaler", method: "Save"},
+	} {
+		got, err := parseInterfaceTarget(target)
+		if err != nil || *got != want {
+			t.Errorf("parseInterfaceTarget(%q) = %+v, %v; want %+v", target, got, err, want)
+		}
+	}
+	for _, target := range []string{"iface:io.Writer", "iface:io", "implements:mycorp/store.Store.Get.X", "implements:mycorp/store"} {
+		if _, err := parseInterfaceTarget(target); err == nil {
+			t.Errorf("parseInterfaceTarget(%q) should fail", target)
+		}
+	}
+}
+
+// TestEngine_InterfaceTargets hooks calls through the Store interface and
+// through every type implementing it, and io.Writer.Write calls made through
+// interface values only.
+func TestEngine_InterfaceTargets(t *testing.T) {
+	src := `package store
+
+import (
+	"io"
+	"os"
+)
+
+func trace(name string) {}
+
+type Store interface {
+	Get(id string) string
+	Put(id, v string)
+}
+
+// Cached embeds Store, so it implements it too.
+type Cached interface {
+	Store
+	Flush()
+}
+
+type memStore struct{}
+
+func (m *memStore) Get(id string) string { return "" }
+func (m *memStore) Put(id, v string)     {}
+func (m *memStore) Len() int             { return 0 }
+
+type notAStore struct{}
+
+func (n notAStore) Get(id string) string { return "" }
+
+func use(s Store, c Cached, w io.Writer, rw io.ReadWriter) {
+	var m memStore
+	s.Get("a")
+	c.Put("b", "v")
+	c.Flush()
+	m.Get("c")
+	m.Len()
+	notAStore{}.Get("d")
+	w.Write(nil)
+	rw.Write(nil)
+	os.Stdout.Write(nil)
+}
+`
+	file := decorateTyped(t, src)
+	cfg := &RulesConfig{Rules: []RuleSpec{
+		{Type: "hook", Target: "implements:example.com/store.Store", Before: `trace({{quote recvType}})`},
+		{Type: "hook", Target: "iface:io.Writer.Write", Before: `trace("write")`},
+	}}
+	rules, err := BuildRules(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg := NewRegistry()
+	for _, r := range rules {
+		reg.RegisterUser(r)
+	}
+	NewEngine(reg, ModeInject, resolveTarget).Process(file)
+	got := fileToString(t, file)
+
+	for _, want := range []string{
+		"trace(\"example.com/store.Store\")\n\ts.Get(\"a\")",
+		"trace(\"example.com/store.Cached\")\n\tc.Put(\"b\", \"v\")",
+		"trace(\"example.com/store.memStore\")\n\tm.Get(\"c\")",
+		"trace(\"write\")\n\tw.Write(nil)",
+		"trace(\"write\")\n\trw.Write(nil)",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("want %q in:\n%s", want, got)
+		}
+	}
+	for _, untouched := range []string{
+		"Put(\"b\", \"v\")\n\tc.Flush()",        // Flush is not a Store method
+		"m.Get(\"c\")\n\tm.Len()",               // nor Len
+		"m.Len()\n\tnotAStore{}.Get(\"d\")",     // notAStore lacks Put
+		"rw.Write(nil)\n\tos.Stdout.Write(nil)", // iface: skips concrete *os.File
+	} {
+		if !strings.Contains(got, untouched) {
+			t.Errorf("want %q untouched in:\n%s", untouched, got)
+		}
+	}
+	if n := strings.Count(got, "trace("); n != 5+1 { // + func trace declaration
+		t.Errorf("got %d trace( occurrences, want 6:\n%s", n, got)
+	}
+}
+
+// TestEngine_InterfaceMemoPerReceiverType — Box[int].Get and
+// Box[string].Get resolve to the same target, but only Box[int] implements
+// IntGetter; the memo must not carry one answer over to the other.
+func TestEngine_InterfaceMemoPerReceiverType(t *testing.T) {
+	src := `package store
+
+func trace() {}
+
+type IntGetter interface {
+	Get() int
+}
+
+type Box[T any] struct{ v T }
+
+func (b Box[T]) Get() T { return b.v }
+
+func use(s Box[string], i Box[int]) {
+	s.Get()
+	i.Get()
+}
+`
+	file := decorateTyped(t, src)
+	rules, err := BuildRules(&RulesConfig{Rules: []RuleSpec{
+		{Type: "hook", Target: "implements:example.com/store.IntGetter", Before: `trace()`},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg := NewRegistry()
+	for _, r := range rules {
+		reg.RegisterUser(r)
+	}
+	NewEngine(reg, ModeInject, resolveTarget).Process(file)
+	got := fileToString(t, file)
+	if want := "s.Get()\n\ttrace()\n\ti.Get()"; !strings.Contains(got, want) {
+		t.Errorf("want %q in:\n%s", want, got)
+	}
+	if n := strings.Count(got, "trace()"); n != 1+1 { // + func trace declaration
+		t.Errorf("got %d trace() occurrences, want 2:\n%s", n, got)
+	}
+}
+
+// TestRegistry_InterfaceNoTypeInfo never matches without go/types.
+func TestRegistry_InterfaceNoTypeInfo(t *testing.T) {
+	common.ClearTypeContext()
+	reg := NewRegistry()
+	reg.RegisterUser(&Rule{Target: "implements:example.com/store.Store", Advice: &Hook{Before: "x()"}})
+	call := &dst.CallExpr{Fun: &dst.SelectorExpr{X: dst.NewIdent("s"), Sel: dst.NewIdent("Get")}}
+	if got := reg.lookupInterface(call, nil); got != nil {
+		t.Errorf("lookupInterface without type info = %v", got)
+	}
+}
+
+func TestBuildRules_InterfaceTargetErrors(t *testing.T) {
+	for _, tc := range []struct {
+		spec    RuleSpec
+		wantErr string
+	}{
+		{RuleSpec{Type: "hook", Target: "iface:io.Writer", Before: "x()"}, "want pkg.Interface.Method"},
+		{RuleSpec{Type: "replace-with-ctx", Target: "iface:io.Writer.Write", With: "whataphttp.HttpGet"}, "does not accept an interface target"},
+		{RuleSpec{Type: "field-wrap", Target: "iface:io.Writer.Write", With: "whataphttp.NewRoundTrip"}, `type "field-wrap" does not accept an interface target`},
+		{RuleSpec{Type: "field-wrap-or-insert", Target: "implements:io.Writer", With: "whataphttp.NewRoundTrip"}, `type "field-wrap-or-insert" does not accept an interface target`},
+		{RuleSpec{Type: "field-assign-wrap", Target: "iface:io.Writer.Write", With: "whataphttp.NewRoundTrip"}, `type "field-assign-wrap" does not accept an interface target`},
+		{RuleSpec{Type: "inject", Target: "implements:io.Writer.Write", Before: "x()"}, `type "inject" does not accept an interface target`},
+		{RuleSpec{Type: "around", Target: "iface:io.Writer.Write", Start: "x()"}, `type "around" does not accept an interface target`},
+	} {
+		_, err := BuildRules(&RulesConfig{
+			ImportAliases: map[string]string{"whataphttp": "github.com/whatap/go-api/instrumentation/net/http/whataphttp"},
+			Rules:         []RuleSpec{tc.spec},
+		})
+		if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("%s: err = %v, want %q", tc.spec.Target, err, tc.wantErr)
+		}
+	}
+}
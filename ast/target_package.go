@@ -11,13 +11,20 @@ import "strings"
 //	"github.com/redis/go-redis/v9.NewClient"    → "github.com/redis/go-redis/v9"
 //	"net/http.Client{}"                         → "net/http"
 //	"decl:github.com/foo/bar.Baz"               → "github.com/foo/bar"
+//	"value:database/sql.Open"                   → "database/sql"
+//	"range:github.com/nats-io/nats.go.Msg"      → "github.com/nats-io/nats.go"
+//	"github.com/nats-io/nats.go.Subscription.NextMsg" → "github.com/nats-io/nats.go"
 //
-// The Go convention — package names are lowercase, exported symbols start
-// with an uppercase letter — lets us split at the first `.` inside the last
-// `/`-segment. Struct-literal markers (`{`) and the `decl:` prefix are
-// stripped first.
+// The package ends at the first `.` inside the last `/`-segment, except
+// that a dot belongs to the path element when what follows it is a
+// `go` / `vN` element suffix with a symbol after it (`nats.go`,
+// `yaml.v3`). Unexported types split like exported ones:
+// "decl:mycorp/svc.server.Handle" → "mycorp/svc". Struct-literal markers
+// (`{`) and the `decl:` / `value:` / `range:` prefixes are stripped first.
 func ExtractRulePackage(target string) string {
 	target = strings.TrimPrefix(target, "decl:")
+	target = strings.TrimPrefix(target, valuePrefix)
+	target = strings.TrimPrefix(target, rangePrefix)
 	if idx := strings.Index(target, "{"); idx >= 0 {
 		target = target[:idx]
 	}
@@ -35,9 +42,41 @@ func ExtractRulePackage(target string) string {
 	}
 
 	dotIdx := strings.Index(lastSegment, ".")
+	for dotIdx >= 0 {
+		rest := lastSegment[dotIdx+1:]
+		next := strings.Index(rest, ".")
+		if next < 0 || !isPathElementSuffix(rest[:next]) {
+			break
+		}
+		dotIdx += 1 + next
+	}
 	if dotIdx < 0 {
 		// No "." — malformed or bare package name. Return as-is.
 		return target
 	}
 	return prefix + lastSegment[:dotIdx]
 }
+
+// LiteralRulePackage is ExtractRulePackage for callers that need the package
+// a rule is about (package filters, the dependency report): "" for pattern
+// targets, whose text is a glob or regexp rather than an import path, and
+// the interface's package for iface: / implements: targets.
+func LiteralRulePackage(target string) string {
+	if isInterfaceTarget(target) {
+		t, err := parseInterfaceTarget(target)
+		if err != nil {
+			return ""
+		}
+		return t.pkgPath
+	}
+	if isPatternTarget(target) {
+		return ""
+	}
+	return ExtractRulePackage(target)
+}
+
+// isPathElementSuffix reports whether s, following a dot, continues a path
+// element rather than naming a symbol: "go" (nats.go) or "vN" (yaml.v3).
+func isPathElementSuffix(s string) bool {
+	return s == "go" || isVersionSuffix(s)
+}
@@ -0,0 +1,44 @@
+package ast
+
+import "testing"
+
+func TestExtractRulePackage(t *testing.T) {
+	cases := map[string]string{
+		"database/sql.Open":                                         "database/sql",
+		"github.com/gin-gonic/gin.Engine.Use":                       "github.com/gin-gonic/gin",
+		"net/http.Client{}":                                         "net/http",
+		"decl:github.com/foo/bar.Baz":                               "github.com/foo/bar",
+		"decl:myapp.helper":                                         "myapp",
+		"github.com/nats-io/nats.go.Subscription.NextMsg":           "github.com/nats-io/nats.go",
+		"github.com/nats-io/nats.go/jetstream.MessagesContext.Next": "github.com/nats-io/nats.go/jetstream",
+		"gopkg.in/yaml.v3.Marshal":                                  "gopkg.in/yaml.v3",
+		"decl:mycorp/svc.server.Handle":                             "mycorp/svc",
+		"decl:pkg.unexported.Method":                                "pkg",
+		"pkg.unexported.Method":                                     "pkg",
+		"github.com/nats-io/nats.go.subscription.next":              "github.com/nats-io/nats.go",
+	}
+	for target, want := range cases {
+		if got := ExtractRulePackage(target); got != want {
+			t.Errorf("ExtractRulePackage(%q) = %q, want %q", target, got, want)
+		}
+	}
+}
+
+func TestLiteralRulePackage(t *testing.T) {
+	cases := map[string]string{
+		"database/sql.Open":                        "database/sql",
+		"decl:mycorp/svc.server.Handle":            "mycorp/svc",
+		"iface:io.Writer.Write":                    "io",
+		"implements:mycorp/store.Store":            "mycorp/store",
+		"implements:github.com/nats-io/nats.go.Js": "github.com/nats-io/nats.go",
+		"mycorp/svc.*.Handle":                      "",
+		"mycorp/...":                               "",
+		`re:^mycorp/svc\.(Get|Put)$`:               "",
+		"iface:io":                                 "",
+	}
+	for target, want := range cases {
+		if got := LiteralRulePackage(target); got != want {
+			t.Errorf("LiteralRulePackage(%q) = %q, want %q", target, got, want)
+		}
+	}
+}
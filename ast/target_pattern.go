@@ -0,0 +1,102 @@
+package ast
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Call-site pattern targets. A call-site rule's target may be a glob or a
+// regular expression instead of one exact "pkg.Func" / "pkg.Type.Method":
+//
+//	github.com/foo/bar.Client.Get*         every Client method starting with Get
+//	mycorp.com/repo/....*Repository.*      every method of every *Repository type
+//	                                       in mycorp.com/repo and its subpackages
+//	re:^mycorp\.com/repo/.*\.(Get|List)\w*$
+//
+// Glob syntax, matched against the whole resolved target:
+//   - "*"             any run of characters within one path segment or name
+//     (does not cross "/" or ".")
+//   - "**" and "..."  any run of characters, "/" and "." included
+//   - "/**", "/..."   the package itself or any package below it (Go's ./...)
+//
+// "re:" targets are Go regular expressions, implicitly anchored at both ends.
+// decl: targets keep their own single-star wildcard (matchDeclWildcard).
+
+// Wildcards never match "{", "}" or "=", so a glob only reaches composite
+// literal ("pkg.T{}") and field-assignment ("pkg.T.F=") targets when it spells
+// those characters out.
+const (
+	segmentRun = `[^/.{}=]*` // "*"
+	anyRun     = `[^{}=]*`   // "**", "..."
+)
+
+// isPatternTarget reports whether target is a call-site glob or regex.
+func isPatternTarget(target string) bool {
+	if strings.HasPrefix(target, "decl:") {
+		return false
+	}
+	return strings.HasPrefix(target, "re:") || strings.Contains(target, "*") || strings.Contains(target, "...")
+}
+
+// targetPattern is a compiled call-site pattern target.
+type targetPattern struct {
+	re *regexp.Regexp
+	// prefix is a literal prefix of every target the pattern can match; the
+	// registry checks it before running the regexp.
+	prefix string
+}
+
+func (p *targetPattern) match(target string) bool {
+	return strings.HasPrefix(target, p.prefix) && p.re.MatchString(target)
+}
+
+// compileTargetPattern compiles a glob or "re:" target (see isPatternTarget).
+func compileTargetPattern(target string) (*targetPattern, error) {
+	if expr, ok := strings.CutPrefix(target, "re:"); ok {
+		re, err := regexp.Compile("^(?:" + expr + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("target %q: %w", target, err)
+		}
+		prefix, _ := re.LiteralPrefix()
+		return &targetPattern{re: re, prefix: prefix}, nil
+	}
+
+	var b strings.Builder
+	b.WriteString("^")
+	prefix := ""
+	literal := true // still inside the leading literal run
+	for i := 0; i < len(target); {
+		rest := target[i:]
+		var expr string
+		n := 1
+		switch {
+		case strings.HasPrefix(rest, "/..."):
+			expr, n = "(?:/"+anyRun+")?", 4
+		case strings.HasPrefix(rest, "/**"):
+			expr, n = "(?:/"+anyRun+")?", 3
+		case strings.HasPrefix(rest, "..."):
+			expr, n = anyRun, 3
+		case strings.HasPrefix(rest, "**"):
+			expr, n = anyRun, 2
+		case rest[0] == '*':
+			expr = segmentRun
+		default:
+			b.WriteString(regexp.QuoteMeta(rest[:1]))
+			if literal {
+				prefix += rest[:1]
+			}
+			i++
+			continue
+		}
+		literal = false
+		b.WriteString(expr)
+		i += n
+	}
+	b.WriteString("$")
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return nil, fmt.Errorf("target %q: %w", target, err)
+	}
+	return &targetPattern{re: re, prefix: prefix}, nil
+}
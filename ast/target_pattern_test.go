@@ -0,0 +1,191 @@
+package ast
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dave/dst"
+)
+
+func TestCompileTargetPattern(t *testing.T) {
+	cases := []struct {
+		pattern string
+		target  string
+		want    bool
+	}{
+		{"github.com/foo/bar.Client.Get*", "github.com/foo/bar.Client.Get", true},
+		{"github.com/foo/bar.Client.Get*", "github.com/foo/bar.Client.GetUser", true},
+		{"github.com/foo/bar.Client.Get*", "github.com/foo/bar.Client.Put", false},
+		{"github.com/foo/bar.Client.Get*", "github.com/foo/bar.Client.Get.X", false}, // * stays in one name
+		{"github.com/foo/bar.*.Get*", "github.com/foo/bar.Pool.GetConn", true},
+		{"mycorp.com/repo/....*Repository.*", "mycorp.com/repo.UserRepository.Find", true},
+		{"mycorp.com/repo/....*Repository.*", "mycorp.com/repo/internal/store.OrderRepository.Save", true},
+		{"mycorp.com/repo/....*Repository.*", "mycorp.com/repo/store.Service.Save", false},
+		{"mycorp.com/repo/....*Repository.*", "mycorp.com/repository.UserRepository.Find", false},
+		{"mycorp.com/repo/**.*Repository.*", "mycorp.com/repo/a/b.UserRepository.Find", true},
+		{"mycorp.com/*/store.*", "mycorp.com/repo/store.Open", true},
+		{"mycorp.com/*/store.*", "mycorp.com/repo/x/store.Open", false}, // * stays in one segment
+		{"mycorp.com/repo/....*Repository.*", "mycorp.com/repo.UserRepository.Name=", false},
+		{"mycorp.com/repo.*{}", "mycorp.com/repo.Config{}", true},
+		{`re:mycorp\.com/repo/.*\.(Get|List)\w*`, "mycorp.com/repo/store.Store.ListOrders", true},
+		{`re:mycorp\.com/repo/.*\.(Get|List)\w*`, "mycorp.com/repo/store.Store.Save", false},
+		{`re:mycorp\.com/repo\.Get`, "x/mycorp.com/repo.Get", false}, // anchored
+	}
+	for _, tc := range cases {
+		if !isPatternTarget(tc.pattern) {
+			t.Errorf("isPatternTarget(%q) = false", tc.pattern)
+			continue
+		}
+		p, err := compileTargetPattern(tc.pattern)
+		if err != nil {
+			t.Fatalf("compile %q: %v", tc.pattern, err)
+		}
+		if got := p.match(tc.target); got != tc.want {
+			t.Errorf("%q matching %q = %v, want %v", tc.pattern, tc.target, got, tc.want)
+		}
+	}
+
+	for _, target := range []string{"database/sql.Open", "net/http.Client{}", "decl:myapp.*"} {
+		if isPatternTarget(target) {
+			t.Errorf("isPatternTarget(%q) = true", target)
+		}
+	}
+	if p, _ := compileTargetPattern("mycorp.com/repo/....*Repository.*"); p.prefix != "mycorp.com/repo" {
+		t.Errorf("prefix = %q", p.prefix)
+	}
+}
+
+// TestRegistry_PatternLookup checks lookup precedence: exact targets beat
+// patterns, and the first registered matching pattern wins.
+func TestRegistry_PatternLookup(t *testing.T) {
+	reg := NewRegistry()
+	exact := &Rule{Target: "mycorp.com/repo.UserRepository.Find", Advice: &Hook{Before: "a()"}}
+	first := &Rule{Target: "mycorp.com/repo/....*Repository.*", Advice: &Hook{Before: "b()"}}
+	second := &Rule{Target: "mycorp.com/repo.**", Advice: &Hook{Before: "c()"}}
+	reg.RegisterUser(exact)
+	reg.RegisterUser(first)
+	reg.RegisterUser(second)
+
+	for target, want := range map[string]*Rule{
+		"mycorp.com/repo.UserRepository.Find": exact,
+		"mycorp.com/repo.UserRepository.Save": first,
+		"mycorp.com/repo.Open":                second,
+		"other.com/repo.Open":                 nil,
+	} {
+		for i := 0; i < 2; i++ { // second round is served from the memo
+			if got := reg.Lookup(target); got != want {
+				t.Errorf("Lookup(%q) round %d = %v, want %v", target, i, got, want)
+			}
+		}
+	}
+}
+
+// TestRegistry_PatternIndex checks that the first-segment index narrows the
+// patterns without changing which one wins: registration order decides
+// between a keyed pattern and one whose prefix has no "/".
+func TestRegistry_PatternIndex(t *testing.T) {
+	reg := NewRegistry()
+	anyGet := &Rule{Target: "**.Get", Advice: &Hook{Before: "a()"}}
+	repo := &Rule{Target: "mycorp.com/repo.**", Advice: &Hook{Before: "b()"}}
+	anyOpen := &Rule{Target: "re:.*\\.Open", Advice: &Hook{Before: "c()"}}
+	other := &Rule{Target: "other.com/x.*", Advice: &Hook{Before: "d()"}}
+	values := &Rule{Target: "value:mycorp.com/repo.**", Advice: &ValueWrap{}}
+	for _, r := range []*Rule{anyGet, repo, anyOpen, other, values} {
+		reg.RegisterUser(r)
+	}
+
+	idx := newPatternIndex(reg.callPatterns)
+	if got := idx.bySegment["mycorp.com"]; len(got) != 1 || got[0] != 1 {
+		t.Errorf(`bySegment["mycorp.com"] = %v, want [1]`, got)
+	}
+	if got := idx.unkeyed; len(got) != 2 || got[0] != 0 || got[1] != 2 {
+		t.Errorf("unkeyed = %v, want [0 2]", got)
+	}
+	if got := idx.candidates("mycorp.com/repo.Store.Open"); len(got) != 3 || got[0] != 0 || got[1] != 1 || got[2] != 2 {
+		t.Errorf("candidates = %v, want [0 1 2]", got)
+	}
+
+	for target, want := range map[string]*Rule{
+		"mycorp.com/repo.Store.Get":        anyGet, // registered before repo
+		"mycorp.com/repo.Store.Open":       repo,   // registered before anyOpen
+		"fmt.Open":                         anyOpen,
+		"other.com/x.Open":                 anyOpen,
+		"other.com/x.Close":                other,
+		"third.com/x.Close":                nil,
+		"value:mycorp.com/repo.Store.Save": values,
+	} {
+		if got := reg.Lookup(target); got != want {
+			t.Errorf("Lookup(%q) = %v, want %v", target, got, want)
+		}
+	}
+}
+
+// TestEngine_PatternHook applies one hook to every method of the
+// repository types through the engine.
+func TestEngine_PatternHook(t *testing.T) {
+	cfg := &RulesConfig{Rules: []RuleSpec{{
+		Type: "hook", Target: "mycorp.com/repo/....*Repository.*",
+		Before: `trace({{quote .FuncName}})`,
+	}}}
+	rules, err := BuildRules(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg := NewRegistry()
+	reg.RegisterUser(rules[0])
+	targets := map[string]string{
+		"users.Find":  "mycorp.com/repo/store.UserRepository.Find",
+		"orders.Save": "mycorp.com/repo/store.OrderRepository.Save",
+		"cache.Get":   "mycorp.com/repo/store.Cache.Get",
+	}
+	resolve := func(n dst.Node) string {
+		if call, ok := n.(*dst.CallExpr); ok {
+			if sel, ok := call.Fun.(*dst.SelectorExpr); ok {
+				if x, ok := sel.X.(*dst.Ident); ok {
+					return targets[x.Name+"."+sel.Sel.Name]
+				}
+			}
+		}
+		return ""
+	}
+	src := `package p
+
+func f() {
+	users.Find(1)
+	orders.Save(o)
+	cache.Get(k)
+}
+`
+	file := parseTestFile(t, src)
+	NewEngine(reg, ModeInject, resolve).Process(file)
+	got := fileToString(t, file)
+	for _, want := range []string{
+		"trace(\"Find\")\n\tusers.Find(1)",
+		"trace(\"Save\")\n\torders.Save(o)",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("want %q in:\n%s", want, got)
+		}
+	}
+	if strings.Count(got, "trace(") != 2 {
+		t.Errorf("cache.Get should not match:\n%s", got)
+	}
+}
+
+func TestBuildRules_PatternTargetErrors(t *testing.T) {
+	for _, tc := range []struct {
+		spec    RuleSpec
+		wantErr string
+	}{
+		{RuleSpec{Type: "hook", Target: "re:mycorp.com/(repo", Before: "x()"}, "missing closing )"},
+		{RuleSpec{Type: "replace-with-ctx", Target: "net/http.Get*", With: "whataphttp.HttpGet"}, "does not accept a pattern target"},
+	} {
+		_, err := BuildRules(&RulesConfig{
+			ImportAliases: map[string]string{"whataphttp": "github.com/whatap/go-api/instrumentation/net/http/whataphttp"},
+			Rules:         []RuleSpec{tc.spec},
+		})
+		if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("%s: err = %v, want %q", tc.spec.Target, err, tc.wantErr)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package ast
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dave/dst"
+)
+
+func TestSplitTargetTypeArgs(t *testing.T) {
+	cases := []struct {
+		target, want string
+		args         []TypeName
+	}{
+		{"mycorp/cache.Get", "mycorp/cache.Get", nil},
+		{"mycorp/cache.Get[T]", "mycorp/cache.Get", nil},
+		{"mycorp/cache.Get[mycorp/model.User]", "mycorp/cache.Get", []TypeName{{ImportPath: "mycorp/model", Name: "User"}}},
+		{"mycorp/repo.Repo[*mycorp/model.User].Find", "mycorp/repo.Repo.Find", []TypeName{{ImportPath: "mycorp/model", Name: "*User"}}},
+		{"mycorp/cache.Map[K, string]", "mycorp/cache.Map", []TypeName{{Name: "_"}, {Name: "string"}}},
+		{"gopkg.in/yaml.v3.Decode[gopkg.in/yaml.v3.Node]", "gopkg.in/yaml.v3.Decode", []TypeName{{ImportPath: "gopkg.in/yaml.v3", Name: "Node"}}},
+		{`re:mycorp/cache\.[GP]et`, `re:mycorp/cache\.[GP]et`, nil},
+	}
+	for _, tc := range cases {
+		got, args, err := splitTargetTypeArgs(tc.target)
+		if err != nil || got != tc.want || !reflect.DeepEqual(args, tc.args) {
+			t.Errorf("splitTargetTypeArgs(%q) = %q, %v, %v; want %q, %v", tc.target, got, args, err, tc.want, tc.args)
+		}
+	}
+	for _, target := range []string{"mycorp/cache.Get[User", "mycorp/cache.Get[Page[User]]", "mycorp/cache.Get[, User]"} {
+		if _, _, err := splitTargetTypeArgs(target); err == nil {
+			t.Errorf("splitTargetTypeArgs(%q) should fail", target)
+		}
+	}
+}
+
+// TestEngine_TypeArgFilters selects instantiations of a generic function and
+// of a generic type's method by their type arguments.
+func TestEngine_TypeArgFilters(t *testing.T) {
+	src := `package store
+
+func trace(name string) {}
+
+type User struct{}
+
+type Order struct{}
+
+func Get[T any](id string) (T, error) {
+	var z T
+	return z, nil
+}
+
+func Put[T any](v T) {}
+
+type Repo[T any] struct{}
+
+func (r *Repo[T]) Find(id string) T {
+	var z T
+	return z
+}
+
+type Page[T any] struct{ Items []T }
+
+func use(users *Repo[User], orders *Repo[*Order]) {
+	u, _ := Get[User]("1")
+	o, _ := Get[*Order]("2")
+	users.Find("a")
+	orders.Find("b")
+	Put(User{})
+	handlers := []func(){}
+	handlers[0]()
+	_, _ = Page[User]{}, Page[Order]{}
+	_, _ = u, o
+}
+`
+	file := decorateTyped(t, src)
+	cfg := &RulesConfig{Rules: []RuleSpec{
+		{Type: "hook", Target: "example.com/store.Get[example.com/store.User]", Before: `trace("get")`},
+		{Type: "hook", Target: "example.com/store.Repo.Find", Before: `trace("find")`,
+			Signature: &SignatureSpec{TypeArgs: []TypeNameSpec{{Package: "example.com/store", Name: "*Order"}}}},
+		{Type: "hook", Target: "example.com/store.Put[T]", Before: `trace("put")`},
+	}}
+	rules, err := BuildRules(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg := NewRegistry()
+	for _, r := range rules {
+		reg.RegisterUser(r)
+	}
+	NewEngine(reg, ModeInject, resolveTarget).Process(file)
+	got := fileToString(t, file)
+
+	for _, want := range []string{
+		"trace(\"get\")\n\tu, _ := Get[User](\"1\")\n\to, _ := Get[*Order](\"2\")\n\tusers.Find(\"a\")",
+		"trace(\"find\")\n\torders.Find(\"b\")",
+		"trace(\"put\")\n\tPut(User{})",
+		"handlers[0]()",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("want %q in:\n%s", want, got)
+		}
+	}
+	if n := strings.Count(got, "trace(\""); n != 3 {
+		t.Errorf("got %d hooks, want 3:\n%s", n, got)
+	}
+
+	// Composite literals of generic types filter the same way.
+	user := []TypeName{{ImportPath: "example.com/store", Name: "User"}}
+	var hits []bool
+	dst.Inspect(file, func(n dst.Node) bool {
+		if lit, ok := n.(*dst.CompositeLit); ok {
+			if _, generic := lit.Type.(*dst.IndexExpr); generic {
+				hits = append(hits, matchTypeArgs(&MatchContext{Lit: lit}, user))
+			}
+		}
+		return true
+	})
+	if !reflect.DeepEqual(hits, []bool{true, false}) {
+		t.Errorf("Page literal matches = %v, want [true false]", hits)
+	}
+}
+
+func TestBuildRules_TypeArgErrors(t *testing.T) {
+	for _, tc := range []struct {
+		spec    RuleSpec
+		wantErr string
+	}{
+		{RuleSpec{Type: "hook", Target: "mycorp/cache.Get[mycorp/model.User]", Before: "x()",
+			Signature: &SignatureSpec{TypeArgs: []TypeNameSpec{{Name: "string"}}}}, "both in the target and in signature.typeArgs"},
+		{RuleSpec{Type: "inject", Target: "decl:mycorp/cache.Get[mycorp/model.User]", Start: "x()"}, `"decl:" targets do not take them`},
+		{RuleSpec{Type: "hook", Target: "mycorp/cache.Get[User", Before: "x()"}, "unclosed"},
+	} {
+		_, err := BuildRules(&RulesConfig{Rules: []RuleSpec{tc.spec}})
+		if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("%s: err = %v, want %q", tc.spec.Target, err, tc.wantErr)
+		}
+	}
+}
@@ -0,0 +1,332 @@
+package ast
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/dave/dst"
+	"github.com/whatap/go-api-inst/ast/common"
+)
+
+// templateFuncs returns the function library available to transform, hook and
+// inject templates (see docs/custom-instrumentation.md §7.2):
+//
+//	quote S       Go string literal of S          {{quote .Arg0}} → "req.URL"
+//	lower S       strings.ToLower                {{lower .FuncName}}
+//	join SEP L    strings.Join(L, SEP)           {{join ", " .ArgsList}}
+//	default D V   V, or D when V is empty        {{.Var | default "res"}}
+//	argType N     go/types type of argument N    {{argType 0}} → "context.Context"
+//	recvType      go/types type of the receiver  {{recvType}} → "*github.com/x/y.Client"
+//	pos           file:line of the match         {{pos}} → "order.go:42"
+//	pkgPath       import path of the matched target's package
+//	uniqueVar B   identifier not yet used in the file, derived from B
+//
+// One FuncMap is built per Apply so uniqueVar returns the same name for the
+// same base across a rule's templates (Hook before/after, Inject start/end).
+// ctx may be nil: load-time validation only needs the names, and every
+// ctx-dependent helper then returns "" (uniqueVar returns B).
+func templateFuncs(ctx *MatchContext) template.FuncMap {
+	var taken map[string]bool
+	chosen := map[string]string{}
+	return template.FuncMap{
+		"quote": strconv.Quote,
+		"lower": strings.ToLower,
+		"join": func(sep string, list []string) string {
+			return strings.Join(list, sep)
+		},
+		"default": func(def, v string) string {
+			if v == "" {
+				return def
+			}
+			return v
+		},
+		"argType":  func(i int) string { return templateArgType(ctx, i) },
+		"recvType": func() string { return templateRecvType(ctx) },
+		"pos":      func() string { return templatePos(ctx) },
+		"pkgPath": func() string {
+			if ctx == nil {
+				return ""
+			}
+			return ExtractRulePackage(ctx.Target)
+		},
+		"uniqueVar": func(base string) string {
+			if name, ok := chosen[base]; ok {
+				return name
+			}
+			if ctx == nil || ctx.File == nil {
+				return base
+			}
+			if taken == nil {
+				taken = identNames(ctx.File)
+			}
+			name := base
+			for i := 1; taken[name]; i++ {
+				name = base + strconv.Itoa(i)
+			}
+			taken[name] = true
+			chosen[base] = name
+			return name
+		},
+	}
+}
+
+// templateArgType returns the type of the i-th argument: go/types for call
+// sites (empty without type info), the declared parameter type for inject.
+func templateArgType(ctx *MatchContext, i int) string {
+	if ctx == nil || i < 0 {
+		return ""
+	}
+	if ctx.Call != nil {
+		if i >= len(ctx.Call.Args) {
+			return ""
+		}
+		if t := common.ResolveType(ctx.Call.Args[i]); t != nil {
+			return t.String()
+		}
+		return ""
+	}
+	if ctx.Decl != nil && ctx.Decl.Type.Params != nil {
+		n := 0
+		for _, p := range ctx.Decl.Type.Params.List {
+			names := len(p.Names)
+			if names == 0 {
+				names = 1
+			}
+			if i < n+names {
+				return nodeToString(p.Type)
+			}
+			n += names
+		}
+	}
+	return ""
+}
+
+// templateRecvType returns the method receiver's type, or "" for package
+// functions and when it cannot be determined.
+func templateRecvType(ctx *MatchContext) string {
+	if ctx == nil {
+		return ""
+	}
+	if ctx.Call != nil && ctx.Sel != nil {
+		if id, ok := ctx.Sel.X.(*dst.Ident); ok && common.GetIdentPath(id) != "" {
+			return "" // pkg.Func — no receiver
+		}
+		if t := common.ResolveType(ctx.Sel.X); t != nil {
+			return t.String()
+		}
+		return ""
+	}
+	if ctx.Decl != nil && ctx.Decl.Recv != nil && len(ctx.Decl.Recv.List) > 0 {
+		return nodeToString(ctx.Decl.Recv.List[0].Type)
+	}
+	return ""
+}
+
+// templatePos returns "file.go:line" of the matched node. The base name keeps
+// generated code independent of the (temporary) build directory.
+func templatePos(ctx *MatchContext) string {
+	if ctx == nil {
+		return ""
+	}
+	pos, ok := common.NodePosition(ctx.matchedNode())
+	if !ok {
+		return ""
+	}
+	return filepath.Base(pos.Filename) + ":" + strconv.Itoa(pos.Line)
+}
+
+// validateTemplate parses code with the template function library and checks
+// that every field referenced on the root context ({{.X}}, {{$.X}}) exists on
+// TransformContext. It runs at rule-load time so that a typo surfaces as a
+// rules[i] error instead of a rule error at every match.
+func validateTemplate(name, code string) error {
+	if strings.TrimSpace(code) == "" {
+		return nil
+	}
+	tmpl, err := template.New(name).Funcs(templateFuncs(nil)).Parse(code)
+	if err != nil {
+		return err
+	}
+	if tmpl.Tree == nil {
+		return nil
+	}
+	return checkTemplateFields(name, tmpl.Tree.Root, true)
+}
+
+// dryRunTemplate executes a validated template against dryRunContext and
+// parses the expansion as Go statements. A failure is kept on the rule
+// (Rule.DryRunErr) rather than failing the load: the synthetic match may
+// simply not be a call shape the rule is meant for.
+func dryRunTemplate(name, code string) error {
+	if strings.TrimSpace(code) == "" {
+		return nil
+	}
+	tmpl, err := template.New(name).Funcs(dryRunFuncs()).Parse(code)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, dryRunContext); err != nil {
+		return fmt.Errorf("dry run: %w", err)
+	}
+	if _, err := parseCodeBlock(buf.String()); err != nil {
+		return fmt.Errorf("dry run: %s: expanded code does not parse: %w (expanded: %q)", name, err, buf.String())
+	}
+	return nil
+}
+
+// dryRunContext is the synthetic match dryRunTemplate expands templates
+// against: a four-argument method call `v, err := recv.Method(ctx, a1, a2, a3)`
+// inside a handler with a context.
+var dryRunContext = TransformContext{
+	Original:  "recv.Method(ctx, a1, a2, a3)",
+	Var:       "v",
+	Var1:      "err",
+	Args:      "ctx, a1, a2, a3",
+	Arg0:      "ctx",
+	Arg1:      "a1",
+	Arg2:      "a2",
+	Arg3:      "a3",
+	FuncName:  "Method",
+	PkgName:   "pkg",
+	Receiver:  "recv",
+	Ctx:       "ctx",
+	TargetPkg: "pkg",
+	ArgsList:  []string{"ctx", "a1", "a2", "a3"},
+	ArgCount:  4,
+	HasCtx:    true,
+	File:      "p",
+	Args1Plus: "a1, a2, a3",
+
+	Results:     "v, err",
+	ResultsList: []string{"v", "err"},
+	Err:         "err",
+	Panic:       "whatapPanic",
+}
+
+// dryRunFuncs is templateFuncs with the type/position helpers returning
+// placeholders, so `x.({{argType 0}})` still expands to valid Go.
+func dryRunFuncs() template.FuncMap {
+	funcs := templateFuncs(nil)
+	funcs["argType"] = func(int) string { return "T" }
+	funcs["recvType"] = func() string { return "*pkg.T" }
+	funcs["pos"] = func() string { return "p.go:1" }
+	funcs["pkgPath"] = func() string { return "example.com/pkg" }
+	return funcs
+}
+
+// checkTemplateFields walks a parsed template and rejects root-context
+// fields TransformContext does not have.
+func checkTemplateFields(name string, node parse.Node, dotIsRoot bool) error {
+	return walkTemplateFields(node, dotIsRoot, func(field string) error {
+		if !transformContextHas(field) {
+			return fmt.Errorf("template: %s: unknown field {{.%s}} (see TransformContext)", name, field)
+		}
+		return nil
+	})
+}
+
+// templateUsesField reports whether code references the root-context field
+// ({{.Panic}}, {{$.Panic}}, also inside if/range/with pipelines). Text that
+// merely contains the name — a comment, a string, .PanicCount on some other
+// value — does not count. false when code does not parse.
+func templateUsesField(code, field string) bool {
+	tmpl, err := template.New("").Funcs(templateFuncs(nil)).Parse(code)
+	if err != nil || tmpl.Tree == nil {
+		return false
+	}
+	found := false
+	walkTemplateFields(tmpl.Tree.Root, true, func(f string) error {
+		found = found || f == field
+		return nil
+	})
+	return found
+}
+
+// walkTemplateFields calls visit for every root-context field a parsed
+// template references, stopping at the first error. Dot is the
+// TransformContext only outside range/with bodies, so bare .X fields are
+// reported there and $.X everywhere.
+func walkTemplateFields(node parse.Node, dotIsRoot bool, visit func(field string) error) error {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, c := range n.Nodes {
+			if err := walkTemplateFields(c, dotIsRoot, visit); err != nil {
+				return err
+			}
+		}
+	case *parse.ActionNode:
+		return walkTemplatePipe(n.Pipe, dotIsRoot, visit)
+	case *parse.IfNode:
+		return walkTemplateBranch(&n.BranchNode, dotIsRoot, dotIsRoot, visit)
+	case *parse.RangeNode:
+		return walkTemplateBranch(&n.BranchNode, dotIsRoot, false, visit)
+	case *parse.WithNode:
+		return walkTemplateBranch(&n.BranchNode, dotIsRoot, false, visit)
+	}
+	return nil
+}
+
+func walkTemplateBranch(b *parse.BranchNode, dotIsRoot, bodyDotIsRoot bool, visit func(string) error) error {
+	if err := walkTemplatePipe(b.Pipe, dotIsRoot, visit); err != nil {
+		return err
+	}
+	if err := walkTemplateFields(b.List, bodyDotIsRoot, visit); err != nil {
+		return err
+	}
+	if b.ElseList != nil {
+		return walkTemplateFields(b.ElseList, dotIsRoot, visit)
+	}
+	return nil
+}
+
+func walkTemplatePipe(pipe *parse.PipeNode, dotIsRoot bool, visit func(string) error) error {
+	if pipe == nil {
+		return nil
+	}
+	for _, cmd := range pipe.Cmds {
+		for _, arg := range cmd.Args {
+			var field string
+			switch a := arg.(type) {
+			case *parse.FieldNode:
+				if dotIsRoot {
+					field = a.Ident[0]
+				}
+			case *parse.VariableNode:
+				if a.Ident[0] == "$" && len(a.Ident) > 1 {
+					field = a.Ident[1]
+				}
+			case *parse.PipeNode:
+				if err := walkTemplatePipe(a, dotIsRoot, visit); err != nil {
+					return err
+				}
+			}
+			if field != "" {
+				if err := visit(field); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// transformContextHas reports whether TransformContext has a field or method
+// named field.
+func transformContextHas(field string) bool {
+	t := reflect.TypeOf(TransformContext{})
+	if _, ok := t.FieldByName(field); ok {
+		return true
+	}
+	_, ok := t.MethodByName(field)
+	return ok
+}
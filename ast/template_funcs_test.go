@@ -0,0 +1,120 @@
+package ast
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateTemplate(t *testing.T) {
+	cases := []struct {
+		code    string
+		wantErr string // "" = valid
+	}{
+		{code: `whatapx.Wrap({{.Arg0}}, {{quote .Original}}, {{.ArgAt 2}})`},
+		{code: `{{$v := uniqueVar "tx"}}{{$v}} := start({{quote (lower .FuncName)}}, {{join ", " .ArgsList}})`},
+		{code: `{{.Var | default "res"}} = x.({{argType 0}}); _ = {{quote recvType}} + {{quote pos}} + {{quote pkgPath}}`},
+		{code: `{{range .ArgsList}}log({{.}}, {{quote $.FuncName}}); {{end}}`},
+		{code: `{{if .HasCtx}}{{.Ctx}}{{else}}context.TODO(){{end}}`},
+		{code: `{{.Arg9}}`, wantErr: "unknown field {{.Arg9}}"},
+		{code: `{{range .ArgsList}}{{$.Nope}}{{end}}`, wantErr: "unknown field {{.Nope}}"},
+		{code: `{{upper .FuncName}}`, wantErr: `function "upper" not defined`},
+		{code: `{{.FuncName`, wantErr: "unclosed action"},
+		// left to the dry run
+		{code: `{{index .ArgsList 5}}`},
+		{code: `wrap({{.Arg0}}`},
+	}
+	for _, tc := range cases {
+		err := validateTemplate("template", tc.code)
+		switch {
+		case tc.wantErr == "" && err != nil:
+			t.Errorf("%q: unexpected error %v", tc.code, err)
+		case tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)):
+			t.Errorf("%q: err = %v, want %q", tc.code, err, tc.wantErr)
+		}
+	}
+}
+
+func TestDryRunTemplate(t *testing.T) {
+	cases := []struct {
+		code    string
+		wantErr string // "" = valid
+	}{
+		{code: `whatapx.Wrap({{.Arg0}}, {{quote .Original}}, {{.ArgAt 2}})`},
+		{code: `{{.Var | default "res"}} = x.({{argType 0}}); _ = {{quote recvType}} + {{quote pos}} + {{quote pkgPath}}`},
+		{code: `{{index .ArgsList 5}}`, wantErr: "dry run: template: template:1:2: executing"},
+		{code: `wrap({{.Arg0}}`, wantErr: "dry run: template: expanded code does not parse"},
+		{code: `{{if gt .ArgCount 5}}{{index .ArgsList 5}}{{end}}`},
+	}
+	for _, tc := range cases {
+		err := dryRunTemplate("template", tc.code)
+		switch {
+		case tc.wantErr == "" && err != nil:
+			t.Errorf("%q: unexpected error %v", tc.code, err)
+		case tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)):
+			t.Errorf("%q: err = %v, want %q", tc.code, err, tc.wantErr)
+		}
+	}
+}
+
+// TestBuiltinTemplatesValidate keeps the built-in Transform templates within
+// what load-time validation accepts.
+func TestBuiltinTemplatesValidate(t *testing.T) {
+	for _, r := range AllRules() {
+		if tr, ok := r.Advice.(*Transform); ok {
+			if err := validateTemplate("template", tr.Template); err != nil {
+				t.Errorf("%s: %v", r.Target, err)
+			}
+			if err := dryRunTemplate("template", tr.Template); err != nil {
+				t.Errorf("%s: %v", r.Target, err)
+			}
+		}
+	}
+}
+
+func TestBuildRules_RejectsBadTemplate(t *testing.T) {
+	cfg := &RulesConfig{Rules: []RuleSpec{{
+		Type: "hook", Target: "mypkg.Do", Before: `trace({{.Funcname}})`,
+	}}}
+	_, err := BuildRules(cfg)
+	if err == nil || !strings.Contains(err.Error(), "unknown field {{.Funcname}}") {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+// TestHookTemplateFuncs checks the helpers at apply time: quote/join/default,
+// uniqueVar avoiding names already in the file and staying stable across
+// Before/After, and argType/pos degrading to "" without type info.
+func TestHookTemplateFuncs(t *testing.T) {
+	src := `package p
+
+func f() {
+	span := 1
+	_ = span
+	send(ctx, msg)
+}
+`
+	file := parseTestFile(t, src)
+	fn := findFuncDecl(file, "f")
+	ctx := &MatchContext{
+		File: file, Mode: ModeInject, Call: findFirstCall(file), FuncName: "send",
+		Target: "example.com/mq.send", EnclosingFunc: fn, EnclosingStmt: fn.Body.List[2],
+		ParentBlock: &fn.Body.List, StmtIndex: 2,
+	}
+	adv := &Hook{
+		Before: `{{uniqueVar "span"}} := begin({{quote .FuncName}}, {{quote (join "|" .ArgsList)}}, {{quote (argType 0)}}, {{quote pos}}, {{quote pkgPath}})`,
+		After:  `end({{uniqueVar "span"}}, {{.Var | default "nil"}})`,
+	}
+	adv.Apply(ctx)
+	if !ctx.Applied {
+		t.Fatal("Hook should have applied")
+	}
+	got := fileToString(t, file)
+	for _, want := range []string{
+		`span1 := begin("send", "ctx|msg", "", "", "example.com/mq")`,
+		`end(span1, nil)`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("want %q in:\n%s", want, got)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package ast
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/whatap/go-api-inst/ast/common"
+
+	"github.com/dave/dst"
+)
+
+// Test mode (config instrumentation.test_mode) — `go test` binaries.
+//
+// The test binary's main() is not the user's: `go test` generates
+// _testmain.go, whose main() builds a testing.M, runs TestMain or m.Run()
+// and ends with os.Exit. Init goes at the top of that main(); Shutdown
+// cannot be deferred (os.Exit skips defers), so every os.Exit(code) becomes
+// os.Exit(func(code int) int { Shutdown(); return code }(code)) — the code
+// is still computed first. A user TestMain that calls os.Exit itself gets
+// the same rewrite, since the generated main never regains control.
+
+// testMainFile is the main file `go test` generates for a test binary. The
+// go command ignores user files starting with "_", so the name cannot
+// belong to user source.
+const testMainFile = "_testmain.go"
+
+// isGeneratedTestMain reports whether path is the generated test main.
+func isGeneratedTestMain(path string) bool {
+	return filepath.Base(path) == testMainFile
+}
+
+// isTestFile reports whether path is a _test.go file.
+func isTestFile(path string) bool {
+	return strings.HasSuffix(path, "_test.go")
+}
+
+// injectTestMain adds Init to the generated main() and Shutdown before its
+// os.Exit calls. Returns the report changes (nil = no main found).
+func (inj *Injector) injectTestMain(file *dst.File, traceAlias string) []string {
+	fn := common.FindNonEmptyMainFunc(file)
+	if fn == nil {
+		return nil
+	}
+	common.AddImportWithAlias(file, "github.com/whatap/go-api/trace", traceAlias)
+	initStmt := &dst.ExprStmt{
+		X: &dst.CallExpr{
+			Fun:  &dst.SelectorExpr{X: dst.NewIdent(traceAlias), Sel: dst.NewIdent("Init")},
+			Args: []dst.Expr{dst.NewIdent("nil")},
+		},
+	}
+	initStmt.Decs.After = dst.NewLine
+	fn.Body.List = append([]dst.Stmt{initStmt}, fn.Body.List...)
+	changes := []string{"added: " + traceAlias + ".Init(nil) (test main)"}
+	if n := shutdownBeforeExit(fn.Body, common.GetPackageNameForImport(file, "os"), traceAlias); n > 0 {
+		changes = append(changes, "added: "+traceAlias+".Shutdown() before os.Exit (test main)")
+	}
+	return changes
+}
+
+// instrumentTestFile applies test mode to a _test.go file: Shutdown before
+// os.Exit in TestMain and, with TestTransactions, a transaction around each
+// TestXxx function and t.Run subtest. Returns the report changes.
+func (inj *Injector) instrumentTestFile(file *dst.File, traceAlias string) []string {
+	testingName := common.GetPackageNameForImport(file, "testing")
+	if testingName == "" {
+		return nil
+	}
+	osName := common.GetPackageNameForImport(file, "os")
+
+	var changes []string
+	exits, tests, subtests := 0, 0, 0
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*dst.FuncDecl)
+		if !ok || fn.Recv != nil || fn.Body == nil {
+			continue
+		}
+		if fn.Name.Name == "TestMain" {
+			if _, ok := testingParam(fn.Type, testingName, "M"); ok {
+				exits += shutdownBeforeExit(fn.Body, osName, traceAlias)
+			}
+			continue
+		}
+		if !inj.TestTransactions {
+			continue
+		}
+		if t, ok := testingParam(fn.Type, testingName, "T"); ok && isTestName(fn.Name.Name) && t != "_" {
+			if inj.wrapTestBody(file, fn.Body, t, traceAlias) {
+				tests++
+			}
+		}
+		dst.Inspect(fn.Body, func(n dst.Node) bool {
+			call, ok := n.(*dst.CallExpr)
+			if !ok || len(call.Args) != 2 {
+				return true
+			}
+			sel, ok := call.Fun.(*dst.SelectorExpr)
+			if !ok || sel.Sel.Name != "Run" {
+				return true
+			}
+			lit, ok := call.Args[1].(*dst.FuncLit)
+			if !ok {
+				return true
+			}
+			if t, ok := testingParam(lit.Type, testingName, "T"); ok && t != "_" {
+				if inj.wrapTestBody(file, lit.Body, t, traceAlias) {
+					subtests++
+				}
+			}
+			return true
+		})
+	}
+	if exits+tests+subtests == 0 {
+		return nil
+	}
+	common.AddImportWithAlias(file, "github.com/whatap/go-api/trace", traceAlias)
+	if exits > 0 {
+		changes = append(changes, "added: "+traceAlias+".Shutdown() before os.Exit (TestMain)")
+	}
+	if tests > 0 {
+		changes = append(changes, fmt.Sprintf("added: %s.Start/End around %d test(s)", traceAlias, tests))
+	}
+	if subtests > 0 {
+		changes = append(changes, fmt.Sprintf("added: %s.Start/End around %d subtest(s)", traceAlias, subtests))
+	}
+	return changes
+}
+
+// wrapTestBody starts a transaction named t.Name() at the top of a test
+// body and ends it on return, with an error when the test failed. Bodies
+// already wrapped (nested t.Run visited twice) are left alone.
+func (inj *Injector) wrapTestBody(file *dst.File, body *dst.BlockStmt, t, traceAlias string) bool {
+	if body == nil || len(body.List) == 0 || isTestTxStart(body.List[0]) {
+		return false
+	}
+	// Names as the file imports them; context / fmt are imported below,
+	// once the code is known to parse.
+	ctxName := common.GetContextPackageName(file)
+	if ctxName == "" {
+		ctxName = "context"
+	}
+	fmtName := common.GetPackageNameForImport(file, "fmt")
+	if fmtName == "" {
+		fmtName = "fmt"
+	}
+	code := "whatapTestCtx, _ := " + traceAlias + ".Start(" + ctxName + ".Background(), " + t + ".Name())\n" +
+		"defer func() {\n" +
+		"\tvar whatapTestErr error\n" +
+		"\tif " + t + ".Failed() {\n" +
+		"\t\twhatapTestErr = " + fmtName + ".Errorf(\"%s failed\", " + t + ".Name())\n" +
+		"\t}\n" +
+		"\t" + traceAlias + ".End(whatapTestCtx, whatapTestErr)\n" +
+		"}()"
+	stmts, err := parseCodeBlock(code)
+	if err != nil {
+		return false
+	}
+	common.AddImport(file, "context")
+	common.AddImport(file, "fmt")
+	stmts[len(stmts)-1].Decorations().After = dst.EmptyLine
+	body.List = append(stmts, body.List...)
+	return true
+}
+
+// isTestTxStart reports whether stmt is the `whatapTestCtx, _ := …` line
+// wrapTestBody inserts.
+func isTestTxStart(stmt dst.Stmt) bool {
+	assign, ok := stmt.(*dst.AssignStmt)
+	if !ok || len(assign.Lhs) == 0 {
+		return false
+	}
+	id, ok := assign.Lhs[0].(*dst.Ident)
+	return ok && id.Name == "whatapTestCtx"
+}
+
+// shutdownBeforeExit rewrites os.Exit(code) calls under node to run
+// Shutdown after code is evaluated and before the process exits. Returns
+// the number of calls rewritten.
+func shutdownBeforeExit(node dst.Node, osName, traceAlias string) int {
+	if osName == "" {
+		return 0
+	}
+	n := 0
+	dst.Inspect(node, func(nd dst.Node) bool {
+		call, ok := nd.(*dst.CallExpr)
+		if !ok || len(call.Args) != 1 {
+			return true
+		}
+		sel, ok := call.Fun.(*dst.SelectorExpr)
+		if !ok || sel.Sel.Name != "Exit" {
+			return true
+		}
+		if x, ok := sel.X.(*dst.Ident); !ok || x.Name != osName {
+			return true
+		}
+		stmts, err := parseCodeBlock("_ = func(code int) int {\n\t" + traceAlias + ".Shutdown()\n\treturn code\n}(0)")
+		if err != nil {
+			return true
+		}
+		wrap := stmts[0].(*dst.AssignStmt).Rhs[0].(*dst.CallExpr)
+		wrap.Args[0] = call.Args[0]
+		call.Args[0] = wrap
+		n++
+		return false
+	})
+	return n
+}
+
+// testingParam returns the name of the single *testing.<typ> parameter of
+// a test function (TestXxx(t *testing.T), TestMain(m *testing.M),
+// func(t *testing.T) passed to t.Run).
+func testingParam(ft *dst.FuncType, testingName, typ string) (string, bool) {
+	if ft == nil || ft.Params == nil || len(ft.Params.List) != 1 || len(ft.Params.List[0].Names) > 1 {
+		return "", false
+	}
+	field := ft.Params.List[0]
+	star, ok := field.Type.(*dst.StarExpr)
+	if !ok {
+		return "", false
+	}
+	sel, ok := star.X.(*dst.SelectorExpr)
+	if !ok || sel.Sel.Name != typ {
+		return "", false
+	}
+	if x, ok := sel.X.(*dst.Ident); !ok || x.Name != testingName {
+		return "", false
+	}
+	if len(field.Names) == 0 {
+		return "_", true
+	}
+	return field.Names[0].Name, true
+}
+
+// isTestName follows `go test`: "Test" followed by nothing or by a
+// character that is not a lower-case letter (TestFoo, Test_foo, not Testfoo).
+func isTestName(name string) bool {
+	if !strings.HasPrefix(name, "Test") {
+		return false
+	}
+	if len(name) == len("Test") {
+		return true
+	}
+	r, _ := utf8.DecodeRuneInString(name[len("Test"):])
+	return !unicode.IsLower(r)
+}
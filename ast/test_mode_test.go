@@ -0,0 +1,208 @@
+package ast
+
+import (
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// generatedTestMain is the shape of the main() `go test` writes into
+// _testmain.go when the package has a TestMain.
+const generatedTestMain = `package main
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"testing/internal/testdeps"
+
+	_test "mycorp.com/app/store"
+)
+
+var tests = []testing.InternalTest{
+	{"TestGet", _test.TestGet},
+}
+
+func main() {
+	m := testing.MainStart(testdeps.TestDeps{}, tests, nil, nil, nil)
+	_test.TestMain(m)
+	os.Exit(int(reflect.ValueOf(m).Elem().FieldByName("exitCode").Int()))
+}
+`
+
+func TestInjectTestMain(t *testing.T) {
+	file := parseTestFile(t, generatedTestMain)
+	inj := &Injector{TestMode: true}
+	if changes := inj.injectTestMain(file, "whataptrace"); len(changes) != 2 {
+		t.Errorf("changes = %q", changes)
+	}
+	got := fileToString(t, file)
+	for _, want := range []string{
+		`whataptrace "github.com/whatap/go-api/trace"`,
+		"func main() {\n\twhataptrace.Init(nil)\n\tm := testing.MainStart(",
+		"os.Exit(func(code int) int {\n\t\twhataptrace.Shutdown()\n\t\treturn code\n\t}(int(reflect.ValueOf(m)",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "defer whataptrace.Shutdown()") {
+		t.Errorf("os.Exit skips defers — Shutdown must not be deferred:\n%s", got)
+	}
+}
+
+// TestInjectFile_GeneratedTestMain leaves the test main alone unless test
+// mode is on.
+func TestInjectFile_GeneratedTestMain(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "_testmain.go")
+	if err := os.WriteFile(src, []byte(generatedTestMain), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, testMode := range []bool{false, true} {
+		inj := NewInjector()
+		inj.TestMode = testMode
+		out := filepath.Join(dir, "out.go")
+		if err := inj.InjectFile(src, out); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Contains(string(data), "whataptrace.Init(nil)"); got != testMode {
+			t.Errorf("test_mode %v: Init injected = %v\n%s", testMode, got, data)
+		}
+	}
+}
+
+func TestInstrumentTestFile(t *testing.T) {
+	src := `package store
+
+import (
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	setup()
+	os.Exit(m.Run())
+}
+
+func TestGet(t *testing.T) {
+	get(t)
+	t.Run("missing", func(t *testing.T) {
+		get(t)
+	})
+}
+
+func TestPut(tt *testing.T) {
+	tt.Run("nested", func(t *testing.T) {
+		t.Run("deeper", func(t *testing.T) { get(t) })
+	})
+}
+
+func Testhelper(t *testing.T) { get(t) }
+
+func BenchmarkGet(b *testing.B) { b.Run("x", func(b *testing.B) {}) }
+
+func TestSkip(_ *testing.T) {}
+`
+	file := parseTestFile(t, src)
+	inj := &Injector{TestMode: true, TestTransactions: true}
+	changes := inj.instrumentTestFile(file, "whataptrace")
+	want := []string{
+		"added: whataptrace.Shutdown() before os.Exit (TestMain)",
+		"added: whataptrace.Start/End around 2 test(s)",
+		"added: whataptrace.Start/End around 3 subtest(s)",
+	}
+	if strings.Join(changes, "\n") != strings.Join(want, "\n") {
+		t.Errorf("changes = %q, want %q", changes, want)
+	}
+	got := fileToString(t, file)
+	for _, w := range []string{
+		"os.Exit(func(code int) int {",
+		"whatapTestCtx, _ := whataptrace.Start(context.Background(), tt.Name())",
+		"if tt.Failed() {",
+		`whatapTestErr = fmt.Errorf("%s failed", t.Name())`,
+		`"context"`,
+		`"fmt"`,
+	} {
+		if !strings.Contains(got, w) {
+			t.Errorf("missing %q:\n%s", w, got)
+		}
+	}
+	if n := strings.Count(got, "whataptrace.Start("); n != 5 {
+		t.Errorf("Start count = %d, want 5 (TestGet, TestPut, 3 subtests):\n%s", n, got)
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), "store_test.go", got, 0); err != nil {
+		t.Errorf("output does not parse: %v\n%s", err, got)
+	}
+
+	// Without transactions only TestMain is touched.
+	file = parseTestFile(t, src)
+	inj.TestTransactions = false
+	if changes := inj.instrumentTestFile(file, "whataptrace"); len(changes) != 1 {
+		t.Errorf("changes without transactions = %q", changes)
+	}
+	if got := fileToString(t, file); strings.Contains(got, "whataptrace.Start(") {
+		t.Errorf("transactions off but Start injected:\n%s", got)
+	}
+}
+
+// TestWrapTestBody_Imports uses the file's own context alias and adds
+// context / fmt only for code that was actually inserted.
+func TestWrapTestBody_Imports(t *testing.T) {
+	src := `package store
+
+import (
+	stdctx "context"
+	"testing"
+)
+
+var _ = stdctx.TODO
+
+func TestGet(t *testing.T) {
+	get(t)
+}
+`
+	file := parseTestFile(t, src)
+	fn := findFuncDecl(file, "TestGet")
+	inj := &Injector{TestMode: true, TestTransactions: true}
+	if inj.wrapTestBody(file, fn.Body, "t", "bad alias") {
+		t.Fatal("code with an invalid alias should not parse")
+	}
+	if got := fileToString(t, file); strings.Contains(got, `"fmt"`) {
+		t.Errorf("fmt imported for code that was not inserted:\n%s", got)
+	}
+
+	if !inj.wrapTestBody(file, fn.Body, "t", "whataptrace") {
+		t.Fatal("not wrapped")
+	}
+	got := fileToString(t, file)
+	for _, w := range []string{
+		"whatapTestCtx, _ := whataptrace.Start(stdctx.Background(), t.Name())",
+		`"fmt"`,
+	} {
+		if !strings.Contains(got, w) {
+			t.Errorf("missing %q:\n%s", w, got)
+		}
+	}
+	if strings.Contains(got, "\t\"context\"") {
+		t.Errorf("context imported again next to stdctx:\n%s", got)
+	}
+}
+
+func TestIsTestName(t *testing.T) {
+	for name, want := range map[string]bool{
+		"Test": true, "TestGet": true, "Test_get": true, "Test1": true,
+		"Testget": false, "BenchmarkGet": false,
+	} {
+		if got := isTestName(name); got != want {
+			t.Errorf("isTestName(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
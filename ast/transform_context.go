@@ -2,6 +2,7 @@ package ast
 
 import (
 	"bytes"
+	"fmt"
 	"strings"
 	"text/template"
 
@@ -11,15 +12,16 @@ import (
 
 // evalTemplateStmts evaluates a Go text/template against a TransformContext and parses
 // the result into dst statements. Returns (nil, nil) for empty code, or an error for
-// template parse/execute failures.
+// template parse/execute failures and for expanded code that is not valid Go.
 //
-// Used by Transform, Hook, and Inject Advice to turn user-provided code strings into
-// AST nodes with template variables like {{.FuncName}}, {{.ArgsList}}, {{.HasCtx}}.
-func evalTemplateStmts(tc TransformContext, code string) ([]dst.Stmt, error) {
+// Used by Hook and Inject Advice to turn user-provided code strings into AST nodes
+// with template variables like {{.FuncName}}, {{.ArgsList}}, {{.HasCtx}} and the
+// templateFuncs library. funcs is shared across one Apply (see uniqueVar).
+func evalTemplateStmts(funcs template.FuncMap, tc TransformContext, code string) ([]dst.Stmt, error) {
 	if strings.TrimSpace(code) == "" {
 		return nil, nil
 	}
-	tmpl, err := template.New("advice").Parse(code)
+	tmpl, err := template.New("advice").Funcs(funcs).Parse(code)
 	if err != nil {
 		return nil, err
 	}
@@ -27,7 +29,11 @@ func evalTemplateStmts(tc TransformContext, code string) ([]dst.Stmt, error) {
 	if err := tmpl.Execute(&buf, tc); err != nil {
 		return nil, err
 	}
-	return parseCodeBlock(buf.String())
+	stmts, err := parseCodeBlock(buf.String())
+	if err != nil {
+		return nil, fmt.Errorf("expanded code does not parse: %w", err)
+	}
+	return stmts, nil
 }
 
 // ArgAt returns the i-th argument as a string, or "" if i is out of range.
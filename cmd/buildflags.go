@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"os"
+	"strings"
+)
+
+// buildTagsEnv passes the build's -tags (comma-separated) from runFastBuild
+// to the toolexec children, which evaluate rule `build:` clauses with them.
+// The compiler invocation itself never sees -tags.
+const buildTagsEnv = "GO_API_BUILD_TAGS"
+
+// buildFlags is what runFastBuild needs from the user's go build/test/run
+// command line: the -tags for rule `build:` clauses, and the flags that
+// change how packages compile, which pre-resolve must replay so its
+// archives match the real build (otherwise the fingerprints differ and the
+// link fails).
+type buildFlags struct {
+	// Tags is the effective -tags: GOFLAGS, then the command line (the last
+	// -tags wins, as in cmd/go). nil = none.
+	Tags []string
+
+	// Replay holds the command-line flags to pass on to `go list`, in order,
+	// as "-name" / "-name=value". GOFLAGS is not replayed — `go list`
+	// inherits the environment.
+	Replay []string
+}
+
+// replayFlags lists the build flags that affect compiled archives, by
+// family, and whether each takes a value.
+var replayFlags = map[string]bool{
+	// build constraints
+	"tags": true,
+	// sanitizers / instrumentation
+	"race": false, "msan": false, "asan": false,
+	// coverage (go build -cover since 1.20, go test -cover)
+	"cover": false, "covermode": true, "coverpkg": true,
+	// code generation and paths
+	"gcflags": true, "asmflags": true, "trimpath": false, "buildmode": true,
+	"pgo": true, "installsuffix": true, "linkshared": false, "compiler": true,
+	// module graph (-mod is chosen by pre-resolve itself, see isVendorProject)
+	"modfile": true, "overlay": true,
+}
+
+// skipValueFlags are flags not replayed that take a separate value, so
+// "-o out" does not read "out" as the next flag. Boolean flags (-v, -x,
+// -a, -work, …) need no entry.
+var skipValueFlags = map[string]bool{
+	"o": true, "p": true, "C": true, "mod": true, "ldflags": true, "gccgoflags": true,
+	"toolexec": true, "exec": true, "pkgdir": true,
+	"debug-actiongraph": true, "debug-runtime-trace": true, "debug-trace": true,
+	// go test
+	"run": true, "skip": true, "bench": true, "benchtime": true, "count": true,
+	"cpu": true, "parallel": true, "timeout": true, "list": true, "shuffle": true,
+	"vet": true, "fuzz": true, "fuzztime": true, "fuzzminimizetime": true,
+	"outputdir": true, "coverprofile": true, "cpuprofile": true, "memprofile": true,
+	"memprofilerate": true, "blockprofile": true, "blockprofilerate": true,
+	"mutexprofile": true, "mutexprofilefraction": true, "trace": true,
+}
+
+// parseBuildFlags reads the build flags from GOFLAGS and the arguments of
+// the go subcommand subCmd. Accepts "-name=value", "-name value" and
+// "--name"; scanning stops at "-args" (go test) and "--". go test also
+// takes flags after the package list, so its non-flag arguments are
+// skipped; for build, run and install the flags end at the first non-flag
+// argument — what follows `go run main.go` belongs to the program.
+func parseBuildFlags(subCmd string, args []string) *buildFlags {
+	f := &buildFlags{}
+	scan := func(fields []string, replay bool) {
+		for i := 0; i < len(fields); i++ {
+			arg := fields[i]
+			if arg == "-args" || arg == "--" {
+				return
+			}
+			if !strings.HasPrefix(arg, "-") {
+				if subCmd != "test" {
+					return
+				}
+				continue
+			}
+			name, value, hasValue := strings.Cut(strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-"), "=")
+			takesValue, known := replayFlags[name]
+			if !known {
+				if skipValueFlags[name] && !hasValue {
+					i++
+				}
+				continue
+			}
+			if takesValue && !hasValue {
+				if i+1 >= len(fields) {
+					return
+				}
+				i++
+				value, hasValue = fields[i], true
+			}
+			if name == "tags" {
+				f.Tags = splitTags(value)
+			}
+			if !replay {
+				continue
+			}
+			if hasValue {
+				f.Replay = append(f.Replay, "-"+name+"="+value)
+			} else {
+				f.Replay = append(f.Replay, "-"+name)
+			}
+		}
+	}
+	scan(strings.Fields(os.Getenv("GOFLAGS")), false)
+	scan(args, true)
+	return f
+}
+
+// splitTags splits a -tags value. Commas are the separator since Go 1.13;
+// spaces are still accepted.
+func splitTags(value string) []string {
+	return strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' })
+}
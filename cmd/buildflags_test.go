@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestParseBuildFlags covers each flag family pre-resolve replays, the
+// accepted spellings, and the flags it must leave out.
+func TestParseBuildFlags(t *testing.T) {
+	cases := []struct {
+		name   string
+		subCmd string
+		args   []string
+		replay []string
+		tags   []string
+	}{
+		{"tags", "build", []string{"-tags=integration,netgo", "./..."}, []string{"-tags=integration,netgo"}, []string{"integration", "netgo"}},
+		{"tags separate value", "build", []string{"-tags", "a b", "."}, []string{"-tags=a b"}, []string{"a", "b"}},
+		{"last tags wins", "build", []string{"-tags=a", "-tags=b", "."}, []string{"-tags=a", "-tags=b"}, []string{"b"}},
+		{"sanitizers", "build", []string{"-race", "-msan", "--asan", "."}, []string{"-race", "-msan", "-asan"}, nil},
+		{"coverage", "build", []string{"-cover", "-covermode", "atomic", "-coverpkg=./...", "."}, []string{"-cover", "-covermode=atomic", "-coverpkg=./..."}, nil},
+		{"codegen", "build", []string{"-gcflags", "all=-N -l", "-asmflags=-D=X", "-trimpath", "-buildmode=pie", "-pgo=off", "."},
+			[]string{"-gcflags=all=-N -l", "-asmflags=-D=X", "-trimpath", "-buildmode=pie", "-pgo=off"}, nil},
+		{"install layout", "build", []string{"-installsuffix", "static", "-linkshared", "-compiler=gc", "."}, []string{"-installsuffix=static", "-linkshared", "-compiler=gc"}, nil},
+		{"module graph", "build", []string{"-modfile=go.alt.mod", "-overlay", "overlay.json", "."}, []string{"-modfile=go.alt.mod", "-overlay=overlay.json"}, nil},
+		{"link and output flags are not replayed", "build", []string{"-o", "-race-bin", "-ldflags", "-X main.v=1", "-v", "-x", "-mod=vendor", "."}, nil, nil},
+		{"go test flags after packages", "test", []string{"./pkg/...", "-run", "-race", "-count=1", "-race"}, []string{"-race"}, nil},
+		{"go run program args", "run", []string{"-race", "main.go", "-tags=x", "-race"}, []string{"-race"}, nil},
+		{"go build stops at packages", "build", []string{"./cmd/app", "-race"}, nil, nil},
+		{"stop at -args", "test", []string{"-race", ".", "-args", "-tags=x"}, []string{"-race"}, nil},
+		{"bool with value", "build", []string{"-trimpath=false", "."}, []string{"-trimpath=false"}, nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("GOFLAGS", "")
+			f := parseBuildFlags(tc.subCmd, tc.args)
+			if !reflect.DeepEqual(f.Replay, tc.replay) {
+				t.Errorf("Replay = %q, want %q", f.Replay, tc.replay)
+			}
+			if !reflect.DeepEqual(f.Tags, tc.tags) {
+				t.Errorf("Tags = %q, want %q", f.Tags, tc.tags)
+			}
+		})
+	}
+}
+
+// TestParseBuildFlags_GOFLAGS reads -tags from GOFLAGS for rule `build:`
+// clauses but does not replay GOFLAGS — go list inherits it.
+func TestParseBuildFlags_GOFLAGS(t *testing.T) {
+	t.Setenv("GOFLAGS", "-mod=mod -tags=fromenv -race")
+	f := parseBuildFlags("build", []string{"."})
+	if len(f.Replay) != 0 || !reflect.DeepEqual(f.Tags, []string{"fromenv"}) {
+		t.Errorf("Replay = %q, Tags = %q", f.Replay, f.Tags)
+	}
+	f = parseBuildFlags("build", []string{"-tags=cli", "."})
+	if !reflect.DeepEqual(f.Tags, []string{"cli"}) {
+		t.Errorf("command line should override GOFLAGS: Tags = %q", f.Tags)
+	}
+}
@@ -9,6 +9,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/whatap/go-api-inst/ast"
+	"github.com/whatap/go-api-inst/ast/custom"
 	"github.com/whatap/go-api-inst/config"
 	"github.com/whatap/go-api-inst/report"
 	"golang.org/x/mod/modfile"
@@ -109,22 +111,42 @@ var frameworkToWhatap = []struct {
 	{"google.golang.org/grpc", "github.com/whatap/go-api/instrumentation/google.golang.org/grpc/whatapgrpc"},
 	{"gorm.io/gorm", "github.com/whatap/go-api/instrumentation/github.com/go-gorm/gorm/whatapgorm"},
 	{"github.com/jinzhu/gorm", "github.com/whatap/go-api/instrumentation/github.com/jinzhu/gorm/whatapgorm"},
+	{"entgo.io/ent", "github.com/whatap/go-api/instrumentation/entgo.io/ent/whatapent"},
+	{"github.com/uptrace/bun", "github.com/whatap/go-api/instrumentation/github.com/uptrace/bun/whatapbun"},
+	{"xorm.io/xorm", "github.com/whatap/go-api/instrumentation/xorm.io/xorm/whatapxorm"},
 	{"github.com/jmoiron/sqlx", "github.com/whatap/go-api/instrumentation/github.com/jmoiron/sqlx/whatapsqlx"},
 	{"github.com/sirupsen/logrus", "github.com/whatap/go-api/instrumentation/github.com/sirupsen/logrus/whataplogrus"},
+	{"github.com/bradfitz/gomemcache", "github.com/whatap/go-api/instrumentation/github.com/bradfitz/gomemcache/memcache/whatapmemcache"},
+	{"github.com/redis/rueidis", "github.com/whatap/go-api/instrumentation/github.com/redis/rueidis/whataprueidis"},
 	{"github.com/gomodule/redigo", "github.com/whatap/go-api/instrumentation/github.com/gomodule/redigo/whatapredigo"},
 	{"github.com/redis/go-redis/v9", "github.com/whatap/go-api/instrumentation/github.com/redis/go-redis/v9/whatapgoredis"},
 	{"github.com/go-redis/redis/v8", "github.com/whatap/go-api/instrumentation/github.com/go-redis/redis/v8/whatapgoredis"},
 	{"go.mongodb.org/mongo-driver", "github.com/whatap/go-api/instrumentation/go.mongodb.org/mongo-driver/mongo/whatapmongo"},
 	{"github.com/IBM/sarama", "github.com/whatap/go-api/instrumentation/github.com/IBM/sarama/whatapsarama"},
 	{"github.com/Shopify/sarama", "github.com/whatap/go-api/instrumentation/github.com/Shopify/sarama/whatapsarama"},
+	{"github.com/segmentio/kafka-go", "github.com/whatap/go-api/instrumentation/github.com/segmentio/kafka-go/whatapkafka"},
+	{"github.com/nats-io/nats.go", "github.com/whatap/go-api/instrumentation/github.com/nats-io/nats.go/whatapnats"},
 	{"github.com/aerospike/aerospike-client-go/v6", "github.com/whatap/go-api/instrumentation/github.com/aerospike/aerospike-client-go/v6/whatapas"},
 	{"github.com/gofiber/fiber/v2", "github.com/whatap/go-api/instrumentation/github.com/gofiber/fiber/v2/whatapfiber"},
 	{"k8s.io/client-go", "github.com/whatap/go-api/instrumentation/k8s.io/client-go/kubernetes/whatapkubernetes"},
+	{"sigs.k8s.io/controller-runtime", "github.com/whatap/go-api/instrumentation/sigs.k8s.io/controller-runtime/whatapcontrollerruntime"},
+	{"github.com/go-resty/resty/v2", "github.com/whatap/go-api/instrumentation/github.com/go-resty/resty/v2/whatapresty"},
+	{"github.com/hashicorp/go-retryablehttp", "github.com/whatap/go-api/instrumentation/github.com/hashicorp/go-retryablehttp/whatapretryablehttp"},
+	{"github.com/elastic/go-elasticsearch/v8", "github.com/whatap/go-api/instrumentation/github.com/elastic/go-elasticsearch/whatapelasticsearch"},
+	{"github.com/opensearch-project/opensearch-go/v2", "github.com/whatap/go-api/instrumentation/github.com/opensearch-project/opensearch-go/whatapopensearch"},
+	{"github.com/opensearch-project/opensearch-go/v4", "github.com/whatap/go-api/instrumentation/github.com/opensearch-project/opensearch-go/whatapopensearch"},
+	{"go.temporal.io/sdk", "github.com/whatap/go-api/instrumentation/go.temporal.io/sdk/whataptemporal"},
+	{"github.com/aws/aws-lambda-go", "github.com/whatap/go-api/instrumentation/github.com/aws/aws-lambda-go/lambda/whataplambda"},
 	{"github.com/sashabaranov/go-openai", "github.com/whatap/go-api/instrumentation/llm/github.com/sashabaranov/go-openai/whatapopenai"},
 	{"github.com/cloudwego/eino-ext/components/model/openai", "github.com/whatap/go-api/instrumentation/llm/github.com/cloudwego/eino/whatapeino"},
 	{"github.com/cloudwego/eino-ext/components/model/claude", "github.com/whatap/go-api/instrumentation/llm/github.com/cloudwego/eino/whatapeino"},
 	{"github.com/anthropics/anthropic-sdk-go", "github.com/whatap/go-api/instrumentation/llm/github.com/anthropics/anthropic-sdk-go/whatapanthropic"},
 	{"github.com/openai/openai-go", "github.com/whatap/go-api/instrumentation/llm/github.com/openai/openai-go/whatapopenaigo"},
+	{"google.golang.org/genai", "github.com/whatap/go-api/instrumentation/llm/google.golang.org/genai/whatapgenai"},
+	{"github.com/ollama/ollama", "github.com/whatap/go-api/instrumentation/llm/github.com/ollama/ollama/api/whatapollama"},
+	{"github.com/mark3labs/mcp-go", "github.com/whatap/go-api/instrumentation/llm/github.com/mark3labs/mcp-go/whatapmcp"},
+	{"github.com/modelcontextprotocol/go-sdk", "github.com/whatap/go-api/instrumentation/llm/github.com/modelcontextprotocol/go-sdk/mcp/whatapmcpsdk"},
+	{"github.com/tmc/langchaingo", "github.com/whatap/go-api/instrumentation/llm/github.com/tmc/langchaingo/whataplangchaingo"},
 }
 
 // buildVendorToolFile scans go.mod and builds a tool file with imports
@@ -299,7 +321,10 @@ func runFastBuild(subCmd string, args []string, cfg *config.Config, errorTrackin
 	// `inject`/`remove` commands). Dependency-level information is populated
 	// here in the parent process.
 	InitReport("build")
-	loadDependencies(report.Get(), projectDir)
+	// The user's build flags: rule `build:` clauses and pre-resolve must
+	// see the same build configuration as the real compile.
+	userFlags := parseBuildFlags(subCmd, args)
+	loadDependencies(report.Get(), projectDir, ast.DefaultBuildContext(userFlags.Tags))
 
 	// §240: Snapshot the effective config so the report is reproducible —
 	// readers can see preset/enabled/disabled packages, external modules,
@@ -369,7 +394,8 @@ func runFastBuild(subCmd string, args []string, cfg *config.Config, errorTrackin
 	}
 
 	// §270: LLM nested module 은 조건부 require — 사용자 go.mod 에 LLM SDK
-	// (sashabaranov / eino-ext openai/claude / anthropic-sdk-go) 매핑이 있는
+	// (sashabaranov / eino-ext openai/claude / anthropic-sdk-go / openai-go /
+	// genai / ollama / langchaingo / MCP) 매핑이 있는
 	// 경우에만 추가. LLM 안 쓰는 사용자에게 무거운 anthropic + eino transitive
 	// 부담 안 줌. (본체 go-api 와 같은 lockstep 버전 사용.)
 	if needsGoAPILLM(projectDir) && !hasGoAPILLMRequire(projectDir) {
@@ -439,7 +465,7 @@ func runFastBuild(subCmd string, args []string, cfg *config.Config, errorTrackin
 
 	// 2. Pre-resolve whatap package archives
 	phaseStart = time.Now()
-	resolveCache, err := preResolveWhatapPackages(projectDir, debug)
+	resolveCache, err := preResolveWhatapPackages(projectDir, userFlags.Replay, debug)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "[whatap-go-inst] Warning: pre-resolve failed: %v\n", err)
 		// Continue without importcfg patching; toolexec will still transform source.
@@ -471,6 +497,7 @@ func runFastBuild(subCmd string, args []string, cfg *config.Config, errorTrackin
 		preResolveInfo.ResolvedCount = len(resolveCache.Packages)
 		preResolveInfo.ReplacedModules = append([]string(nil), resolveCache.ReplacedModules...)
 	}
+	preResolveInfo.BuildFlags = userFlags.Replay
 	if cfg.HasExternalModules() {
 		debugEnv := debug
 		expanded := resolveExternalModuleList(cfg.ExternalModules, projectDir, debugEnv)
@@ -483,9 +510,16 @@ func runFastBuild(subCmd string, args []string, cfg *config.Config, errorTrackin
 	// (a) never overwrite existing files and (b) defer-remove what we created
 	// to guarantee cleanup even on build failure. Append rules are NOT handled
 	// here — they are the Step 2 scope (toolexec-time).
+	//
+	// instrumentation.library_init arrives here as one more add rule.
+	addRules, err := custom.AddRules(projectDir, cfg, userFlags.Tags)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[whatap-go-inst] %v\n", err)
+		os.Exit(1)
+	}
 	var addedFiles []string
-	if len(cfg.Add) > 0 {
-		created, err := applyAddRulesFast(projectDir, cfg, debug)
+	if len(addRules) > 0 {
+		created, err := applyAddRulesFast(projectDir, cfg.BaseDir, addRules, debug)
 		if err != nil {
 			for _, f := range created {
 				os.Remove(f)
@@ -566,6 +600,7 @@ func runFastBuild(subCmd string, args []string, cfg *config.Config, errorTrackin
 	env = filterEnvVar(env, "GO_API_RESOLVE_CACHE")
 	env = filterEnvVar(env, "GO_API_EXTERNAL_MODULES")
 	env = filterEnvVar(env, "GO_API_REPORT_FRAG_DIR")
+	env = filterEnvVar(env, buildTagsEnv)
 	if instrumentedOutputDir != "" {
 		env = append(env, "GO_API_AST_OUTPUT_DIR="+instrumentedOutputDir)
 	}
@@ -580,6 +615,9 @@ func runFastBuild(subCmd string, args []string, cfg *config.Config, errorTrackin
 	if cfg.HasExternalModules() {
 		env = append(env, "GO_API_EXTERNAL_MODULES="+strings.Join(cfg.ExternalModules, ","))
 	}
+	if len(userFlags.Tags) > 0 {
+		env = append(env, buildTagsEnv+"="+strings.Join(userFlags.Tags, ","))
+	}
 	// §188: Pass vendor mode to toolexec
 	if isVendor {
 		env = append(env, "GO_API_VENDOR_MODE=true")
@@ -836,13 +874,14 @@ func copyProjectFiles(srcDir, dstDir string) {
 	}
 }
 
-// applyAddRulesFast creates files from cfg.Add inside projectDir. Returns the
-// absolute paths of created files for deferred removal.
+// applyAddRulesFast creates files from rules (cfg.Add plus library_init)
+// inside projectDir. Returns the absolute paths of created files for
+// deferred removal.
 //
 // Safety contract (§211):
 //   - Never overwrite an existing file — returns an error on conflict so the
 //     caller can abort the build and undo previously created files.
-//   - content_file is resolved relative to cfg.BaseDir (same as ast/custom/add.go).
+//   - content_file is resolved relative to baseDir (cfg.BaseDir, same as ast/custom/add.go).
 //
 // The duplicated implementation (vs. ast/custom/add.go) is intentional: that
 // package writes to a dstDir copy (wrap/inject) and may overwrite. Fast mode
@@ -850,9 +889,9 @@ func copyProjectFiles(srcDir, dstDir string) {
 //
 // `append: true` was removed in v0.5.5 — the yaml loader rejects it before we
 // get here, so rules reaching this point are always new-file creations.
-func applyAddRulesFast(projectDir string, cfg *config.Config, debug bool) ([]string, error) {
+func applyAddRulesFast(projectDir, baseDir string, rules []config.AddRule, debug bool) ([]string, error) {
 	var created []string
-	for _, rule := range cfg.Add {
+	for _, rule := range rules {
 		// Resolve target file path under projectDir.
 		var filePath string
 		if rule.Package == "main" || rule.Package == "." || rule.Package == "" {
@@ -874,7 +913,7 @@ func applyAddRulesFast(projectDir string, cfg *config.Config, debug bool) ([]str
 		if rule.ContentFile != "" {
 			contentPath := rule.ContentFile
 			if !filepath.IsAbs(contentPath) {
-				contentPath = filepath.Join(cfg.BaseDir, contentPath)
+				contentPath = filepath.Join(baseDir, contentPath)
 			}
 			data, err := os.ReadFile(contentPath)
 			if err != nil {
@@ -35,7 +35,13 @@ type goListEntry struct {
 // Since toolexec only transforms project source and external-module packages
 // (not third-party libraries like gin), pre-resolve's cached artifacts are
 // compatible with the main build — library fingerprints remain unchanged.
-func preResolveWhatapPackages(projectDir string, debug bool) (*ResolveCache, error) {
+//
+// buildFlags are the user's compile-affecting flags (buildFlags.Replay:
+// -tags, -race, -gcflags, -trimpath, -cover, …). The whatap packages must
+// be compiled exactly as in the real build, otherwise the archive
+// fingerprints differ and the link fails. GOOS/GOARCH/CGO_ENABLED and
+// GOFLAGS come from the inherited environment.
+func preResolveWhatapPackages(projectDir string, buildFlags []string, debug bool) (*ResolveCache, error) {
 	// -deps: include transitive dependencies (needed for linker importcfg)
 	// §191: Use -mod=vendor for vendor projects (whatap packages are now in vendor/
 	// via Orchestrion pattern: tool file → tidy → vendor). This ensures pre-resolved
@@ -47,9 +53,15 @@ func preResolveWhatapPackages(projectDir string, debug bool) (*ResolveCache, err
 	// §270: LLM 어댑터는 별도 nested module (github.com/whatap/go-api/instrumentation/llm)
 	// 이므로 본체 패턴 (github.com/whatap/go-api/...) 에 안 잡힘. 별도 인자로 추가.
 	// `-e` flag 가 사용자 go.mod 에 LLM module require 없을 때 not-found 흡수.
-	cmd := exec.Command("go", "list", modFlag, "-json", "-export", "-e", "-deps",
+	listArgs := []string{"list", modFlag, "-json", "-export", "-e", "-deps"}
+	listArgs = append(listArgs, buildFlags...)
+	listArgs = append(listArgs,
 		"github.com/whatap/go-api/...",
 		"github.com/whatap/go-api/instrumentation/llm/...")
+	cmd := exec.Command("go", listArgs...)
+	if debug && len(buildFlags) > 0 {
+		fmt.Fprintf(os.Stderr, "[whatap-go-inst] pre-resolve build flags: %s\n", strings.Join(buildFlags, " "))
+	}
 	cmd.Dir = projectDir
 	cmd.Stderr = os.Stderr
 
@@ -79,7 +91,9 @@ func preResolveWhatapPackages(projectDir string, debug bool) (*ResolveCache, err
 	// Without this, toolexec adds e.g. "os" import but importcfg has no archive for it,
 	// causing "could not import os" compiler error.
 	stdlibPkgs := []string{"os", "context", "fmt", "log", "net/http"}
+	// The same buildFlags apply: -race / -gcflags change stdlib archives too.
 	stdCmd := exec.Command("go", "list", "-json", "-export", "-e")
+	stdCmd.Args = append(stdCmd.Args, buildFlags...)
 	stdCmd.Args = append(stdCmd.Args, stdlibPkgs...)
 	stdCmd.Dir = projectDir
 	if stdOut, err := stdCmd.Output(); err == nil {
@@ -182,8 +182,12 @@ func FinalizeReport() {
 // opt-in / exclusion, plus unfiltered user rules from cfg.Rules). No
 // reimplementation — callers go through `ast.NewInjector() + SetConfig` so
 // report and engine stay in lock step by construction.
-func loadDependencies(r *report.Report, baseDir string) {
+//
+// buildCtx is the build's target (rule `build:` clauses); nil = the
+// environment's.
+func loadDependencies(r *report.Report, baseDir string, buildCtx *ast.BuildContext) {
 	inj := ast.NewInjector()
+	inj.BuildContext = buildCtx
 	if globalConfig != nil {
 		inj.EnabledPackages = globalConfig.Instrumentation.EnabledPackages
 		inj.DisabledPackages = globalConfig.Instrumentation.DisabledPackages
@@ -196,7 +200,7 @@ func loadDependencies(r *report.Report, baseDir string) {
 	infos := make([]report.TransformerInfo, 0)
 	seen := make(map[string]bool)
 	for _, rule := range inj.Rules() {
-		pkg := ast.ExtractRulePackage(rule.Target)
+		pkg := ast.LiteralRulePackage(rule.Target)
 		if pkg == "" || seen[pkg] {
 			continue
 		}
@@ -209,6 +213,28 @@ func loadDependencies(r *report.Report, baseDir string) {
 			fmt.Fprintf(os.Stderr, "[whatap-go-inst] Warning: Failed to load go.mod: %v\n", err)
 		}
 	}
+
+	// Rules whose `build:` / `when:` clause does not hold for this build,
+	// or whose template failed its dry run. The toolexec children build the
+	// same registry and skip them silently.
+	for _, skip := range inj.SkippedRules() {
+		if skip.Template {
+			fmt.Fprintf(os.Stderr, "[whatap-go-inst] custom rules: rule %s skipped: %s\n", skip.RuleID, skip.Reason)
+			r.AddSkippedRule(report.Diagnostic{
+				Level:   report.DiagError,
+				RuleID:  skip.RuleID,
+				Message: skip.Reason,
+				Hint:    "rule not applied; fix the template, or guard argument access with {{if gt .ArgCount N}}",
+			})
+			continue
+		}
+		r.AddSkippedRule(report.Diagnostic{
+			Level:   report.DiagInfo,
+			RuleID:  skip.RuleID,
+			Message: skip.Reason,
+			Hint:    "rule not applied; adjust its build: / when: clause if the wrapper supports this build",
+		})
+	}
 }
 
 // extractRulePackage moved to ast.ExtractRulePackage (§242).
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -174,6 +175,9 @@ func processCompileArgs(args []string) []string {
 
 	// Transformed files
 	injector := ast.NewInjector()
+	// Rule `build:` clauses: GOOS/GOARCH/CGO_ENABLED are this compile's
+	// environment; -tags come from runFastBuild.
+	injector.BuildContext = ast.DefaultBuildContext(splitTags(os.Getenv(buildTagsEnv)))
 	// §208: Pass config to injector for custom rules and preset filtering.
 	// §227 Step 5: SetConfig() rebuilds the registry so user-defined rules
 	// from cfg.Rules get registered alongside built-ins. A bare assignment
@@ -212,6 +216,7 @@ func processCompileArgs(args []string) []string {
 	// §179: Use TOOLEXEC_IMPORTPATH for accurate package identification.
 	// File path matching cannot distinguish major versions (e.g., /v8 vs /v9).
 	importPath := os.Getenv("TOOLEXEC_IMPORTPATH")
+	injector.ImportPath = importPath // rule `scope:` matching
 
 	// §174: Pre-load type info from importcfg for all transformable goFiles.
 	// Uses importer.ForCompiler (reads .a archives) instead of packages.Load (triggers go list → panic).
@@ -304,6 +309,13 @@ func processCompileArgs(args []string) []string {
 			}()
 			injectErr = injector.InjectFile(goFile, tmpFile)
 		}()
+		if errors.Is(injectErr, ast.ErrRuleFailed) {
+			// fail_on_rule_error: stop this compile so `go build` fails
+			// instead of silently building the uninstrumented original.
+			fmt.Fprintf(os.Stderr, "[whatap-go-inst] %v\n", injectErr)
+			saveReportFragment(importPath, debug)
+			os.Exit(1)
+		}
 		if injectErr != nil {
 			// Use original on transformation failure
 			transformedFiles = append(transformedFiles, goFile)
@@ -388,18 +400,12 @@ func processCompileArgs(args []string) []string {
 	// the fragment dir and passes it via GO_API_REPORT_FRAG_DIR). No fragment
 	// is written when there were no file-level records (e.g. all goFiles were
 	// GOROOT/GOMODCACHE-passthroughs).
-	if fragDir := os.Getenv("GO_API_REPORT_FRAG_DIR"); fragDir != "" {
-		r := report.Get()
-		if r != nil && len(r.Files) > 0 {
-			safe := strings.NewReplacer("/", "_", "\\", "_", ":", "_").Replace(importPath)
-			if safe == "" {
-				safe = "unknown"
-			}
-			fragPath := filepath.Join(fragDir,
-				fmt.Sprintf("%s-%d-%d.json", safe, os.Getpid(), time.Now().UnixNano()))
-			if err := r.SaveJSONQuiet(fragPath); err != nil && debug {
-				fmt.Fprintf(os.Stderr, "[whatap-go-inst] §239: save fragment %s failed: %v\n", fragPath, err)
-			}
+	//
+	// Without --report nobody would see this package's rule errors, so they
+	// go to stderr (shown by `go build` under the package header).
+	if !saveReportFragment(importPath, debug) {
+		for _, d := range report.Get().RuleErrors() {
+			fmt.Fprintf(os.Stderr, "[whatap-go-inst] %s:%d: rule %s: %s\n", d.File, d.Line, d.RuleID, d.Message)
 		}
 	}
 
@@ -408,6 +414,28 @@ func processCompileArgs(args []string) []string {
 	return newArgs
 }
 
+// saveReportFragment writes this child's report as a fragment JSON into
+// GO_API_REPORT_FRAG_DIR (§239). Returns false when --report is off.
+func saveReportFragment(importPath string, debug bool) bool {
+	fragDir := os.Getenv("GO_API_REPORT_FRAG_DIR")
+	if fragDir == "" {
+		return false
+	}
+	r := report.Get()
+	if r != nil && len(r.Files) > 0 {
+		safe := strings.NewReplacer("/", "_", "\\", "_", ":", "_").Replace(importPath)
+		if safe == "" {
+			safe = "unknown"
+		}
+		fragPath := filepath.Join(fragDir,
+			fmt.Sprintf("%s-%d-%d.json", safe, os.Getpid(), time.Now().UnixNano()))
+		if err := r.SaveJSONQuiet(fragPath); err != nil && debug {
+			fmt.Fprintf(os.Stderr, "[whatap-go-inst] §239: save fragment %s failed: %v\n", fragPath, err)
+		}
+	}
+	return true
+}
+
 // saveInstrumentedFile copies transformed file to save directory
 func saveInstrumentedFile(originalPath, transformedPath, saveDir string) {
 	// Calculate relative path from project root
@@ -509,6 +537,10 @@ func shouldSkipFile(path string) bool {
 	if os.Getenv("GO_API_VENDOR_MODE") == "true" {
 		excludePatterns = excludePatternsWithoutVendor()
 	}
+	// instrumentation.test_mode: _test.go files are instrumented too.
+	if globalConfig != nil && globalConfig.Instrumentation.TestMode.Enabled {
+		excludePatterns = excludePatternsWithoutTests(excludePatterns)
+	}
 	skip := common.ShouldSkipFileExcludeOnly(path, basePath, excludePatterns)
 
 	debug := os.Getenv("GO_API_AST_DEBUG") != ""
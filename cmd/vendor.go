@@ -147,3 +147,18 @@ func excludePatternsWithoutVendor() []string {
 	}
 	return filtered
 }
+
+// excludePatternsWithoutTests returns patterns (DefaultExcludePatterns if
+// nil) with "**/*_test.go" removed. Used in toolexec test mode.
+func excludePatternsWithoutTests(patterns []string) []string {
+	if patterns == nil {
+		patterns = config.DefaultExcludePatterns
+	}
+	var filtered []string
+	for _, p := range patterns {
+		if p != "**/*_test.go" {
+			filtered = append(filtered, p)
+		}
+	}
+	return filtered
+}
@@ -124,6 +124,71 @@ type InstrumentationConfig struct {
 	// when you know your replace target is signature-compatible with the
 	// original and you want instrumentation applied anyway.
 	SkipReplacedModules *bool `yaml:"skip_replaced_modules,omitempty"`
+
+	// FailOnRuleError turns custom-rule errors into build failures: a rule
+	// that does not load, or a template that fails to execute / expands to
+	// invalid Go at a match. Default (false) reports them as diagnostics and
+	// builds with the affected matches left uninstrumented.
+	FailOnRuleError bool `yaml:"fail_on_rule_error"`
+
+	// RuleScopes restricts built-in rules to parts of the user's code, keyed
+	// by the rule's target package path (same keys as enabled_packages):
+	//   rule_scopes:
+	//     fmt: {exclude: ["./cmd/cli/..."]}
+	// User rules carry their own `scope:` block instead.
+	RuleScopes map[string]RuleScope `yaml:"rule_scopes,omitempty"`
+
+	// TestMode instruments `whatap-go-inst go test` binaries (opt-in). Off,
+	// _test.go files and the generated test main are left alone and tests
+	// run without an agent.
+	TestMode TestModeConfig `yaml:"test_mode"`
+
+	// LibraryInit starts the agent from a generated init() in a package
+	// without main() — plugins, c-shared libraries, libraries loaded by a
+	// non-Go host. Delivered as an extra add rule (ast/custom/library_init.go).
+	LibraryInit *LibraryInitConfig `yaml:"library_init,omitempty"`
+}
+
+// LibraryInitConfig is the `instrumentation.library_init` block.
+type LibraryInitConfig struct {
+	// Package is the target package relative to the project root, as in
+	// add[].package ("main" / "." / "" = root).
+	Package string `yaml:"package"`
+
+	// File is the generated file name. Default whatap_library_init.go.
+	File string `yaml:"file,omitempty"`
+
+	// Shutdown is how the agent is flushed: "signal" (default; on
+	// SIGINT/SIGTERM), "export" (exported Cleanup function the host calls),
+	// "both" or "none".
+	Shutdown string `yaml:"shutdown,omitempty"`
+
+	// Cleanup names the exported cleanup function. Default WhatapShutdown.
+	Cleanup string `yaml:"cleanup,omitempty"`
+
+	// CgoExport marks Cleanup `//export` so a c-shared host can call it
+	// from C. Needs cgo.
+	CgoExport bool `yaml:"cgo_export,omitempty"`
+}
+
+// TestModeConfig is the `instrumentation.test_mode` block.
+type TestModeConfig struct {
+	// Enabled instruments _test.go files like regular sources and starts
+	// the agent in the test binary: trace.Init in the generated test main,
+	// trace.Shutdown before its os.Exit and before os.Exit in a TestMain.
+	Enabled bool `yaml:"enabled"`
+
+	// Transactions additionally traces each TestXxx function and t.Run
+	// subtest as a transaction named after t.Name(). Requires Enabled.
+	Transactions bool `yaml:"transactions"`
+}
+
+// RuleScope lists package patterns ("./internal/api/...",
+// "mycorp.com/app/...") and "file:" globs a rule is limited to (Include,
+// empty = everywhere) or kept out of (Exclude).
+type RuleScope struct {
+	Include []string `yaml:"include,omitempty"`
+	Exclude []string `yaml:"exclude,omitempty"`
 }
 
 // ShouldSkipReplacedModules returns the effective value of
@@ -244,6 +309,25 @@ func (c *Config) Merge(other *Config) {
 		v := *other.Instrumentation.SkipReplacedModules
 		c.Instrumentation.SkipReplacedModules = &v
 	}
+	if other.Instrumentation.FailOnRuleError {
+		c.Instrumentation.FailOnRuleError = true
+	}
+	for pkg, scope := range other.Instrumentation.RuleScopes {
+		if c.Instrumentation.RuleScopes == nil {
+			c.Instrumentation.RuleScopes = make(map[string]RuleScope)
+		}
+		c.Instrumentation.RuleScopes[pkg] = scope
+	}
+	if other.Instrumentation.TestMode.Enabled {
+		c.Instrumentation.TestMode.Enabled = true
+	}
+	if other.Instrumentation.TestMode.Transactions {
+		c.Instrumentation.TestMode.Transactions = true
+	}
+	if other.Instrumentation.LibraryInit != nil {
+		li := *other.Instrumentation.LibraryInit
+		c.Instrumentation.LibraryInit = &li
+	}
 
 
 	// Merge Exclude (add)
@@ -10,6 +10,8 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"golang.org/x/mod/modfile"
 )
 
 // LogLevel represents log verbosity level
@@ -42,10 +44,16 @@ const (
 	DiagError   DiagnosticLevel = "error"
 )
 
-// Diagnostic represents a diagnostic message for a file
+// Diagnostic represents a diagnostic message for a file.
+//
+// RuleID is set for rule errors (a custom rule's template failed to execute
+// or expanded to invalid Go at this location); File repeats FileReport.Path
+// so the entry still reads on its own in the summary's rule-error list.
 type Diagnostic struct {
 	Level   DiagnosticLevel `json:"level"`
+	File    string          `json:"file,omitempty"`
 	Line    int             `json:"line,omitempty"`
+	RuleID  string          `json:"rule_id,omitempty"`
 	Message string          `json:"message"`
 	Hint    string          `json:"hint,omitempty"`
 }
@@ -81,6 +89,8 @@ type Summary struct {
 	Errors               int            `json:"errors"`
 	Removed              int            `json:"removed"`
 	Warnings             int            `json:"warnings"`
+	RuleErrors           int            `json:"rule_errors,omitempty"`   // Diagnostic entries with a RuleID
+	SkippedRules         int            `json:"skipped_rules,omitempty"` // rules left out by a `when:` clause or a failed dry run
 	SupportedLibraries   int            `json:"supported_libraries"`
 	UnsupportedLibraries int            `json:"unsupported_libraries"`
 	FragmentCount        int            `json:"fragment_count,omitempty"`  // §240 (§239 fragments merged by parent)
@@ -166,6 +176,7 @@ type PreResolveInfo struct {
 	ResolvedCount    int      `json:"resolved_count,omitempty"`
 	ReplacedModules  []string `json:"replaced_modules,omitempty"`
 	ExternalModules  []string `json:"external_modules,omitempty"` // resolved expansions
+	BuildFlags       []string `json:"build_flags,omitempty"`      // user flags replayed into go list
 }
 
 // Report represents the full report
@@ -185,11 +196,13 @@ type Report struct {
 	PreResolve   *PreResolveInfo  `json:"pre_resolve,omitempty"`
 	Summary      Summary          `json:"summary"`
 	Dependencies []Dependency     `json:"dependencies,omitempty"`
+	SkippedRules []Diagnostic     `json:"skipped_rules,omitempty"` // project-wide, one per rule
 	Files        []FileReport     `json:"files"`
 
 	mu       sync.Mutex   `json:"-"`
 	logLevel LogLevel     `json:"-"`
-	warnings []Diagnostic `json:"-"` // collected warnings for summary
+	warnings   []Diagnostic `json:"-"` // collected warnings for summary
+	ruleErrors []Diagnostic `json:"-"` // collected rule errors for summary
 }
 
 // SetConfigSnapshot stores the effective config for the report. §240.
@@ -317,7 +330,8 @@ func (r *Report) AddFile(fr FileReport) {
 	}
 	r.Summary.Total++
 
-	// Count warnings from diagnostics
+	// Count rule errors and warnings from diagnostics
+	r.Summary.RuleErrors += r.collectRuleErrors(fr)
 	for _, d := range fr.Diagnostics {
 		if d.Level == DiagWarning {
 			r.Summary.Warnings++
@@ -333,6 +347,31 @@ func (r *Report) AddFile(fr FileReport) {
 	r.logFile(fr)
 }
 
+// collectRuleErrors records fr's rule-error diagnostics for PrintSummary and
+// returns how many there were. Caller holds r.mu.
+func (r *Report) collectRuleErrors(fr FileReport) int {
+	n := 0
+	for _, d := range fr.Diagnostics {
+		if d.RuleID == "" || d.Level != DiagError {
+			continue
+		}
+		if d.File == "" {
+			d.File = fr.Path
+		}
+		r.ruleErrors = append(r.ruleErrors, d)
+		n++
+	}
+	return n
+}
+
+// RuleErrors returns the rule-error diagnostics recorded so far (including
+// merged fragments).
+func (r *Report) RuleErrors() []Diagnostic {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Diagnostic(nil), r.ruleErrors...)
+}
+
 // AddDependency adds a dependency to the report
 func (r *Report) AddDependency(dep Dependency) {
 	r.mu.Lock()
@@ -347,6 +386,17 @@ func (r *Report) AddDependency(dep Dependency) {
 	}
 }
 
+// AddSkippedRule records a rule that was not registered for this project
+// (d.RuleID names it, d.Message says why). Rule conditions are evaluated
+// once per build, in the parent process, so fragments never carry these.
+func (r *Report) AddSkippedRule(d Diagnostic) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Summary.SkippedRules++
+	r.SkippedRules = append(r.SkippedRules, d)
+}
+
 // AddWarning adds a standalone warning
 func (r *Report) AddWarning(msg, hint string) {
 	r.mu.Lock()
@@ -445,12 +495,24 @@ func (r *Report) PrintSummary() {
 	if r.Summary.Errors > 0 {
 		fmt.Printf("   ❌ Errors: %d files\n", r.Summary.Errors)
 	}
+	if r.Summary.RuleErrors > 0 {
+		fmt.Printf("   ❌ Rule errors: %d\n", r.Summary.RuleErrors)
+	}
+	if r.Summary.SkippedRules > 0 {
+		fmt.Printf("   ⏭️  Skipped rules: %d\n", r.Summary.SkippedRules)
+	}
 	fmt.Printf("   📁 Total: %d files\n", r.Summary.Total)
 	fmt.Println("─────────────────────────────────")
 
 	// Print dependencies if available
 	r.printDependencies()
 
+	// Print rule errors (always — a failing rule means missing instrumentation)
+	r.printRuleErrors()
+
+	// Print skipped rules
+	r.printSkippedRules()
+
 	// Print warnings if available
 	r.printWarnings()
 }
@@ -488,6 +550,43 @@ func (r *Report) printDependencies() {
 	fmt.Println("─────────────────────────────────")
 }
 
+// printRuleErrors prints collected rule errors as file:line: rule <id>: message.
+func (r *Report) printRuleErrors() {
+	if len(r.ruleErrors) == 0 {
+		return
+	}
+
+	fmt.Println("─────────────────────────────────")
+	fmt.Println("❌ Rule errors")
+	fmt.Println("─────────────────────────────────")
+
+	for _, d := range r.ruleErrors {
+		loc := d.File
+		if d.Line > 0 {
+			loc = fmt.Sprintf("%s:%d", d.File, d.Line)
+		}
+		fmt.Printf("   %s: rule %s: %s\n", loc, d.RuleID, d.Message)
+	}
+	fmt.Println("─────────────────────────────────")
+}
+
+// printSkippedRules prints rules left out by their conditions as
+// rule <id>: reason. Verbose only — the summary line already counts them.
+func (r *Report) printSkippedRules() {
+	if len(r.SkippedRules) == 0 || r.logLevel < LogVerbose {
+		return
+	}
+
+	fmt.Println("─────────────────────────────────")
+	fmt.Println("⏭️  Skipped rules")
+	fmt.Println("─────────────────────────────────")
+
+	for _, d := range r.SkippedRules {
+		fmt.Printf("   rule %s: %s\n", d.RuleID, d.Message)
+	}
+	fmt.Println("─────────────────────────────────")
+}
+
 // printWarnings prints collected warnings
 func (r *Report) printWarnings() {
 	if len(r.warnings) == 0 || r.logLevel < LogVerbose {
@@ -545,6 +644,9 @@ func (r *Report) MergeFragment(frag *Report) {
 	defer r.mu.Unlock()
 
 	r.Files = append(r.Files, frag.Files...)
+	for _, fr := range frag.Files {
+		r.collectRuleErrors(fr)
+	}
 	r.Summary.Total += frag.Summary.Total
 	r.Summary.Instrumented += frag.Summary.Instrumented
 	r.Summary.Skipped += frag.Summary.Skipped
@@ -552,6 +654,7 @@ func (r *Report) MergeFragment(frag *Report) {
 	r.Summary.Errors += frag.Summary.Errors
 	r.Summary.Removed += frag.Summary.Removed
 	r.Summary.Warnings += frag.Summary.Warnings
+	r.Summary.RuleErrors += frag.Summary.RuleErrors
 	r.Summary.ImportCfgFails += frag.Summary.ImportCfgFails
 	// §240: merge skip reasons. Null-safe — child fragments may omit the map.
 	for reason, n := range frag.Summary.SkipReasons {
@@ -666,6 +769,35 @@ func (r *Report) LoadDependencies(goModPath string, transformers []TransformerIn
 	return scanner.Err()
 }
 
+// ReadModuleVersions returns the module versions the build uses according
+// to go.mod (module path → version, e.g. "github.com/IBM/sarama" →
+// "v1.38.1"): the require directives with replace directives applied. A
+// module replaced by another module@version gets that version; one replaced
+// by a local directory maps to "" (version unknown). Rule `when:` clauses
+// are evaluated against it.
+func ReadModuleVersions(goModPath string) (map[string]string, error) {
+	data, err := os.ReadFile(goModPath)
+	if err != nil {
+		return nil, err
+	}
+	f, err := modfile.Parse(goModPath, data, nil)
+	if err != nil {
+		return nil, err
+	}
+	versions := make(map[string]string, len(f.Require))
+	for _, req := range f.Require {
+		versions[req.Mod.Path] = req.Mod.Version
+	}
+	for _, rep := range f.Replace {
+		v, ok := versions[rep.Old.Path]
+		if !ok || (rep.Old.Version != "" && rep.Old.Version != v) {
+			continue
+		}
+		versions[rep.Old.Path] = rep.New.Version // "" for a local directory
+	}
+	return versions, nil
+}
+
 // parseDependencyLine parses a single dependency line from go.mod
 func parseDependencyLine(line string, supportedPaths map[string]transformerEntry) *Dependency {
 	// Remove "require " prefix if present
@@ -1,6 +1,8 @@
 package report
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -69,6 +71,30 @@ func TestMatchTransformer_VersionFiltering(t *testing.T) {
 	}
 }
 
+// Rule packages below a module root (gomemcache/memcache) and module-root rule
+// packages (rueidis) must both mark the go.mod require as supported, as
+// loadDependencies feeds LiteralRulePackage(rule.Target) paths in.
+func TestMatchTransformer_CacheClients(t *testing.T) {
+	supportedPaths := map[string]transformerEntry{
+		"github.com/bradfitz/gomemcache/memcache": {},
+		"github.com/redis/rueidis":                {},
+	}
+	tests := []struct {
+		depPath  string
+		wantPath string
+	}{
+		{"github.com/bradfitz/gomemcache", "github.com/bradfitz/gomemcache/memcache"},
+		{"github.com/redis/rueidis", "github.com/redis/rueidis"},
+		{"github.com/redis/rueidis/rueidishook", "github.com/redis/rueidis"},
+	}
+	for _, tt := range tests {
+		got, ok := matchTransformer(tt.depPath, supportedPaths)
+		if !ok || got != tt.wantPath {
+			t.Errorf("matchTransformer(%q) = %q, %v; want %q, true", tt.depPath, got, ok, tt.wantPath)
+		}
+	}
+}
+
 // §146: extractVersionFromPath
 func TestExtractVersionFromPath(t *testing.T) {
 	tests := []struct {
@@ -122,3 +148,87 @@ func TestIsVersionSuffix_Report(t *testing.T) {
 		})
 	}
 }
+
+func TestRuleErrors_AddFileAndMerge(t *testing.T) {
+	diag := Diagnostic{Level: DiagError, Line: 12, RuleID: "audit-hook", Message: "before: boom"}
+
+	child := NewReport("toolexec")
+	child.AddFile(FileReport{Path: "a.go", Status: StatusInstrumented, Diagnostics: []Diagnostic{
+		diag,
+		{Level: DiagWarning, Line: 3, Message: "not a rule error"},
+	}})
+	if child.Summary.RuleErrors != 1 || child.Summary.Warnings != 1 {
+		t.Fatalf("summary = %+v", child.Summary)
+	}
+	got := child.RuleErrors()
+	if len(got) != 1 || got[0].File != "a.go" || got[0].RuleID != "audit-hook" {
+		t.Fatalf("RuleErrors() = %+v", got)
+	}
+
+	parent := NewReport("go")
+	parent.MergeFragment(child)
+	if parent.Summary.RuleErrors != 1 || len(parent.RuleErrors()) != 1 {
+		t.Errorf("merged: summary=%d list=%d", parent.Summary.RuleErrors, len(parent.RuleErrors()))
+	}
+}
+
+func TestReadModuleVersions(t *testing.T) {
+	dir := t.TempDir()
+	gomod := `module mycorp.com/app
+
+go 1.22
+
+require github.com/gin-gonic/gin v1.9.1
+
+require (
+	// kafka
+	github.com/IBM/sarama v1.38.1
+	github.com/whatap/go-api v0.5.0
+	golang.org/x/sys v0.15.0 // indirect
+	github.com/segmentio/kafka-go v0.4.40
+	github.com/redis/rueidis v1.0.20
+)
+
+replace github.com/IBM/sarama => github.com/mycorp/sarama v1.40.0
+
+replace (
+	github.com/segmentio/kafka-go => ../kafka-go
+	github.com/redis/rueidis v1.0.19 => github.com/redis/rueidis v1.0.30
+)
+`
+	path := filepath.Join(dir, "go.mod")
+	if err := os.WriteFile(path, []byte(gomod), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadModuleVersions(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"github.com/gin-gonic/gin":      "v1.9.1",
+		"github.com/IBM/sarama":         "v1.40.0", // replaced by a fork
+		"github.com/whatap/go-api":      "v0.5.0",
+		"golang.org/x/sys":              "v0.15.0",
+		"github.com/redis/rueidis":      "v1.0.20", // replace pins another version
+		"github.com/segmentio/kafka-go": "",        // local directory
+	}
+	if len(got) != len(want) {
+		t.Fatalf("ReadModuleVersions = %v", got)
+	}
+	for mod, v := range want {
+		if got[mod] != v {
+			t.Errorf("%s = %q, want %q", mod, got[mod], v)
+		}
+	}
+	if _, err := ReadModuleVersions(filepath.Join(dir, "missing.mod")); err == nil {
+		t.Error("missing go.mod should fail")
+	}
+}
+
+func TestAddSkippedRule(t *testing.T) {
+	r := NewReport("go")
+	r.AddSkippedRule(Diagnostic{Level: DiagInfo, RuleID: "sarama-new", Message: "github.com/IBM/sarama v1.37.0 does not satisfy \">=1.38\""})
+	if r.Summary.SkippedRules != 1 || len(r.SkippedRules) != 1 || r.SkippedRules[0].RuleID != "sarama-new" {
+		t.Errorf("summary=%d list=%+v", r.Summary.SkippedRules, r.SkippedRules)
+	}
+}